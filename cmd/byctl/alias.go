@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/config"
+)
+
+const (
+	aliasListFlagJson = "json"
+)
+
+var aliasCmd = &cli.Command{
+	Name:  "alias",
+	Usage: "manage command aliases defined in config",
+	Subcommands: []*cli.Command{
+		aliasListCmd,
+	},
+}
+
+var aliasListCmd = &cli.Command{
+	Name:      "list",
+	Usage:     "list all aliases",
+	UsageText: "byctl alias list [command options]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  aliasListFlagJson,
+			Usage: "Output in json format",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, true)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		names := cfg.AliasNames()
+		if c.Bool(aliasListFlagJson) {
+			aliases := make(map[string]string, len(names))
+			for _, name := range names {
+				aliases[name], _ = cfg.GetAlias(name)
+			}
+			err = json.NewEncoder(os.Stdout).Encode(aliases)
+			if err != nil {
+				logger.Error("encode aliases", zap.Error(err))
+				return err
+			}
+			return nil
+		}
+
+		for _, name := range names {
+			def, _ := cfg.GetAlias(name)
+			// Aliases could not shadow builtin commands.
+			if isCommand(c.App, name) {
+				fmt.Printf("%s = %s (shadowed by builtin command)\n", name, def)
+				continue
+			}
+			fmt.Printf("%s = %s\n", name, def)
+		}
+		return nil
+	},
+}
+
+// isCommand checks whether name is a builtin command of a.
+func isCommand(a *cli.App, name string) bool {
+	return name == "help" || name == "h" || a.Command(name) != nil
+}
+
+// resolveAlias replaces the alias in args with the command it defined.
+//
+// args will be returned as is if no alias used. Global flags before the
+// alias will be kept.
+func resolveAlias(a *cli.App, args []string) ([]string, error) {
+	idx, path := findCommand(a, args)
+	if idx == -1 || isCommand(a, args[idx]) {
+		return args, nil
+	}
+
+	cfg, err := config.LoadLayered(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("load config %s: %w", path, err)
+	}
+
+	// Let cli report the unknown command.
+	if _, ok := cfg.GetAlias(args[idx]); !ok {
+		return args, nil
+	}
+
+	expanded, err := cfg.ExpandAlias(args[idx], args[idx+1:], func(name string) bool {
+		return isCommand(a, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, 0, idx+len(expanded))
+	out = append(out, args[:idx]...)
+	return append(out, expanded...), nil
+}
+
+// findCommand finds the index of command name in args and the config path
+// specified via global flags.
+//
+// idx will be -1 if command not found, and path will be the default one if
+// not specified.
+func findCommand(a *cli.App, args []string) (idx int, path string) {
+	valueFlags := valueFlagNames(a.Flags)
+
+	idx = -1
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			idx = i
+			break
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		value := ""
+		if sep := strings.Index(name, "="); sep != -1 {
+			name, value = name[:sep], name[sep+1:]
+		} else if valueFlags[name] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if name == flagConfigName || name == "c" {
+			path = value
+		}
+	}
+
+	if path == "" {
+		path = os.Getenv("BEYOND_CTL_CONFIG")
+	}
+	if path == "" {
+		path = flagConfig.Value
+	}
+	return idx, path
+}
+
+// valueFlagNames returns names of flags that take a value.
+func valueFlagNames(fs []cli.Flag) map[string]bool {
+	names := make(map[string]bool)
+	for _, f := range fs {
+		if _, ok := f.(*cli.BoolFlag); ok {
+			continue
+		}
+		for _, name := range f.Names() {
+			names[name] = true
+		}
+	}
+	return names
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/config"
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/v5/types"
+)
+
+// bandwidthSources records how to read the limit of every limiter key from
+// config, so that limits could be reloaded while running.
+var bandwidthSources = struct {
+	sync.Mutex
+	m    map[string]func(cfg *config.Config) string
+	once sync.Once
+}{
+	m: make(map[string]func(cfg *config.Config) string),
+}
+
+// applyBandwidthLimits applies the total bandwidth limit of the process, and
+// reloads limits of profiles from config on SIGUSR1.
+func applyBandwidthLimits(c *cli.Context) error {
+	text := c.String(flagBandwidthLimitName)
+	s, err := operations.ParseBandwidthSchedule(text)
+	if err != nil {
+		return fmt.Errorf("--%s %s is invalid: %w", flagBandwidthLimitName, text, err)
+	}
+	operations.SetBandwidth(operations.BandwidthTotal, s)
+
+	bandwidthSources.once.Do(func() {
+		go reloadBandwidthLimits(c.String(flagConfigName))
+	})
+	return nil
+}
+
+// reloadBandwidthLimits reloads limits from config at path on every reload
+// signal. Limits specified via flags take precedence, so they will not be
+// changed.
+func reloadBandwidthLimits(path string) {
+	if len(bandwidthReloadSignals) == 0 {
+		return
+	}
+	logger, _ := zap.NewDevelopment()
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, bandwidthReloadSignals...)
+
+	for range sigch {
+		cfg, err := config.LoadLayered(path, true)
+		if err != nil {
+			logger.Error("reload bandwidth limits", zap.String("config", path), zap.Error(err))
+			continue
+		}
+
+		bandwidthSources.Lock()
+		for key, source := range bandwidthSources.m {
+			s, err := operations.ParseBandwidthSchedule(source(cfg))
+			if err != nil {
+				logger.Error("reload bandwidth limits", zap.String("key", key), zap.Error(err))
+				continue
+			}
+			operations.SetBandwidth(key, s)
+		}
+		bandwidthSources.Unlock()
+		logger.Info("bandwidth limits reloaded", zap.String("config", path))
+	}
+}
+
+// setBandwidthLimit sets the limit of key read from cfg via source, and
+// records source for reloading.
+func setBandwidthLimit(cfg *config.Config, key string, source func(cfg *config.Config) string) error {
+	text := source(cfg)
+	s, err := operations.ParseBandwidthSchedule(text)
+	if err != nil {
+		return fmt.Errorf("bandwidth limit %s is invalid: %w", text, err)
+	}
+	operations.SetBandwidth(key, s)
+
+	bandwidthSources.Lock()
+	defer bandwidthSources.Unlock()
+	bandwidthSources.m[key] = source
+	return nil
+}
+
+// parseBandwidthPairs returns the pairs that limit reads or writes of the
+// storager of conn, which is picked via input. No pair will be returned if
+// all the limits are unlimited.
+//
+// The pair shares limiters with all reads and writes in the process:
+//   - the total limit if total is true, it should only be taken by one side
+//     of copies.
+//   - the bandwidth limit of the profile, shared by reads and writes.
+//   - the read or write speed limit of the profile, flagName takes precedence.
+func parseBandwidthPairs(
+	c *cli.Context, cfg *config.Config, input, conn string, total bool,
+	flagName string, option func(opts config.ProfileOptions) string,
+) ([]types.Pair, error) {
+	profileKey := "profile:" + conn
+	err := setBandwidthLimit(cfg, profileKey, func(cfg *config.Config) string {
+		return cfg.ParseProfileOptions(input).BandwidthLimit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sideKey := flagName + ":" + conn
+	if c.IsSet(flagName) {
+		err = setBandwidthLimit(cfg, sideKey, func(*config.Config) string {
+			return c.String(flagName)
+		})
+	} else {
+		err = setBandwidthLimit(cfg, sideKey, func(cfg *config.Config) string {
+			return option(cfg.ParseProfileOptions(input))
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pair, ok := operations.BandwidthPair(total, profileKey, sideKey)
+	if !ok {
+		return nil, nil
+	}
+	return []types.Pair{pair}, nil
+}
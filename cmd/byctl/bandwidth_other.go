@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package main
+
+import (
+	"os"
+)
+
+// bandwidthReloadSignals is empty, because there is no signal like SIGUSR1
+// on this platform, limits will not be reloaded while running.
+var bandwidthReloadSignals []os.Signal
@@ -0,0 +1,12 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// bandwidthReloadSignals are signals to reload bandwidth limits from config.
+var bandwidthReloadSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
+)
+
+const (
+	benchFlagSizes              = "sizes"
+	benchFlagCount              = "count"
+	benchFlagMultipartThreshold = "multipart-threshold"
+	benchFlagJson               = "json"
+)
+
+var benchFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  benchFlagSizes,
+		Usage: "comma separated sizes of synthetic objects, for example, 4KiB,1MiB,64MiB",
+		Value: "4KiB,1MiB,16MiB",
+	},
+	&cli.IntFlag{
+		Name:  benchFlagCount,
+		Usage: "the number of objects for every size",
+		Value: 32,
+	},
+	&cli.StringFlag{
+		Name:  benchFlagMultipartThreshold,
+		Usage: "objects not smaller than this size will be written via multipart",
+		EnvVars: []string{
+			"BEYOND_CTL_MULTIPART_THRESHOLD",
+		},
+		Value: "1GiB",
+	},
+	&cli.BoolFlag{
+		Name:  benchFlagJson,
+		Usage: "Output in json format",
+	},
+}
+
+var benchCmd = &cli.Command{
+	Name:      "bench",
+	Usage:     "benchmark throughput and latency of storage",
+	UsageText: "byctl bench [command options] [target]",
+	Description: `Synthetic objects will be written into a new dir under target, then they
+   will be read, stated, listed and deleted by workers concurrently. The dir
+   will be removed at last, even if the bench is interrupted via Ctrl-C, in
+   which case results of finished operations will still be printed.
+
+   Run it with different --workers and --multipart-threshold to pick the
+   best ones for the storage.`,
+	Flags: mergeFlags(globalFlags, multipartFlags, benchFlags),
+	Before: func(c *cli.Context) error {
+		if args := c.Args().Len(); args != 1 {
+			return fmt.Errorf("bench command wants one args, but got %d", args)
+		}
+		if count := c.Int(benchFlagCount); count <= 0 {
+			return fmt.Errorf("--%s must be positive, but got %d", benchFlagCount, count)
+		}
+		return nil
+	},
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, true)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		input := c.Args().First()
+		conn, key, err := cfg.ParseProfileInput(input)
+		if err != nil {
+			logger.Error("parse profile input from target", zap.Error(err))
+			return err
+		}
+
+		store, err := newStorager(cfg, input, conn)
+		if err != nil {
+			logger.Error("init target storager", zap.Error(err), zap.String("conn string", conn))
+			return err
+		}
+
+		var sizes []int64
+		for _, text := range strings.Split(c.String(benchFlagSizes), ",") {
+			size, err := units.RAMInBytes(strings.TrimSpace(text))
+			if err != nil {
+				logger.Error("size is invalid", zap.String("input", text), zap.Error(err))
+				return err
+			}
+			sizes = append(sizes, size)
+		}
+
+		opts := cfg.ParseProfileOptions(input)
+		multipartThreshold, err := parseMultipartThreshold(c, benchFlagMultipartThreshold, opts)
+		if err != nil {
+			logger.Error("parse multipart-threshold", zap.Error(err))
+			return err
+		}
+
+		partSize, err := parsePartSizeOption(c, opts)
+		if err != nil {
+			logger.Error("parse part-size", zap.Error(err))
+			return err
+		}
+
+		maxMemory, err := units.RAMInBytes(c.String(flagMaxMemoryName))
+		if err != nil {
+			logger.Error("max-memory is invalid",
+				zap.String("input", c.String(flagMaxMemoryName)),
+				zap.Error(err))
+			return err
+		}
+		operations.SetMaxMemory(maxMemory)
+
+		so := operations.NewSingleOperator(store)
+		if workers, ok := workersOption(c, opts); ok {
+			so.WithWorkers(workers)
+		}
+		so.WithPartSize(partSize)
+		so.WithPartConcurrency(intOption(c, flagPartConcurrencyName, opts.PartConcurrency))
+
+		// Objects are written into a new dir, so that existing objects will
+		// not be touched.
+		if key != "" && !strings.HasSuffix(key, "/") {
+			key += "/"
+		}
+		dir := key + "byctl-bench-" + uuid.NewString()
+
+		// Stop the bench on the first signal, so that the synthetic objects
+		// could be deleted. The second one will kill the process as usual.
+		stop := make(chan struct{})
+		sigch := make(chan os.Signal, 1)
+		signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigch)
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-sigch:
+				signal.Stop(sigch)
+				close(stop)
+			case <-done:
+			}
+		}()
+
+		results, err := so.Bench(dir, operations.BenchOptions{
+			Sizes:              sizes,
+			Count:              c.Int(benchFlagCount),
+			MultipartThreshold: multipartThreshold,
+			Stop:               stop,
+		})
+		if err != nil && !errors.Is(err, operations.ErrBenchStopped) {
+			logger.Error("run bench", zap.String("path", dir), zap.Error(err))
+			return err
+		}
+
+		if c.Bool(benchFlagJson) {
+			if perr := printBenchJSON(results); perr != nil {
+				return perr
+			}
+		} else if perr := printBenchTable(results); perr != nil {
+			return perr
+		}
+		return err
+	},
+}
+
+func printBenchTable(results []*operations.BenchResult) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OP\tSIZE\tCOUNT\tERRORS\tP50\tP90\tP99\tMAX\tOPS/S\tTHROUGHPUT")
+	for _, r := range results {
+		throughput := "-"
+		if r.Op == operations.BenchOpWrite || r.Op == operations.BenchOpRead {
+			throughput = units.BytesSize(r.Throughput()) + "/s"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%.1f\t%s\n",
+			r.Op, units.BytesSize(float64(r.Size)), r.Count, r.Errors,
+			formatLatency(r.Percentile(50)), formatLatency(r.Percentile(90)),
+			formatLatency(r.Percentile(99)), formatLatency(r.Percentile(100)),
+			r.OpsPerSecond(), throughput)
+	}
+	return w.Flush()
+}
+
+// benchRecord is the json output of a bench result, latencies are in
+// milliseconds.
+type benchRecord struct {
+	Op           string  `json:"op"`
+	Size         int64   `json:"size"`
+	Count        int     `json:"count"`
+	Errors       int     `json:"errors"`
+	P50          float64 `json:"p50_ms"`
+	P90          float64 `json:"p90_ms"`
+	P99          float64 `json:"p99_ms"`
+	Max          float64 `json:"max_ms"`
+	OpsPerSecond float64 `json:"ops_per_second"`
+	Throughput   float64 `json:"bytes_per_second"`
+}
+
+func printBenchJSON(results []*operations.BenchResult) error {
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	records := make([]benchRecord, 0, len(results))
+	for _, r := range results {
+		records = append(records, benchRecord{
+			Op:           r.Op,
+			Size:         r.Size,
+			Count:        r.Count,
+			Errors:       r.Errors,
+			P50:          ms(r.Percentile(50)),
+			P90:          ms(r.Percentile(90)),
+			P99:          ms(r.Percentile(99)),
+			Max:          ms(r.Percentile(100)),
+			OpsPerSecond: r.OpsPerSecond(),
+			Throughput:   r.Throughput(),
+		})
+	}
+	return json.NewEncoder(os.Stdout).Encode(records)
+}
+
+// formatLatency rounds d so that it's readable in table.
+func formatLatency(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
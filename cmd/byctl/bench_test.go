@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBench(t *testing.T) {
+	if os.Getenv("BEYOND_CTL_INTEGRATION_TEST") != "on" {
+		t.Skipf("BEYOND_CTL_INTEGRATION_TEST is not 'on', skipped")
+	}
+
+	base := uuid.NewString()
+	err := os.Setenv(
+		fmt.Sprintf("BEYOND_CTL_PROFILE_%s", base),
+		fmt.Sprintf(os.Getenv("BEYOND_CTL_TEST_SERVICE"), base+"/"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(fmt.Sprintf("BEYOND_CTL_PROFILE_%s", base))
+
+	err = app.Run([]string{
+		"byctl", "bench",
+		"--sizes=1KiB,64KiB",
+		"--count=8",
+		"--json",
+		fmt.Sprintf("%s:", base),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"go.uber.org/zap"
 
 	"go.beyondstorage.io/beyond-ctl/operations"
-	"go.beyondstorage.io/v5/services"
 )
 
 var catCmd = &cli.Command{
@@ -37,7 +36,7 @@ var catCmd = &cli.Command{
 				continue
 			}
 
-			store, err := services.NewStoragerFromString(conn)
+			store, err := newStorager(cfg, c.Args().Get(i), conn)
 			if err != nil {
 				logger.Error("init src storager", zap.Error(err), zap.String("conn string", conn))
 				continue
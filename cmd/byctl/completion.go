@@ -0,0 +1,380 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"go.beyondstorage.io/beyond-ctl/config"
+	"go.beyondstorage.io/beyond-ctl/operations"
+)
+
+const (
+	completeFlagShell = "shell"
+
+	// completionTimeout is the max duration of listing remote keys.
+	completionTimeout = 2 * time.Second
+	// completionCacheTTL is the duration that listed remote keys are cached.
+	completionCacheTTL = time.Minute
+)
+
+// pathCommands are commands that take paths as args.
+var pathCommands = map[string]bool{
+	"bench": true, "cat": true, "cp": true, "ls": true, "mount": true, "mv": true,
+	"rm": true, "serve": true, "setmeta": true, "shell": true, "sign": true,
+	"stat": true, "sync": true, "tee": true,
+}
+
+// profileNameCommands are subcommands of profile that take profile name as the
+// first arg.
+var profileNameCommands = map[string]bool{
+	"remove": true, "rename": true, "show": true, "test": true, "update": true,
+}
+
+var completionScripts = map[string]string{
+	"bash": `_byctl_complete() {
+    local line="${COMP_LINE:0:$COMP_POINT}"
+    local IFS=$'\n'
+    COMPREPLY=($(byctl __complete --shell bash -- "$line" 2>/dev/null))
+    # Do not append space after profile names and dirs.
+    if [[ ${#COMPREPLY[@]} -eq 1 && ( ${COMPREPLY[0]} == *: || ${COMPREPLY[0]} == */ ) ]]; then
+        compopt -o nospace
+    fi
+}
+complete -o default -F _byctl_complete byctl
+`,
+	"zsh": `#compdef byctl
+
+_byctl() {
+    local -a candidates
+    candidates=("${(@f)$(byctl __complete --shell zsh -- "${BUFFER[1,CURSOR]}" 2>/dev/null)}")
+    if [[ ${#candidates} -eq 0 || -z ${candidates[1]} ]]; then
+        _files
+        return
+    fi
+    local c
+    for c in $candidates; do
+        # Do not append space after profile names and dirs.
+        if [[ $c == *: || $c == */ ]]; then
+            compadd -S '' -- $c
+        else
+            compadd -- $c
+        fi
+    done
+}
+
+compdef _byctl byctl
+`,
+	"fish": `function __byctl_complete
+    byctl __complete --shell fish -- (commandline -cp) 2>/dev/null
+end
+
+complete -c byctl -a '(__byctl_complete)'
+`,
+}
+
+var completionCmd = &cli.Command{
+	Name:      "completion",
+	Usage:     "output shell completion script for bash, zsh or fish",
+	UsageText: "byctl completion [bash|zsh|fish]",
+	Description: `Load completion in current shell:
+
+   bash: source <(byctl completion bash)
+   zsh:  source <(byctl completion zsh)
+   fish: byctl completion fish | source`,
+	Before: func(c *cli.Context) error {
+		if args := c.Args().Len(); args != 1 {
+			return fmt.Errorf("completion command wants one args, but got %d", args)
+		}
+		return nil
+	},
+	Action: func(c *cli.Context) error {
+		script, ok := completionScripts[c.Args().First()]
+		if !ok {
+			return fmt.Errorf("shell %s is not supported", c.Args().First())
+		}
+		fmt.Print(script)
+		return nil
+	},
+}
+
+var completeCmd = &cli.Command{
+	Name:   "__complete",
+	Usage:  "output completion candidates for the command line",
+	Hidden: true,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  completeFlagShell,
+			Value: "bash",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		words, err := config.SplitArgs(c.Args().First())
+		if err != nil {
+			// Quote not closed yet, nothing to complete.
+			return nil
+		}
+		// Start a new word if the line ends with space.
+		if len(words) == 0 || strings.HasSuffix(c.Args().First(), " ") {
+			words = append(words, "")
+		}
+
+		cur := words[len(words)-1]
+		// bash treats `:` as word break, so candidates should only contain
+		// the part after the last `:`.
+		trim := ""
+		if c.String(completeFlagShell) == "bash" {
+			trim = cur[:strings.LastIndex(cur, ":")+1]
+		}
+		for _, v := range completeWords(c.App, words) {
+			fmt.Println(strings.TrimPrefix(v, trim))
+		}
+		return nil
+	},
+}
+
+// completeWords returns candidates for the last word in words, words[0] is
+// the program name.
+func completeWords(a *cli.App, words []string) []string {
+	cur := words[len(words)-1]
+	idx, path := findCommand(a, words[:len(words)-1])
+
+	// Loading config may fail while typing, only profiles and aliases will
+	// be missing in that case. Completion should never write config file.
+	cfg, err := config.LoadLayeredReadOnly(path, false)
+	if err != nil {
+		cfg = config.New()
+	}
+
+	// Complete command name or global flags.
+	if idx == -1 {
+		if strings.HasPrefix(cur, "-") {
+			return completeFlags(a.Flags, cur)
+		}
+		var names []string
+		for _, cmd := range a.VisibleCommands() {
+			names = append(names, cmd.Name)
+		}
+		names = append(names, cfg.AliasNames()...)
+		return filterPrefix(names, cur)
+	}
+
+	cmd := a.Command(words[idx])
+	if cmd == nil {
+		return nil
+	}
+	parents := []string{cmd.Name}
+
+	// Find the subcommand and positional args before cur.
+	var positional []string
+	valueFlags := valueFlagNames(cmd.Flags)
+	for i := idx + 1; i < len(words)-1; i++ {
+		arg := words[i]
+		if strings.HasPrefix(arg, "-") {
+			if !strings.Contains(arg, "=") && valueFlags[strings.TrimLeft(arg, "-")] {
+				i++
+			}
+			continue
+		}
+		if len(positional) == 0 && len(cmd.Subcommands) > 0 {
+			if sub := cmd.Command(arg); sub != nil {
+				cmd = sub
+				parents = append(parents, sub.Name)
+				valueFlags = valueFlagNames(cmd.Flags)
+				continue
+			}
+		}
+		positional = append(positional, arg)
+	}
+
+	// Value of flag will not be completed.
+	if prev := words[len(words)-2]; strings.HasPrefix(prev, "-") && !strings.Contains(prev, "=") &&
+		valueFlags[strings.TrimLeft(prev, "-")] && len(words)-2 > idx {
+		return nil
+	}
+
+	if strings.HasPrefix(cur, "-") {
+		return completeFlags(cmd.Flags, cur)
+	}
+
+	if len(cmd.Subcommands) > 0 {
+		var names []string
+		for _, sub := range cmd.VisibleCommands() {
+			names = append(names, sub.Name)
+		}
+		return filterPrefix(names, cur)
+	}
+
+	if parents[0] == "profile" && profileNameCommands[cmd.Name] && len(positional) == 0 {
+		return filterPrefix(profileNames(cfg), cur)
+	}
+
+	if !pathCommands[parents[0]] {
+		return nil
+	}
+
+	if sep := strings.Index(cur, ":"); sep != -1 {
+		if _, ok := cfg.GetProfile(cur[:sep]); ok {
+			return completeRemotePath(cfg, cur)
+		}
+		return nil
+	}
+
+	var names []string
+	for _, name := range profileNames(cfg) {
+		names = append(names, name+":")
+	}
+	names = append(names, completeLocalPath("", cur)...)
+	return filterPrefix(names, cur)
+}
+
+// completeFlags returns names of flags that start with cur.
+func completeFlags(fs []cli.Flag, cur string) []string {
+	var names []string
+	for _, f := range fs {
+		for _, name := range f.Names() {
+			if len(name) == 1 {
+				names = append(names, "-"+name)
+			} else {
+				names = append(names, "--"+name)
+			}
+		}
+	}
+	return filterPrefix(names, cur)
+}
+
+func profileNames(cfg *config.Config) []string {
+	var names []string
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	return names
+}
+
+// completeRemotePath returns remote paths that start with cur like
+// `profile:dir/file`.
+func completeRemotePath(cfg *config.Config, cur string) []string {
+	sep := strings.Index(cur, ":")
+	dir := cur[:sep+1]
+	if idx := strings.LastIndex(cur, "/"); idx > sep {
+		dir = cur[:idx+1]
+	}
+
+	conn, key, err := cfg.ParseProfileInput(dir)
+	if err != nil {
+		return nil
+	}
+	names, err := listCompletions(conn, key)
+	if err != nil {
+		return nil
+	}
+
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, dir+name)
+	}
+	return filterPrefix(paths, cur)
+}
+
+type completionCache struct {
+	Names []string `json:"names"`
+}
+
+// listCompletions lists names of objects under dir key in storager conn.
+//
+// Names of dirs end with `/`. Results will be cached for completionCacheTTL,
+// and listing will be aborted after completionTimeout.
+func listCompletions(conn, key string) ([]string, error) {
+	// Connection string may contain credentials, so we use hash as the name
+	// of cache file.
+	sum := sha256.Sum256([]byte(conn + "\n" + key))
+	var cachePath string
+	if dir, err := os.UserCacheDir(); err == nil {
+		cachePath = filepath.Join(dir, "byctl", "completion", hex.EncodeToString(sum[:]))
+	}
+
+	if cachePath != "" {
+		fi, err := os.Stat(cachePath)
+		if err == nil && time.Since(fi.ModTime()) < completionCacheTTL {
+			content, err := ioutil.ReadFile(cachePath)
+			var cache completionCache
+			if err == nil && json.Unmarshal(content, &cache) == nil {
+				return cache.Names, nil
+			}
+		}
+	}
+
+	type result struct {
+		names []string
+		err   error
+	}
+	// Buffered, so that the list goroutine could exit after timeout.
+	ch := make(chan result, 1)
+	go func() {
+		names, err := listNames(conn, key)
+		ch <- result{names, err}
+	}()
+
+	var names []string
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil, r.err
+		}
+		names = r.names
+	case <-time.After(completionTimeout):
+		return nil, fmt.Errorf("list %s timeout", key)
+	}
+
+	if cachePath != "" {
+		content, err := json.Marshal(completionCache{Names: names})
+		if err == nil && os.MkdirAll(filepath.Dir(cachePath), 0700) == nil {
+			_ = ioutil.WriteFile(cachePath, content, 0600)
+		}
+	}
+	return names, nil
+}
+
+func listNames(conn, key string) ([]string, error) {
+	store, err := newStorager(nil, "", conn)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := operations.NewSingleOperator(store).List(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// The channel is always drained, so that the listing goroutine will not
+	// be blocked forever.
+	var names []string
+	for v := range ch {
+		if err != nil {
+			continue
+		}
+		if v.Error != nil {
+			err = v.Error
+			continue
+		}
+		if len(names) >= maxCompletions {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(v.Object.Path, key), "/")
+		if v.Object.Mode.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
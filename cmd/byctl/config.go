@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/config"
+)
+
+const (
+	configMigrateFlagCheck = "check"
+	configShowFlagOrigin   = "origin"
+	configShowFlagJson     = "json"
+)
+
+var configCmd = &cli.Command{
+	Name:  "config",
+	Usage: "manage config file",
+	Subcommands: []*cli.Command{
+		configMigrateCmd,
+		configShowCmd,
+	},
+}
+
+var configShowCmd = &cli.Command{
+	Name:      "show",
+	Usage:     "show config merged from system, user, project config and environment variables",
+	UsageText: "byctl config show [command options]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  configShowFlagOrigin,
+			Usage: "show where every value comes from",
+		},
+		&cli.BoolFlag{
+			Name:  configShowFlagJson,
+			Usage: "Output in json format",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, true)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		items, err := flattenConfig(cfg)
+		if err != nil {
+			logger.Error("flatten config", zap.Error(err))
+			return err
+		}
+
+		if c.Bool(configShowFlagJson) {
+			if !c.Bool(configShowFlagOrigin) {
+				for _, item := range items {
+					item.Origin = ""
+				}
+			}
+			err = json.NewEncoder(os.Stdout).Encode(items)
+			if err != nil {
+				logger.Error("encode config", zap.Error(err))
+				return err
+			}
+			return nil
+		}
+
+		for _, item := range items {
+			if c.Bool(configShowFlagOrigin) {
+				fmt.Printf("%s\t", item.Origin)
+			}
+			fmt.Printf("%s=%v\n", item.Key, item.Value)
+		}
+		return nil
+	},
+}
+
+type configItem struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Origin string      `json:"origin,omitempty"`
+}
+
+// flattenConfig flattens all items in config into key value pairs with
+// secrets masked, for example, `profile.<name>.connection=s3://bucket`.
+func flattenConfig(cfg *config.Config) ([]*configItem, error) {
+	items := make([]*configItem, 0)
+
+	for _, key := range cfg.OriginKeys() {
+		var value interface{}
+		switch {
+		case strings.HasPrefix(key, "profile."):
+			prof, ok := cfg.GetProfile(strings.TrimPrefix(key, "profile."))
+			if !ok {
+				continue
+			}
+			value = prof.Masked()
+		case strings.HasPrefix(key, "alias."):
+			def, ok := cfg.GetAlias(strings.TrimPrefix(key, "alias."))
+			if !ok {
+				continue
+			}
+			value = def
+		default:
+			continue
+		}
+
+		// Convert value into a map via json, so that nested fields could be
+		// flattened.
+		content, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		var m interface{}
+		if err = json.Unmarshal(content, &m); err != nil {
+			return nil, err
+		}
+
+		origin := cfg.Origin(key)
+		// Options of profiles could be set by project config.
+		optionsOrigin := cfg.Origin(key + ".options")
+		flattenValue(key, m, func(k string, v interface{}) {
+			item := &configItem{
+				Key:    k,
+				Value:  v,
+				Origin: origin,
+			}
+			if optionsOrigin != "" && strings.HasPrefix(k, key+".options.") {
+				item.Origin = optionsOrigin
+			}
+			items = append(items, item)
+		})
+	}
+	return items, nil
+}
+
+func flattenValue(prefix string, v interface{}, fn func(key string, value interface{})) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		fn(prefix, v)
+		return
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		flattenValue(prefix+"."+k, m[k], fn)
+	}
+}
+
+var configMigrateCmd = &cli.Command{
+	Name:      "migrate",
+	Usage:     "upgrade config file to the version supported by byctl",
+	UsageText: "byctl config migrate [command options]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  configMigrateFlagCheck,
+			Usage: "preview changes without rewriting config file",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		path := c.String(flagConfigName)
+
+		var plan *config.MigrationPlan
+		var backup string
+		var err error
+		if c.Bool(configMigrateFlagCheck) {
+			plan, err = config.PlanMigration(path)
+		} else {
+			plan, backup, err = config.MigrateFile(path)
+		}
+		if err != nil {
+			logger.Error("migrate config", zap.String("path", path), zap.Error(err))
+			return err
+		}
+
+		if !plan.NeedMigrate() {
+			fmt.Printf("config ver. %d is up to date\n", plan.From)
+			return nil
+		}
+
+		fmt.Printf("migrate config from ver. %d to ver. %d:\n", plan.From, plan.To)
+		for _, m := range plan.Migrations {
+			fmt.Printf("  ver. %d -> %d: %s\n", m.From, m.From+1, m.Description)
+		}
+		original, migrated, err := plan.Masked()
+		if err != nil {
+			logger.Error("mask config", zap.String("path", path), zap.Error(err))
+			return err
+		}
+		fmt.Println()
+		fmt.Print(diffLines(string(original), string(migrated)))
+
+		if plan.DropsComments() {
+			if backup != "" {
+				fmt.Printf("\nwarning: comments in config file are dropped, they are only kept in the backup\n")
+			} else {
+				fmt.Printf("\nwarning: comments in config file will be dropped by migration\n")
+			}
+		}
+		if backup != "" {
+			fmt.Printf("\noriginal config is backed up to %s\n", backup)
+		}
+		return nil
+	},
+}
+
+// diffLines returns the line based diff between a and b.
+//
+// Config files are small, so we use the simple LCS algorithm here.
+func diffLines(a, b string) string {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of LCS of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	buf := pool.Get()
+	defer buf.Free()
+
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			buf.AppendString("  " + x[i] + "\n")
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			buf.AppendString("- " + x[i] + "\n")
+			i++
+		default:
+			buf.AppendString("+ " + y[j] + "\n")
+			j++
+		}
+	}
+	return buf.String()
+}
@@ -11,7 +11,6 @@ import (
 
 	"go.beyondstorage.io/beyond-ctl/operations"
 	"go.beyondstorage.io/v5/services"
-	"go.beyondstorage.io/v5/types"
 )
 
 const (
@@ -42,7 +41,7 @@ var cpCmd = &cli.Command{
 	Name:      "cp",
 	Usage:     "copy file from source storager to target storager",
 	UsageText: "byctl cp [command options] [source] [target]",
-	Flags:     mergeFlags(globalFlags, ioFlags, cpFlags),
+	Flags:     mergeFlags(globalFlags, ioFlags, multipartFlags, metadataFlags, cpFlags, []cli.Flag{flagPreserve}),
 	Before: func(c *cli.Context) error {
 		if args := c.Args().Len(); args < 2 {
 			return fmt.Errorf("cp command wants at least two args, but got %d", args)
@@ -66,7 +65,7 @@ var cpCmd = &cli.Command{
 			return err
 		}
 
-		dst, err := services.NewStoragerFromString(dstConn)
+		dst, err := newStorager(cfg, c.Args().Get(argsNum-1), dstConn)
 		if err != nil {
 			logger.Error("init dst storager", zap.Error(err), zap.String("conn string", dstConn))
 			return err
@@ -90,42 +89,40 @@ var cpCmd = &cli.Command{
 			}
 		}
 
-		// Handle read pairs.
-		var readPairs []types.Pair
-		if c.IsSet(flagReadSpeedLimitName) {
-			limitPair, err := parseLimit(c.String(flagReadSpeedLimitName))
-			if err != nil {
-				logger.Error("read limit is invalid",
-					zap.String("input", c.String(flagReadSpeedLimitName)),
-					zap.Error(err))
-				return err
-			}
-
-			readPairs = append(readPairs, limitPair)
+		// Handle write pairs, write options are picked from the target profile.
+		dstOpts := cfg.ParseProfileOptions(c.Args().Get(argsNum - 1))
+		writePairs, err := parseWritePairs(c, cfg, c.Args().Get(argsNum-1), dstConn)
+		if err != nil {
+			logger.Error("parse write pairs", zap.Error(err))
+			return err
+		}
+		metadataPairs, err := parseMetadataPairs(c, dstOpts, dstConn)
+		if err != nil {
+			logger.Error("parse metadata pairs", zap.Error(err))
+			return err
 		}
 
-		// Handle write pairs.
-		var writePairs []types.Pair
-		if c.IsSet(flagWriteSpeedLimitName) {
-			limitPair, err := parseLimit(c.String(flagWriteSpeedLimitName))
-			if err != nil {
-				logger.Error("write limit is invalid",
-					zap.String("input", c.String(flagWriteSpeedLimitName)),
-					zap.Error(err))
-				return err
-			}
+		// parse flag multipart-threshold, 1GB is the default value
+		multipartThreshold, err := parseMultipartThreshold(c, cpFlagMultipartThresholdName, dstOpts)
+		if err != nil {
+			logger.Error("parse multipart-threshold", zap.Error(err))
+			return err
+		}
 
-			writePairs = append(writePairs, limitPair)
+		partSize, err := parsePartSizeOption(c, dstOpts)
+		if err != nil {
+			logger.Error("parse part-size", zap.Error(err))
+			return err
 		}
 
-		// parse flag multipart-threshold, 1GB is the default value
-		multipartThreshold, err := units.FromHumanSize(c.String(cpFlagMultipartThresholdName))
+		maxMemory, err := units.RAMInBytes(c.String(flagMaxMemoryName))
 		if err != nil {
-			logger.Error("multipart-threshold is invalid",
-				zap.String("input", c.String(cpFlagMultipartThresholdName)),
+			logger.Error("max-memory is invalid",
+				zap.String("input", c.String(flagMaxMemoryName)),
 				zap.Error(err))
 			return err
 		}
+		operations.SetMaxMemory(maxMemory)
 
 		for i := 0; i < argsNum-1; i++ {
 			srcConn, srcKey, err := cfg.ParseProfileInput(c.Args().Get(i))
@@ -134,12 +131,20 @@ var cpCmd = &cli.Command{
 				continue
 			}
 
-			src, err := services.NewStoragerFromString(srcConn)
+			src, err := newStorager(cfg, c.Args().Get(i), srcConn)
 			if err != nil {
 				logger.Error("init src storager", zap.Error(err), zap.String("conn string", srcConn))
 				continue
 			}
 
+			// Handle read pairs, read options are picked from the source profile.
+			srcOpts := cfg.ParseProfileOptions(c.Args().Get(i))
+			readPairs, err := parseReadPairs(c, cfg, c.Args().Get(i), srcConn)
+			if err != nil {
+				logger.Error("parse read pairs", zap.Error(err))
+				continue
+			}
+
 			so := operations.NewSingleOperator(src)
 
 			srcObject, err := so.Stat(srcKey)
@@ -163,15 +168,26 @@ var cpCmd = &cli.Command{
 				size = n
 			}
 
+			preserve, err := parsePreserveOptions(c, src, srcConn, dst, dstConn)
+			if err != nil {
+				logger.Error("parse preserve", zap.Error(err))
+				continue
+			}
+
 			do := operations.NewDualOperator(src, dst)
-			if c.IsSet(flagWorkersName) {
-				do.WithWorkers(c.Int(flagWorkersName))
+			if workers, ok := workersOption(c, dstOpts, srcOpts); ok {
+				do.WithWorkers(workers)
 			}
+			do.WithPartSize(partSize)
+			do.WithPartConcurrency(intOption(c, flagPartConcurrencyName, dstOpts.PartConcurrency))
 
 			// set read pairs
 			do.WithReadPairs(readPairs...)
 			// set write pairs
 			do.WithWritePairs(writePairs...)
+			do.WithMetadataPairs(metadataPairs...)
+			do.WithContentTypeDetection(c.Bool(flagDetectContentTypeName))
+			do.WithPreserve(preserve)
 
 			realDstKey := dstKey
 			if argsNum > 2 || (dstObject != nil && dstObject.Mode.IsDir()) {
@@ -11,6 +11,10 @@ var (
 	globalFlags = []cli.Flag{
 		flagConfig,
 		flagWorkers,
+		flagAdaptiveWorkers,
+		flagMaxWorkers,
+		flagBandwidthLimit,
+		flagMaxRPS,
 	}
 	// IO flags will be applied to all operations that will have read or write IO
 	// operations
@@ -18,13 +22,41 @@ var (
 		flagReadSpeedLimit,
 		flagWriteSpeedLimit,
 	}
+	// multipart flags will be applied to all operations that could upload
+	// objects via multipart related operations.
+	multipartFlags = []cli.Flag{
+		flagPartSize,
+		flagPartConcurrency,
+		flagMaxMemory,
+	}
+	// metadata flags will be applied to all operations that write objects.
+	metadataFlags = []cli.Flag{
+		flagContentType,
+		flagDetectContentType,
+		flagMeta,
+		flagStorageClass,
+		flagCacheControl,
+	}
 )
 
 const (
-	flagConfigName          = "config"
-	flagWorkersName         = "workers"
-	flagReadSpeedLimitName  = "read-speed-limit"
-	flagWriteSpeedLimitName = "write-speed-limit"
+	flagConfigName            = "config"
+	flagWorkersName           = "workers"
+	flagAdaptiveWorkersName   = "adaptive-workers"
+	flagMaxWorkersName        = "max-workers"
+	flagBandwidthLimitName    = "bandwidth-limit"
+	flagMaxRPSName            = "max-rps"
+	flagReadSpeedLimitName    = "read-speed-limit"
+	flagWriteSpeedLimitName   = "write-speed-limit"
+	flagPartSizeName          = "part-size"
+	flagPartConcurrencyName   = "part-concurrency"
+	flagMaxMemoryName         = "max-memory"
+	flagContentTypeName       = "content-type"
+	flagDetectContentTypeName = "detect-content-type"
+	flagMetaName              = "meta"
+	flagStorageClassName      = "storage-class"
+	flagCacheControlName      = "cache-control"
+	flagPreserveName          = "preserve"
 )
 
 var (
@@ -45,20 +77,96 @@ var (
 		},
 		Value: 4,
 	}
+	flagAdaptiveWorkers = &cli.BoolFlag{
+		Name:  flagAdaptiveWorkersName,
+		Usage: "Tune the workers number via observed throughput, latency and errors, --workers will be the initial number",
+		EnvVars: []string{
+			"BEYOND_CTL_ADAPTIVE_WORKERS",
+		},
+	}
+	flagMaxWorkers = &cli.IntFlag{
+		Name:  flagMaxWorkersName,
+		Usage: "Specify the max number of running workers across all operations, 0 means unlimited",
+		EnvVars: []string{
+			"BEYOND_CTL_MAX_WORKERS",
+		},
+	}
+	flagBandwidthLimit = &cli.StringFlag{
+		Name:  flagBandwidthLimitName,
+		Usage: "Specify the total speed limit shared by all read and write I/O operations, for example, 10MB or 09:00-18:00=10MB,unlimited to limit it during business hours only. Limits of profiles are reloaded from config on SIGUSR1.",
+		EnvVars: []string{
+			"BEYOND_CTL_BANDWIDTH_LIMIT",
+		},
+	}
+	flagMaxRPS = &cli.Float64Flag{
+		Name:  flagMaxRPSName,
+		Usage: "Specify the max requests per second sent to every storage, which overrides max_rps of profiles. Requests will be slowed down automatically while the storage throttles them",
+		EnvVars: []string{
+			"BEYOND_CTL_MAX_RPS",
+		},
+	}
 	flagReadSpeedLimit = &cli.StringFlag{
 		Name:  flagReadSpeedLimitName,
-		Usage: "Specify speed limit for read I/O operations, for example, 1MB, 10mb, 3GiB.",
+		Usage: "Specify speed limit for read I/O operations, for example, 1MB, 10mb, 3GiB or 09:00-18:00=10MB,unlimited.",
 		EnvVars: []string{
 			"BEYOND_CTL_READ_SPEED_LIMIT",
 		},
 	}
 	flagWriteSpeedLimit = &cli.StringFlag{
 		Name:  flagWriteSpeedLimitName,
-		Usage: "Specify speed limit for write I/O operations, for example, 1MB, 10mb, 3GiB.",
+		Usage: "Specify speed limit for write I/O operations, for example, 1MB, 10mb, 3GiB or 09:00-18:00=10MB,unlimited.",
 		EnvVars: []string{
 			"BEYOND_CTL_WRITE_SPEED_LIMIT",
 		},
 	}
+	flagPartSize = &cli.StringFlag{
+		Name:  flagPartSizeName,
+		Usage: "Specify part size for multipart operations, for example, 64MiB. Use auto to calculate it from object size and service limits.",
+		EnvVars: []string{
+			"BEYOND_CTL_PART_SIZE",
+		},
+		Value: partSizeAuto,
+	}
+	flagPartConcurrency = &cli.IntFlag{
+		Name:  flagPartConcurrencyName,
+		Usage: "Specify the number of parts uploaded concurrently for every multipart object",
+		EnvVars: []string{
+			"BEYOND_CTL_PART_CONCURRENCY",
+		},
+		Value: 4,
+	}
+	flagMaxMemory = &cli.StringFlag{
+		Name:  flagMaxMemoryName,
+		Usage: "Specify the memory budget of part buffers for multipart operations, for example, 512MiB. Reading will be paused until buffers are released.",
+		EnvVars: []string{
+			"BEYOND_CTL_MAX_MEMORY",
+		},
+		Value: "1GiB",
+	}
+	flagContentType = &cli.StringFlag{
+		Name:  flagContentTypeName,
+		Usage: "Specify the content type of written objects, for example, text/plain.",
+	}
+	flagDetectContentType = &cli.BoolFlag{
+		Name:  flagDetectContentTypeName,
+		Usage: "Detect the content type from extension or content of files if it's not specified",
+	}
+	flagMeta = &cli.StringSliceFlag{
+		Name:  flagMetaName,
+		Usage: "Specify the user metadata of written objects in `KEY=VALUE`, could be repeated.",
+	}
+	flagStorageClass = &cli.StringFlag{
+		Name:  flagStorageClassName,
+		Usage: "Specify the storage class of written objects, for example, STANDARD_IA for s3.",
+	}
+	flagCacheControl = &cli.StringFlag{
+		Name:  flagCacheControlName,
+		Usage: "Specify the cache control of written objects, for example, max-age=3600.",
+	}
+	flagPreserve = &cli.StringFlag{
+		Name:  flagPreserveName,
+		Usage: "Preserve the specified attributes of source files, could be a comma separated list of mode, timestamps, content-type and user-meta.",
+	}
 )
 
 func mergeFlags(fs ...[]cli.Flag) []cli.Flag {
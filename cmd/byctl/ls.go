@@ -10,7 +10,6 @@ import (
 	"go.uber.org/zap"
 
 	"go.beyondstorage.io/beyond-ctl/operations"
-	"go.beyondstorage.io/v5/services"
 	"go.beyondstorage.io/v5/types"
 )
 
@@ -60,7 +59,7 @@ var lsCmd = &cli.Command{
 				continue
 			}
 
-			store, err := services.NewStoragerFromString(conn)
+			store, err := newStorager(cfg, c.Args().Get(i), conn)
 			if err != nil {
 				logger.Error("init storager", zap.Error(err))
 				continue
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"sync"
 
 	"github.com/urfave/cli/v2"
 )
@@ -19,6 +21,11 @@ var app = cli.App{
 	Version:     Version,
 	Flags:       mergeFlags(globalFlags),
 	Commands: []*cli.Command{
+		aliasCmd,
+		benchCmd,
+		completeCmd,
+		completionCmd,
+		configCmd,
 		cpCmd,
 		lsCmd,
 		profileCmd,
@@ -26,20 +33,87 @@ var app = cli.App{
 		statCmd,
 		teeCmd,
 		catCmd,
+		mountCmd,
 		mvCmd,
 		signCmd,
+		serveCmd,
+		setmetaCmd,
+		shellCmd,
 		syncCmd,
 	},
 }
 
 func main() {
-	err := app.Run(os.Args)
+	// Worker, bandwidth and request limits are global flags of every
+	// command, and they must be applied before any operator is created.
+	workerLimits := applyOnce(applyWorkerLimits, flagMaxWorkersName, flagAdaptiveWorkersName)
+	bandwidthLimits := applyOnce(applyBandwidthLimits, flagBandwidthLimitName)
+	requestLimits := applyOnce(applyRequestLimits, flagMaxRPSName)
+	for _, cmd := range app.Commands {
+		cmd.Before = chainBefore(workerLimits, bandwidthLimits, requestLimits, cmd.Before)
+	}
+
+	args, err := resolveAlias(&app, os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "byctl: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = app.Run(args)
 	if err != nil {
 		// FIXME: we need to respect platform style later.
 		os.Exit(1)
 	}
 }
 
+// chainBefore returns a BeforeFunc that runs fns in order, nil fns will be
+// skipped.
+func chainBefore(fns ...cli.BeforeFunc) cli.BeforeFunc {
+	return func(c *cli.Context) error {
+		for _, fn := range fns {
+			if fn == nil {
+				continue
+			}
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// applyOnce returns a BeforeFunc that applies process wide limits via fn.
+//
+// Commands running in shell mode share the process, so fn is only called
+// again if any of flags is set, otherwise limits applied by the shell will be
+// reset to the defaults by every command.
+func applyOnce(fn cli.BeforeFunc, flags ...string) cli.BeforeFunc {
+	var mu sync.Mutex
+	applied := false
+	return func(c *cli.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if applied && !anyFlagSet(c, flags) {
+			return nil
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+		applied = true
+		return nil
+	}
+}
+
+func anyFlagSet(c *cli.Context, flags []string) bool {
+	for _, name := range flags {
+		if c.IsSet(name) {
+			return true
+		}
+	}
+	return false
+}
+
 func userConfigDir() string {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestApplyOnce(t *testing.T) {
+	var applied []int
+	before := applyOnce(func(c *cli.Context) error {
+		applied = append(applied, c.Int(flagMaxWorkersName))
+		return nil
+	}, flagMaxWorkersName)
+
+	run := func(args ...string) {
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := flagMaxWorkers.Apply(set); err != nil {
+			t.Fatal(err)
+		}
+		if err := set.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		if err := before(cli.NewContext(cli.NewApp(), set, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first command always applies limits, later ones only apply them
+	// if the flags are set.
+	run("--" + flagMaxWorkersName + "=8")
+	run()
+	run("--" + flagMaxWorkersName + "=4")
+	assert.Equal(t, []int{8, 4}, applied)
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/urfave/cli/v2"
+)
+
+var mountCmd = &cli.Command{
+	Name:      "mount",
+	Usage:     "mount storager as a local filesystem",
+	UsageText: "byctl mount [command options] [source] [mountpoint]",
+	Action: func(c *cli.Context) error {
+		return fmt.Errorf("mount is not supported on %s", runtime.GOOS)
+	},
+}
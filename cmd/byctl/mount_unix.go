@@ -0,0 +1,131 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/mount"
+	"go.beyondstorage.io/beyond-ctl/operations"
+)
+
+const (
+	mountFlagCacheTTL               = "cache-ttl"
+	mountFlagReadOnly               = "read-only"
+	mountFlagAllowOther             = "allow-other"
+	mountFlagMultipartThresholdName = "multipart-threshold"
+)
+
+var mountFlags = []cli.Flag{
+	&cli.DurationFlag{
+		Name:  mountFlagCacheTTL,
+		Usage: "duration that metadata of files and dirs will be cached, 0 to disable cache",
+		Value: time.Minute,
+	},
+	&cli.BoolFlag{
+		Name:  mountFlagReadOnly,
+		Usage: "mount the filesystem as read only",
+	},
+	&cli.BoolFlag{
+		Name:  mountFlagAllowOther,
+		Usage: "allow other users to access the filesystem",
+	},
+	&cli.StringFlag{
+		Name:  mountFlagMultipartThresholdName,
+		Usage: "Specify multipart threshold. If written file size is larger than this value, byctl will use multipart method to upload file.",
+		EnvVars: []string{
+			"BEYOND_CTL_MULTIPART_THRESHOLD",
+		},
+		Value: "1GiB", // Use 1 GiB as the default value.
+	},
+}
+
+var mountCmd = &cli.Command{
+	Name:      "mount",
+	Usage:     "mount storager as a local filesystem",
+	UsageText: "byctl mount [command options] [source] [mountpoint]",
+	Description: `Files are read via ranged reads, and written files are buffered locally
+   and uploaded while they are closed. Use Ctrl-C or umount to unmount.`,
+	Flags: mergeFlags(globalFlags, multipartFlags, mountFlags),
+	Before: func(c *cli.Context) error {
+		if args := c.Args().Len(); args != 2 {
+			return fmt.Errorf("mount command wants two args, but got %d", args)
+		}
+		return nil
+	},
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, true)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		conn, key, err := cfg.ParseProfileInput(c.Args().Get(0))
+		if err != nil {
+			logger.Error("parse profile input", zap.Error(err))
+			return err
+		}
+
+		store, err := newStorager(cfg, c.Args().Get(0), conn)
+		if err != nil {
+			logger.Error("init storager", zap.Error(err), zap.String("conn string", conn))
+			return err
+		}
+
+		opts := cfg.ParseProfileOptions(c.Args().Get(0))
+		multipartThreshold, err := parseMultipartThreshold(c, mountFlagMultipartThresholdName, opts)
+		if err != nil {
+			logger.Error("parse multipart-threshold", zap.Error(err))
+			return err
+		}
+		partSize, err := parsePartSizeOption(c, opts)
+		if err != nil {
+			logger.Error("parse part-size", zap.Error(err))
+			return err
+		}
+
+		so := operations.NewSingleOperator(store).
+			WithPartSize(partSize).
+			WithPartConcurrency(intOption(c, flagPartConcurrencyName, opts.PartConcurrency))
+
+		fsys := mount.New(store, so, key, mount.Options{
+			CacheTTL:           c.Duration(mountFlagCacheTTL),
+			ReadOnly:           c.Bool(mountFlagReadOnly),
+			AllowOther:         c.Bool(mountFlagAllowOther),
+			MultipartThreshold: multipartThreshold,
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sigch := make(chan os.Signal, 1)
+		signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigch)
+		go func() {
+			select {
+			case <-sigch:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		mountpoint := c.Args().Get(1)
+		err = mount.Mount(ctx, mountpoint, c.Args().Get(0), fsys)
+		if err != nil {
+			logger.Error("mount", zap.String("mountpoint", mountpoint), zap.Error(err))
+			return err
+		}
+		return nil
+	},
+}
@@ -10,7 +10,6 @@ import (
 
 	"go.beyondstorage.io/beyond-ctl/operations"
 	"go.beyondstorage.io/v5/services"
-	"go.beyondstorage.io/v5/types"
 )
 
 const (
@@ -41,7 +40,7 @@ var mvCmd = &cli.Command{
 	Name:      "mv",
 	Usage:     "move file from source storager to target storager",
 	UsageText: "byctl mv [command options] [source] [target]",
-	Flags:     mergeFlags(globalFlags, ioFlags, mvFlags),
+	Flags:     mergeFlags(globalFlags, ioFlags, multipartFlags, metadataFlags, mvFlags, []cli.Flag{flagPreserve}),
 	Before: func(c *cli.Context) error {
 		if args := c.Args().Len(); args < 2 {
 			return fmt.Errorf("mv command wants at least two args, but got %d", args)
@@ -57,42 +56,14 @@ var mvCmd = &cli.Command{
 			return err
 		}
 
-		// Handle read pairs.
-		var readPairs []types.Pair
-		if c.IsSet(flagReadSpeedLimitName) {
-			limitPair, err := parseLimit(c.String(flagReadSpeedLimitName))
-			if err != nil {
-				logger.Error("read limit is invalid",
-					zap.String("input", c.String(flagReadSpeedLimitName)),
-					zap.Error(err))
-				return err
-			}
-
-			readPairs = append(readPairs, limitPair)
-		}
-
-		// Handle write pairs.
-		var writePairs []types.Pair
-		if c.IsSet(flagWriteSpeedLimitName) {
-			limitPair, err := parseLimit(c.String(flagWriteSpeedLimitName))
-			if err != nil {
-				logger.Error("write limit is invalid",
-					zap.String("input", c.String(flagWriteSpeedLimitName)),
-					zap.Error(err))
-				return err
-			}
-
-			writePairs = append(writePairs, limitPair)
-		}
-
-		// parse flag multipart-threshold, 1GB is the default value
-		multipartThreshold, err := units.RAMInBytes(c.String(mvFlagMultipartThresholdName))
+		maxMemory, err := units.RAMInBytes(c.String(flagMaxMemoryName))
 		if err != nil {
-			logger.Error("multipart-threshold is invalid",
-				zap.String("input", c.String(mvFlagMultipartThresholdName)),
+			logger.Error("max-memory is invalid",
+				zap.String("input", c.String(flagMaxMemoryName)),
 				zap.Error(err))
 			return err
 		}
+		operations.SetMaxMemory(maxMemory)
 
 		args := c.Args().Len()
 
@@ -102,7 +73,7 @@ var mvCmd = &cli.Command{
 			return err
 		}
 
-		dst, err := services.NewStoragerFromString(dstConn)
+		dst, err := newStorager(cfg, c.Args().Get(args-1), dstConn)
 		if err != nil {
 			logger.Error("init dst storager", zap.Error(err), zap.String("conn string", dstConn))
 			return err
@@ -126,6 +97,32 @@ var mvCmd = &cli.Command{
 			}
 		}
 
+		// Handle write pairs, write options are picked from the target profile.
+		dstOpts := cfg.ParseProfileOptions(c.Args().Get(args - 1))
+		writePairs, err := parseWritePairs(c, cfg, c.Args().Get(args-1), dstConn)
+		if err != nil {
+			logger.Error("parse write pairs", zap.Error(err))
+			return err
+		}
+		metadataPairs, err := parseMetadataPairs(c, dstOpts, dstConn)
+		if err != nil {
+			logger.Error("parse metadata pairs", zap.Error(err))
+			return err
+		}
+
+		// parse flag multipart-threshold, 1GB is the default value
+		multipartThreshold, err := parseMultipartThreshold(c, mvFlagMultipartThresholdName, dstOpts)
+		if err != nil {
+			logger.Error("parse multipart-threshold", zap.Error(err))
+			return err
+		}
+
+		partSize, err := parsePartSizeOption(c, dstOpts)
+		if err != nil {
+			logger.Error("parse part-size", zap.Error(err))
+			return err
+		}
+
 		for i := 0; i < args-1; i++ {
 			srcConn, srcKey, err := cfg.ParseProfileInput(c.Args().Get(i))
 			if err != nil {
@@ -133,12 +130,20 @@ var mvCmd = &cli.Command{
 				continue
 			}
 
-			src, err := services.NewStoragerFromString(srcConn)
+			src, err := newStorager(cfg, c.Args().Get(i), srcConn)
 			if err != nil {
 				logger.Error("init src storager", zap.Error(err), zap.String("conn string", srcConn))
 				continue
 			}
 
+			// Handle read pairs, read options are picked from the source profile.
+			srcOpts := cfg.ParseProfileOptions(c.Args().Get(i))
+			readPairs, err := parseReadPairs(c, cfg, c.Args().Get(i), srcConn)
+			if err != nil {
+				logger.Error("parse read pairs", zap.Error(err))
+				continue
+			}
+
 			so := operations.NewSingleOperator(src)
 
 			srcObject, err := so.Stat(srcKey)
@@ -162,15 +167,26 @@ var mvCmd = &cli.Command{
 				size = n
 			}
 
+			preserve, err := parsePreserveOptions(c, src, srcConn, dst, dstConn)
+			if err != nil {
+				logger.Error("parse preserve", zap.Error(err))
+				continue
+			}
+
 			do := operations.NewDualOperator(src, dst)
-			if c.IsSet(flagWorkersName) {
-				do.WithWorkers(c.Int(flagWorkersName))
+			if workers, ok := workersOption(c, dstOpts, srcOpts); ok {
+				do.WithWorkers(workers)
 			}
+			do.WithPartSize(partSize)
+			do.WithPartConcurrency(intOption(c, flagPartConcurrencyName, dstOpts.PartConcurrency))
 
 			// set read pairs
 			do.WithReadPairs(readPairs...)
 			// set write pairs
 			do.WithWritePairs(writePairs...)
+			do.WithMetadataPairs(metadataPairs...)
+			do.WithContentTypeDetection(c.Bool(flagDetectContentTypeName))
+			do.WithPreserve(preserve)
 
 			realDstKey := dstKey
 			if args > 2 || (dstObject != nil && dstObject.Mode.IsDir()) {
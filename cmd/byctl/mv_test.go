@@ -123,3 +123,25 @@ func TestMvFile(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestMvFileViaPreserve(t *testing.T) {
+	if os.Getenv("BEYOND_CTL_INTEGRATION_TEST") != "on" {
+		t.Skipf("BEYOND_CTL_INTEGRATION_TEST is not 'on', skipped")
+	}
+
+	base, path := setupMvFile(t)
+	defer tearDownMv(t, base)
+
+	targetService, targetPath := setupMvTarget(t)
+	defer tearDownMv(t, targetService)
+
+	err := app.Run([]string{
+		"byctl", "mv",
+		"--preserve=content-type",
+		fmt.Sprintf("%s:%s", base, path),
+		fmt.Sprintf("%s:%s", targetService, targetPath),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
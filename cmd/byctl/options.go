@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/urfave/cli/v2"
+
+	"go.beyondstorage.io/beyond-ctl/config"
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/services/cos/v3"
+	"go.beyondstorage.io/services/gcs/v3"
+	"go.beyondstorage.io/services/oss/v3"
+	"go.beyondstorage.io/services/qingstor/v4"
+	"go.beyondstorage.io/services/s3/v3"
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/types"
+)
+
+// metadataPairFuncs contains the pair constructors of metadata supported by a
+// service, nil means the metadata is not supported.
+type metadataPairFuncs struct {
+	storageClass func(v string) types.Pair
+	cacheControl func(v string) types.Pair
+	userMetadata func(v map[string]string) types.Pair
+}
+
+// serviceMetadataPairs contains metadata pairs of services indexed by type.
+var serviceMetadataPairs = map[string]metadataPairFuncs{
+	"cos":      {storageClass: cos.WithStorageClass},
+	"gcs":      {storageClass: gcs.WithStorageClass},
+	"oss":      {storageClass: oss.WithStorageClass},
+	"qingstor": {storageClass: qingstor.WithStorageClass},
+	"s3":       {storageClass: s3.WithStorageClass},
+}
+
+// stringOption returns the value of flag name if it's set via flag or
+// environment variable, otherwise returns the profile option if not empty.
+//
+// The default value of flag will be returned if neither of them is set.
+func stringOption(c *cli.Context, name, profileValue string) string {
+	if c.IsSet(name) || profileValue == "" {
+		return c.String(name)
+	}
+	return profileValue
+}
+
+// intOption is the same as stringOption but for int flags, zero profile option
+// will be ignored.
+func intOption(c *cli.Context, name string, profileValue int) int {
+	if c.IsSet(name) || profileValue == 0 {
+		return c.Int(name)
+	}
+	return profileValue
+}
+
+// workersOption returns the workers number and whether it's specified via
+// flags or profile options. The first profile with workers set wins.
+func workersOption(c *cli.Context, opts ...config.ProfileOptions) (int, bool) {
+	if c.IsSet(flagWorkersName) {
+		return c.Int(flagWorkersName), true
+	}
+	for _, o := range opts {
+		if o.Workers > 0 {
+			return o.Workers, true
+		}
+	}
+	return 0, false
+}
+
+// defaultAdaptiveMaxWorkers is the max size of every adaptive pool if
+// --max-workers is not specified.
+const defaultAdaptiveMaxWorkers = 64
+
+// applyWorkerLimits applies the global cap and adaptive options of workers to
+// all operators in the process.
+func applyWorkerLimits(c *cli.Context) error {
+	max := c.Int(flagMaxWorkersName)
+	if max < 0 {
+		return fmt.Errorf("--%s must not be negative, but got %d", flagMaxWorkersName, max)
+	}
+	operations.SetMaxWorkers(max)
+
+	if !c.Bool(flagAdaptiveWorkersName) {
+		operations.SetAdaptiveWorkers(operations.AdaptiveOptions{})
+		return nil
+	}
+	if max == 0 {
+		max = defaultAdaptiveMaxWorkers
+	}
+	operations.SetAdaptiveWorkers(operations.AdaptiveOptions{Max: max})
+	return nil
+}
+
+// parseReadPairs builds read pairs from flags and options of source profile
+// picked via input.
+//
+// The total bandwidth limit is taken by the read side only, so that copies
+// will not be charged twice.
+func parseReadPairs(c *cli.Context, cfg *config.Config, input, conn string) ([]types.Pair, error) {
+	ps, err := parseBandwidthPairs(c, cfg, input, conn, true, flagReadSpeedLimitName,
+		func(opts config.ProfileOptions) string { return opts.ReadSpeedLimit })
+	if err != nil {
+		return nil, fmt.Errorf("read limit: %w", err)
+	}
+
+	return append(ps, stringPairs(cfg.ParseProfileOptions(input).ReadPairs)...), nil
+}
+
+// parseWritePairs builds write pairs from flags and options of target profile
+// picked via input.
+//
+// conn is the connection string of target storager, which is used to decide
+// the service specific pairs.
+func parseWritePairs(c *cli.Context, cfg *config.Config, input, conn string) ([]types.Pair, error) {
+	ps, err := parseBandwidthPairs(c, cfg, input, conn, false, flagWriteSpeedLimitName,
+		func(opts config.ProfileOptions) string { return opts.WriteSpeedLimit })
+	if err != nil {
+		return nil, fmt.Errorf("write limit: %w", err)
+	}
+
+	return append(ps, stringPairs(cfg.ParseProfileOptions(input).WritePairs)...), nil
+}
+
+// metadataOptions is the metadata of objects to write, which is specified via
+// metadata flags and options of target profile.
+type metadataOptions struct {
+	ContentType  string
+	StorageClass string
+	CacheControl string
+	UserMetadata map[string]string
+}
+
+// parseMetadataOptions parses metadata flags with storage class in target
+// profile as fallback.
+func parseMetadataOptions(c *cli.Context, opts config.ProfileOptions) (metadataOptions, error) {
+	m := metadataOptions{
+		ContentType:  c.String(flagContentTypeName),
+		StorageClass: stringOption(c, flagStorageClassName, opts.StorageClass),
+		CacheControl: c.String(flagCacheControlName),
+	}
+
+	for _, v := range c.StringSlice(flagMetaName) {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return m, fmt.Errorf("meta %s is invalid, want KEY=VALUE", v)
+		}
+		if m.UserMetadata == nil {
+			m.UserMetadata = make(map[string]string)
+		}
+		m.UserMetadata[kv[0]] = kv[1]
+	}
+	return m, nil
+}
+
+// pairs converts m into pairs, conn is the connection string of target
+// storager, which is used to decide the service specific pairs.
+func (m metadataOptions) pairs(conn string) ([]types.Pair, error) {
+	var ps []types.Pair
+	ty := serviceType(conn)
+
+	if m.ContentType != "" {
+		ps = append(ps, pairs.WithContentType(m.ContentType))
+	}
+	fns := serviceMetadataPairs[ty]
+	if m.StorageClass != "" {
+		if fns.storageClass == nil {
+			return nil, fmt.Errorf("storage class is not supported by service %s", ty)
+		}
+		ps = append(ps, fns.storageClass(m.StorageClass))
+	}
+	if m.CacheControl != "" {
+		if fns.cacheControl == nil {
+			return nil, fmt.Errorf("cache control is not supported by service %s", ty)
+		}
+		ps = append(ps, fns.cacheControl(m.CacheControl))
+	}
+	if len(m.UserMetadata) > 0 {
+		if fns.userMetadata == nil {
+			return nil, fmt.Errorf("user metadata is not supported by service %s", ty)
+		}
+		ps = append(ps, fns.userMetadata(m.UserMetadata))
+	}
+	return ps, nil
+}
+
+// parseMetadataPairs builds metadata pairs from flags and options of target
+// profile.
+func parseMetadataPairs(c *cli.Context, opts config.ProfileOptions, conn string) ([]types.Pair, error) {
+	m, err := parseMetadataOptions(c, opts)
+	if err != nil {
+		return nil, err
+	}
+	return m.pairs(conn)
+}
+
+// Attributes could be preserved via flag preserve.
+const (
+	preserveMode        = "mode"
+	preserveTimestamps  = "timestamps"
+	preserveContentType = "content-type"
+	preserveUserMeta    = "user-meta"
+)
+
+// parsePreserveOptions parses flag preserve into options of copying from src
+// to dst, srcConn and dstConn are their connection strings.
+//
+// Mode and timestamps could only be applied to local files, so dst must be
+// fs. Mode is read from local files too, so src must be fs for mode.
+func parsePreserveOptions(
+	c *cli.Context, src types.Storager, srcConn string, dst types.Storager, dstConn string,
+) (operations.PreserveOptions, error) {
+	var p operations.PreserveOptions
+	text := c.String(flagPreserveName)
+	if text == "" {
+		return p, nil
+	}
+
+	p.SrcDir = localWorkDir(src, srcConn)
+	p.DstDir = localWorkDir(dst, dstConn)
+	for _, attr := range strings.Split(text, ",") {
+		switch strings.TrimSpace(attr) {
+		case preserveMode:
+			if p.SrcDir == "" || p.DstDir == "" {
+				return p, fmt.Errorf("mode could only be preserved between fs")
+			}
+			p.Mode = true
+		case preserveTimestamps:
+			if p.DstDir == "" {
+				return p, fmt.Errorf("timestamps could only be preserved into fs, but target service is %s",
+					serviceType(dstConn))
+			}
+			p.Timestamps = true
+		case preserveContentType:
+			p.ContentType = true
+		case preserveUserMeta:
+			ty := serviceType(dstConn)
+			fn := serviceMetadataPairs[ty].userMetadata
+			if fn == nil {
+				return p, fmt.Errorf("user metadata is not supported by service %s", ty)
+			}
+			p.UserMetadataPair = fn(nil).Key
+		default:
+			return p, fmt.Errorf("preserve attribute %s is not supported", attr)
+		}
+	}
+	return p, nil
+}
+
+// localWorkDir returns the work dir of store if it's fs, otherwise returns
+// empty string.
+func localWorkDir(store types.Storager, conn string) string {
+	if serviceType(conn) != "fs" {
+		return ""
+	}
+	return store.Metadata().WorkDir
+}
+
+// parseMultipartThreshold parses flag name with multipart threshold in target
+// profile as fallback.
+//
+// The threshold is parsed via units.FromHumanSize as cp always did, so 1GB
+// means 10^9 bytes instead of 2^30 bytes.
+func parseMultipartThreshold(c *cli.Context, name string, opts config.ProfileOptions) (int64, error) {
+	text := stringOption(c, name, opts.MultipartThreshold)
+	threshold, err := units.FromHumanSize(text)
+	if err != nil {
+		return 0, fmt.Errorf("multipart-threshold %s is invalid: %w", text, err)
+	}
+	return threshold, nil
+}
+
+// parsePartSizeOption parses flag part-size with part size in target profile
+// as fallback.
+func parsePartSizeOption(c *cli.Context, opts config.ProfileOptions) (int64, error) {
+	text := stringOption(c, flagPartSizeName, opts.PartSize)
+	partSize, err := parsePartSize(text)
+	if err != nil {
+		return 0, fmt.Errorf("part-size %s is invalid: %w", text, err)
+	}
+	return partSize, nil
+}
+
+// parseExpire parses the expire duration of signed URL, which could be either
+// the number of seconds or a duration like `1h30m`.
+func parseExpire(text string) (time.Duration, error) {
+	if second, err := strconv.Atoi(text); err == nil {
+		return time.Duration(second) * time.Second, nil
+	}
+	return time.ParseDuration(text)
+}
+
+// stringPairs converts m into pairs sorted by key.
+func stringPairs(m map[string]string) []types.Pair {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ps := make([]types.Pair, 0, len(keys))
+	for _, k := range keys {
+		ps = append(ps, types.Pair{Key: k, Value: m[k]})
+	}
+	return ps
+}
+
+// serviceType returns the service type of connection string like `s3`.
+func serviceType(conn string) string {
+	if idx := strings.Index(conn, ":"); idx != -1 {
+		return conn[:idx]
+	}
+	return conn
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+
+	"go.beyondstorage.io/beyond-ctl/config"
+	"go.beyondstorage.io/services/s3/v3"
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/types"
+)
+
+func TestParseMultipartThreshold(t *testing.T) {
+	cases := []struct {
+		name    string
+		flag    string
+		profile string
+		expect  int64
+		hasErr  bool
+	}{
+		{"default", "", "", 1000 * 1000 * 1000, false},
+		{"flag", "1GB", "", 1000 * 1000 * 1000, false},
+		{"profile", "", "100MB", 100 * 1000 * 1000, false},
+		{"flag over profile", "1MB", "100MB", 1000 * 1000, false},
+		{"invalid", "abc", "", 0, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flag.NewFlagSet("test", flag.ContinueOnError)
+			set.String(cpFlagMultipartThresholdName, "1GiB", "")
+			if tt.flag != "" {
+				if err := set.Set(cpFlagMultipartThresholdName, tt.flag); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c := cli.NewContext(cli.NewApp(), set, nil)
+
+			threshold, err := parseMultipartThreshold(c, cpFlagMultipartThresholdName,
+				config.ProfileOptions{MultipartThreshold: tt.profile})
+			if tt.hasErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, threshold)
+		})
+	}
+}
+
+func TestMetadataOptionsPairs(t *testing.T) {
+	cases := []struct {
+		name   string
+		m      metadataOptions
+		conn   string
+		expect []types.Pair
+		hasErr bool
+	}{
+		{
+			name:   "content type",
+			m:      metadataOptions{ContentType: "text/plain"},
+			conn:   "fs:///tmp",
+			expect: []types.Pair{pairs.WithContentType("text/plain")},
+		},
+		{
+			name:   "storage class",
+			m:      metadataOptions{StorageClass: "STANDARD_IA"},
+			conn:   "s3://bucket",
+			expect: []types.Pair{s3.WithStorageClass("STANDARD_IA")},
+		},
+		{
+			name:   "storage class not supported",
+			m:      metadataOptions{StorageClass: "STANDARD_IA"},
+			conn:   "fs:///tmp",
+			hasErr: true,
+		},
+		{
+			name:   "user metadata not supported",
+			m:      metadataOptions{UserMetadata: map[string]string{"a": "b"}},
+			conn:   "s3://bucket",
+			hasErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		ps, err := tt.m.pairs(tt.conn)
+		if tt.hasErr {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.expect, ps, tt.name)
+	}
+}
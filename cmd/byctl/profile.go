@@ -10,6 +10,8 @@ import (
 	"go.uber.org/zap"
 
 	"go.beyondstorage.io/beyond-ctl/config"
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/v5/services"
 )
 
 var profileCmd = &cli.Command{
@@ -19,15 +21,78 @@ var profileCmd = &cli.Command{
 		profileAddCmd,
 		profileListCmd,
 		profileRemoveCmd,
+		profileUpdateCmd,
+		profileRenameCmd,
+		profileShowCmd,
+		profileTestCmd,
+		profileEncryptCmd,
+		profileDecryptCmd,
+	},
+}
+
+const (
+	profileFlagService           = "service"
+	profileFlagName              = "name"
+	profileFlagWorkDir           = "work-dir"
+	profileFlagEndpoint          = "endpoint"
+	profileFlagLocation          = "location"
+	profileFlagCredentialType    = "credential-protocol"
+	profileFlagCredentialEnv     = "credential-env"
+	profileFlagCredentialFile    = "credential-file"
+	profileFlagCredentialCommand = "credential-command"
+)
+
+var profileAddFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  profileFlagService,
+		Usage: "service type of the profile, like s3, gcs and so on",
+	},
+	&cli.StringFlag{
+		Name:  profileFlagName,
+		Usage: "storage name of the profile, like bucket name",
+	},
+	&cli.StringFlag{
+		Name:  profileFlagWorkDir,
+		Usage: "work dir of the profile",
+	},
+	&cli.StringFlag{
+		Name:  profileFlagEndpoint,
+		Usage: "endpoint of the service",
+	},
+	&cli.StringFlag{
+		Name:  profileFlagLocation,
+		Usage: "location of the storage",
+	},
+	&cli.StringFlag{
+		Name:  profileFlagCredentialType,
+		Usage: "credential protocol, like hmac, apikey and so on",
+	},
+	&cli.StringFlag{
+		Name:  profileFlagCredentialEnv,
+		Usage: "read credential value from environment variable `NAME`",
+	},
+	&cli.StringFlag{
+		Name:  profileFlagCredentialFile,
+		Usage: "read credential value from `FILE`",
+	},
+	&cli.StringFlag{
+		Name:  profileFlagCredentialCommand,
+		Usage: "read credential value from the output of credential helper `COMMAND`",
 	},
 }
 
 var profileAddCmd = &cli.Command{
-	Name:  "add",
-	Usage: "add profile [name] [connection_string]",
+	Name:      "add",
+	Usage:     "add profile [name] [connection_string]",
+	UsageText: "byctl profile add [command options] [name] [connection_string]",
+	Flags:     profileAddFlags,
 	Before: func(ctx *cli.Context) error {
-		if args := ctx.Args().Len(); args < 2 {
-			return fmt.Errorf("add command wants two args, but got %d", args)
+		args := ctx.Args().Len()
+		if args < 1 {
+			return fmt.Errorf("add command wants at least one arg, but got %d", args)
+		}
+		if args < 2 && !ctx.IsSet(profileFlagService) {
+			return fmt.Errorf("add command wants connection string or --%s", profileFlagService)
 		}
 		return nil
 	},
@@ -40,16 +105,22 @@ var profileAddCmd = &cli.Command{
 			return err
 		}
 
-		name, connStr := c.Args().Get(0), c.Args().Get(1)
-		err = cfg.AddProfile(name, config.Profile{
-			Connection: connStr,
+		name := c.Args().Get(0)
+		prof, err := parseProfileFlags(c, config.Profile{
+			Connection: c.Args().Get(1),
 		})
+		if err != nil {
+			logger.Error("parse profile", zap.Error(err))
+			return err
+		}
+
+		err = cfg.AddProfile(name, prof)
 		if err != nil {
 			logger.Error("add profile", zap.Error(err))
 			return err
 		}
 
-		if err := cfg.WriteToFile(c.String(flagConfigName)); err != nil {
+		if err := writeConfig(c, cfg); err != nil {
 			logger.Error("write to file", zap.Error(err))
 			return err
 		}
@@ -57,6 +128,54 @@ var profileAddCmd = &cli.Command{
 	},
 }
 
+// parseProfileFlags updates prof with profile related flags.
+func parseProfileFlags(c *cli.Context, prof config.Profile) (config.Profile, error) {
+	if c.IsSet(profileFlagService) {
+		prof.Service = c.String(profileFlagService)
+	}
+	if c.IsSet(profileFlagName) {
+		prof.Name = c.String(profileFlagName)
+	}
+	if c.IsSet(profileFlagWorkDir) {
+		prof.WorkDir = c.String(profileFlagWorkDir)
+	}
+	if c.IsSet(profileFlagEndpoint) {
+		prof.Endpoint = c.String(profileFlagEndpoint)
+	}
+	if c.IsSet(profileFlagLocation) {
+		prof.Location = c.String(profileFlagLocation)
+	}
+
+	var sources int
+	cred := &config.Credential{
+		Protocol: c.String(profileFlagCredentialType),
+	}
+	if c.IsSet(profileFlagCredentialEnv) {
+		cred.Env = c.String(profileFlagCredentialEnv)
+		sources++
+	}
+	if c.IsSet(profileFlagCredentialFile) {
+		cred.File = c.String(profileFlagCredentialFile)
+		sources++
+	}
+	if c.IsSet(profileFlagCredentialCommand) {
+		cred.Command = c.String(profileFlagCredentialCommand)
+		sources++
+	}
+	if sources > 1 {
+		return prof, fmt.Errorf("only one of --%s, --%s and --%s could be set",
+			profileFlagCredentialEnv, profileFlagCredentialFile, profileFlagCredentialCommand)
+	}
+	if sources > 0 || cred.Protocol != "" {
+		if cred.Protocol == "" {
+			return prof, fmt.Errorf("--%s is required for credential", profileFlagCredentialType)
+		}
+		prof.Credential = cred
+	}
+
+	return prof, nil
+}
+
 var profileRemoveCmd = &cli.Command{
 	Name:  "remove",
 	Usage: "remove profile [name]",
@@ -77,7 +196,7 @@ var profileRemoveCmd = &cli.Command{
 
 		cfg.RemoveProfile(c.Args().First())
 
-		if err := cfg.WriteToFile(c.String(flagConfigName)); err != nil {
+		if err := writeConfig(c, cfg); err != nil {
 			logger.Error("write to file", zap.Error(err))
 			return err
 		}
@@ -104,10 +223,16 @@ var profileListCmd = &cli.Command{
 			return err
 		}
 
+		// Secrets should never be displayed.
+		profiles := make(map[string]config.Profile, len(cfg.Profiles))
+		for name, prof := range cfg.Profiles {
+			profiles[name] = prof.Masked()
+		}
+
 		if c.Bool("json") {
-			err = json.NewEncoder(os.Stdout).Encode(cfg.Profiles)
+			err = json.NewEncoder(os.Stdout).Encode(profiles)
 		} else {
-			err = toml.NewEncoder(os.Stdout).Encode(cfg.Profiles)
+			err = toml.NewEncoder(os.Stdout).Encode(profiles)
 		}
 
 		if err != nil {
@@ -117,3 +242,256 @@ var profileListCmd = &cli.Command{
 		return nil
 	},
 }
+
+var profileUpdateCmd = &cli.Command{
+	Name:      "update",
+	Usage:     "update profile [name] [connection_string]",
+	UsageText: "byctl profile update [command options] [name] [connection_string]",
+	Flags:     profileAddFlags,
+	Before: func(ctx *cli.Context) error {
+		if args := ctx.Args().Len(); args < 1 {
+			return fmt.Errorf("update command wants at least one arg, but got %d", args)
+		}
+		return nil
+	},
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, false)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		name := c.Args().Get(0)
+		prof, ok := cfg.GetProfile(name)
+		if !ok {
+			err = fmt.Errorf("profile with name %s not exist", name)
+			logger.Error("get profile", zap.Error(err))
+			return err
+		}
+
+		if c.Args().Len() > 1 {
+			prof.Connection = c.Args().Get(1)
+		}
+		prof, err = parseProfileFlags(c, prof)
+		if err != nil {
+			logger.Error("parse profile", zap.Error(err))
+			return err
+		}
+
+		err = cfg.UpdateProfile(name, prof)
+		if err != nil {
+			logger.Error("update profile", zap.Error(err))
+			return err
+		}
+
+		if err := writeConfig(c, cfg); err != nil {
+			logger.Error("write to file", zap.Error(err))
+			return err
+		}
+		return nil
+	},
+}
+
+var profileRenameCmd = &cli.Command{
+	Name:  "rename",
+	Usage: "rename profile [old_name] [new_name]",
+	Before: func(ctx *cli.Context) error {
+		if args := ctx.Args().Len(); args < 2 {
+			return fmt.Errorf("rename command wants two args, but got %d", args)
+		}
+		return nil
+	},
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, false)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		err = cfg.RenameProfile(c.Args().Get(0), c.Args().Get(1))
+		if err != nil {
+			logger.Error("rename profile", zap.Error(err))
+			return err
+		}
+
+		if err := writeConfig(c, cfg); err != nil {
+			logger.Error("write to file", zap.Error(err))
+			return err
+		}
+		return nil
+	},
+}
+
+var profileShowCmd = &cli.Command{
+	Name:  "show",
+	Usage: "show profile [name] with secrets masked",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "show profile in json format",
+			Value: false,
+		},
+	},
+	Before: func(ctx *cli.Context) error {
+		if args := ctx.Args().Len(); args < 1 {
+			return fmt.Errorf("show command wants one arg, but got %d", args)
+		}
+		return nil
+	},
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, false)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		name := c.Args().First()
+		prof, ok := cfg.GetProfile(name)
+		if !ok {
+			err = fmt.Errorf("profile with name %s not exist", name)
+			logger.Error("get profile", zap.Error(err))
+			return err
+		}
+
+		if c.Bool("json") {
+			err = json.NewEncoder(os.Stdout).Encode(prof.Masked())
+		} else {
+			err = toml.NewEncoder(os.Stdout).Encode(prof.Masked())
+		}
+		if err != nil {
+			logger.Error("encode profile", zap.Error(err))
+			return err
+		}
+		return nil
+	},
+}
+
+var profileTestCmd = &cli.Command{
+	Name:      "test",
+	Usage:     "test connectivity and permissions of profile [name]",
+	UsageText: "byctl profile test [name]",
+	Before: func(ctx *cli.Context) error {
+		if args := ctx.Args().Len(); args < 1 {
+			return fmt.Errorf("test command wants one arg, but got %d", args)
+		}
+		return nil
+	},
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, true)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		name := c.Args().First()
+		prof, ok := cfg.GetProfile(name)
+		if !ok {
+			err = fmt.Errorf("profile with name %s not exist", name)
+			logger.Error("get profile", zap.Error(err))
+			return err
+		}
+
+		conn, err := prof.ConnectionString()
+		if err != nil {
+			logger.Error("build connection string", zap.Error(err))
+			return err
+		}
+
+		store, err := services.NewStoragerFromString(conn)
+		if err != nil {
+			fmt.Printf("init: failed: %s\n", err)
+			return err
+		}
+		fmt.Println("init: ok")
+
+		so := operations.NewSingleOperator(store)
+		for _, r := range so.CheckStorager("") {
+			if r.Error != nil {
+				fmt.Printf("%s: failed (%s): %s\n", r.Operation, r.Duration, r.Error)
+				return r.Error
+			}
+			fmt.Printf("%s: ok (%s) %s\n", r.Operation, r.Duration, r.Path)
+		}
+		return nil
+	},
+}
+
+var profileEncryptCmd = &cli.Command{
+	Name:  "encrypt",
+	Usage: "encrypt secrets in config file via password or key file",
+	Description: fmt.Sprintf("Password will be read from %s, or key file will be read from the path in %s.",
+		config.EnvConfigPassword, config.EnvConfigKeyFile),
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		secret, ok, err := config.LoadSecretFromEnv()
+		if err != nil {
+			logger.Error("load secret", zap.Error(err))
+			return err
+		}
+		if !ok {
+			err = fmt.Errorf("neither %s nor %s is set", config.EnvConfigPassword, config.EnvConfigKeyFile)
+			logger.Error("load secret", zap.Error(err))
+			return err
+		}
+
+		cfg, err := loadConfig(c, false)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		err = cfg.Encrypt(secret)
+		if err != nil {
+			logger.Error("encrypt config", zap.Error(err))
+			return err
+		}
+
+		if err := writeConfig(c, cfg); err != nil {
+			logger.Error("write to file", zap.Error(err))
+			return err
+		}
+		return nil
+	},
+}
+
+var profileDecryptCmd = &cli.Command{
+	Name:  "decrypt",
+	Usage: "decrypt secrets in config file and store them in plain text",
+	Description: fmt.Sprintf("Password will be read from %s, or key file will be read from the path in %s.",
+		config.EnvConfigPassword, config.EnvConfigKeyFile),
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, false)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		if !cfg.IsEncrypted() {
+			fmt.Println("config is not encrypted")
+			return nil
+		}
+
+		err = cfg.Decrypt()
+		if err != nil {
+			logger.Error("decrypt config", zap.Error(err))
+			return err
+		}
+
+		if err := writeConfig(c, cfg); err != nil {
+			logger.Error("write to file", zap.Error(err))
+			return err
+		}
+		return nil
+	},
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/urfave/cli/v2"
+
+	"go.beyondstorage.io/beyond-ctl/config"
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/v5/types"
+)
+
+// requestLimits are the max requests per second of storagers. The flag takes
+// precedence over quotas of profiles, which are indexed by profile name.
+var requestLimits = struct {
+	sync.Mutex
+	flag     float64
+	profiles map[string]float64
+	// stores are the profiles used by storagers.
+	stores map[types.Storager]string
+}{
+	profiles: make(map[string]float64),
+	stores:   make(map[types.Storager]string),
+}
+
+// applyRequestLimits applies --max-rps to all storagers, including the ones
+// initialized before, for example, in shell mode.
+func applyRequestLimits(c *cli.Context) error {
+	rps := c.Float64(flagMaxRPSName)
+	if rps < 0 {
+		return fmt.Errorf("--%s must not be negative, but got %v", flagMaxRPSName, rps)
+	}
+
+	requestLimits.Lock()
+	requestLimits.flag = rps
+	requestLimits.Unlock()
+
+	storagers.Lock()
+	defer storagers.Unlock()
+	for _, store := range storagers.m {
+		limitRequests(store)
+	}
+	return nil
+}
+
+// registerRequestQuota records the request quota of the profile used by input,
+// which will be applied to store. Nothing is recorded if input doesn't use a
+// profile.
+func registerRequestQuota(cfg *config.Config, input string, store types.Storager) {
+	name := cfg.ParseProfileName(input)
+	if name == "" {
+		return
+	}
+	rps := cfg.ParseProfileOptions(input).MaxRPS
+
+	requestLimits.Lock()
+	defer requestLimits.Unlock()
+	requestLimits.profiles[name] = rps
+	requestLimits.stores[store] = name
+}
+
+// limitRequests limits requests sent to store via all operators.
+func limitRequests(store types.Storager) {
+	requestLimits.Lock()
+	rps := requestLimits.flag
+	if rps == 0 {
+		rps = requestLimits.profiles[requestLimits.stores[store]]
+	}
+	requestLimits.Unlock()
+
+	operations.SetRequestLimit(store, rps)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.beyondstorage.io/beyond-ctl/config"
+	"go.beyondstorage.io/v5/types"
+)
+
+type quotaStorager struct {
+	types.Storager
+}
+
+func TestRegisterRequestQuota(t *testing.T) {
+	cfg := config.New()
+	_ = cfg.AddProfile("limited", config.Profile{
+		Connection: "s3://bucket-name",
+		Options:    &config.ProfileOptions{MaxRPS: 5},
+	})
+	_ = cfg.AddProfile("other", config.Profile{
+		Connection: "s3://bucket-name",
+		Options:    &config.ProfileOptions{MaxRPS: 10},
+	})
+
+	store := &quotaStorager{}
+	registerRequestQuota(cfg, "limited:dir/object", store)
+	registerRequestQuota(cfg, "/path/to/file", &quotaStorager{})
+
+	requestLimits.Lock()
+	defer requestLimits.Unlock()
+	// Only the quota of the profile used is registered, indexed by name.
+	assert.Equal(t, float64(5), requestLimits.profiles["limited"])
+	assert.NotContains(t, requestLimits.profiles, "other")
+	assert.Equal(t, "limited", requestLimits.stores[store])
+	assert.Len(t, requestLimits.stores, 1)
+}
@@ -58,13 +58,16 @@ var rmCmd = &cli.Command{
 				continue
 			}
 
-			store, err := services.NewStoragerFromString(conn)
+			store, err := newStorager(cfg, c.Args().Get(i), conn)
 			if err != nil {
 				logger.Error("init src storager", zap.Error(err), zap.String("conn string", conn))
 				continue
 			}
 
 			so := operations.NewSingleOperator(store)
+			if workers, ok := workersOption(c, cfg.ParseProfileOptions(c.Args().Get(i))); ok {
+				so.WithWorkers(workers)
+			}
 
 			if c.Bool(rmFlagMultipart) && !c.Bool(rmFlagRecursive) {
 				// Remove all multipart objects whose path is `key`
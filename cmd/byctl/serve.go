@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/beyond-ctl/serve"
+	"go.beyondstorage.io/v5/types"
+)
+
+const (
+	serveFlagAddr                   = "addr"
+	serveFlagReadOnly               = "read-only"
+	serveFlagUser                   = "user"
+	serveFlagPassword               = "password"
+	serveFlagMultipartThresholdName = "multipart-threshold"
+	serveFlagBucket                 = "bucket"
+	serveFlagAccessKey              = "access-key"
+	serveFlagSecretKey              = "secret-key"
+	serveFlagRegion                 = "region"
+
+	// serveShutdownTimeout is the max duration to wait for requests in
+	// progress while shutting down.
+	serveShutdownTimeout = 10 * time.Second
+)
+
+var serveFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  serveFlagAddr,
+		Usage: "address to listen on, use :8080 to listen on all interfaces",
+		Value: "127.0.0.1:8080",
+	},
+}
+
+var serveFlagMultipartThreshold = &cli.StringFlag{
+	Name:  serveFlagMultipartThresholdName,
+	Usage: "Specify multipart threshold. If uploaded or moved file size is larger than this value, byctl will use multipart method.",
+	EnvVars: []string{
+		"BEYOND_CTL_MULTIPART_THRESHOLD",
+	},
+	Value: "1GiB", // Use 1 GiB as the default value.
+}
+
+var serveWebDAVFlags = []cli.Flag{
+	&cli.BoolFlag{
+		Name:  serveFlagReadOnly,
+		Usage: "reject all requests that modify objects",
+	},
+	&cli.StringFlag{
+		Name:  serveFlagUser,
+		Usage: "basic auth user that requests modifying objects must be signed with",
+		EnvVars: []string{
+			"BEYOND_CTL_WEBDAV_USER",
+		},
+	},
+	&cli.StringFlag{
+		Name:  serveFlagPassword,
+		Usage: "basic auth password that requests modifying objects must be signed with",
+		EnvVars: []string{
+			"BEYOND_CTL_WEBDAV_PASSWORD",
+		},
+	},
+	serveFlagMultipartThreshold,
+}
+
+var serveS3Flags = []cli.Flag{
+	serveFlagMultipartThreshold,
+	&cli.StringFlag{
+		Name:  serveFlagBucket,
+		Usage: "name of the bucket, the profile name will be used if not set",
+	},
+	&cli.StringFlag{
+		Name:  serveFlagAccessKey,
+		Usage: "access key that requests must be signed with",
+		EnvVars: []string{
+			"BEYOND_CTL_S3_ACCESS_KEY",
+		},
+	},
+	&cli.StringFlag{
+		Name:  serveFlagSecretKey,
+		Usage: "secret key that requests must be signed with",
+		EnvVars: []string{
+			"BEYOND_CTL_S3_SECRET_KEY",
+		},
+	},
+	&cli.StringFlag{
+		Name:  serveFlagRegion,
+		Usage: "region returned by GetBucketLocation",
+		Value: "us-east-1",
+	},
+}
+
+var serveCmd = &cli.Command{
+	Name:  "serve",
+	Usage: "serve storager over http, webdav or s3 compatible api",
+	Subcommands: []*cli.Command{
+		serveHTTPCmd,
+		serveWebDAVCmd,
+		serveS3Cmd,
+	},
+}
+
+var serveHTTPCmd = &cli.Command{
+	Name:      "http",
+	Usage:     "serve files over http with directory index pages",
+	UsageText: "byctl serve http [command options] [source]",
+	Flags:     mergeFlags(globalFlags, serveFlags),
+	Before: func(c *cli.Context) error {
+		if args := c.Args().Len(); args != 1 {
+			return fmt.Errorf("serve http command wants one args, but got %d", args)
+		}
+		return nil
+	},
+	Action: func(c *cli.Context) error {
+		return runServe(c, false, func(t *serveTarget) http.Handler {
+			return serve.New(t.store, t.so, t.do, t.key, serve.Options{})
+		})
+	},
+}
+
+var serveWebDAVCmd = &cli.Command{
+	Name:      "webdav",
+	Usage:     "serve files over webdav",
+	UsageText: "byctl serve webdav [command options] [source]",
+	Flags:     mergeFlags(globalFlags, multipartFlags, serveFlags, serveWebDAVFlags),
+	Before: func(c *cli.Context) error {
+		if args := c.Args().Len(); args != 1 {
+			return fmt.Errorf("serve webdav command wants one args, but got %d", args)
+		}
+		// Requests modifying objects are sent with credentials of the
+		// profile, so they must be authenticated.
+		if !c.Bool(serveFlagReadOnly) && (c.String(serveFlagUser) == "" || c.String(serveFlagPassword) == "") {
+			return fmt.Errorf("serve webdav command requires %s and %s unless %s is set",
+				serveFlagUser, serveFlagPassword, serveFlagReadOnly)
+		}
+		return nil
+	},
+	Action: func(c *cli.Context) error {
+		return runServe(c, true, func(t *serveTarget) http.Handler {
+			return serve.New(t.store, t.so, t.do, t.key, serve.Options{
+				WebDAV:             true,
+				ReadOnly:           c.Bool(serveFlagReadOnly),
+				Username:           c.String(serveFlagUser),
+				Password:           c.String(serveFlagPassword),
+				MultipartThreshold: t.multipartThreshold,
+			})
+		})
+	},
+}
+
+var serveS3Cmd = &cli.Command{
+	Name:      "s3",
+	Usage:     "serve files via s3 compatible api",
+	UsageText: "byctl serve s3 [command options] [source]",
+	Description: `The source is exposed as the only bucket, and only path-style requests
+   like /bucket/key signed with AWS Signature Version 4 are supported.`,
+	Flags: mergeFlags(globalFlags, multipartFlags, serveFlags, serveS3Flags),
+	Before: func(c *cli.Context) error {
+		if args := c.Args().Len(); args != 1 {
+			return fmt.Errorf("serve s3 command wants one args, but got %d", args)
+		}
+		if c.String(serveFlagAccessKey) == "" || c.String(serveFlagSecretKey) == "" {
+			return fmt.Errorf("serve s3 command requires %s and %s", serveFlagAccessKey, serveFlagSecretKey)
+		}
+		return nil
+	},
+	Action: func(c *cli.Context) error {
+		bucket := c.String(serveFlagBucket)
+		if bucket == "" {
+			bucket = defaultBucketName(c.Args().First())
+		}
+
+		return runServe(c, true, func(t *serveTarget) http.Handler {
+			return serve.NewS3(t.store, t.so, t.key, serve.S3Options{
+				Bucket:             bucket,
+				AccessKey:          c.String(serveFlagAccessKey),
+				SecretKey:          c.String(serveFlagSecretKey),
+				Region:             c.String(serveFlagRegion),
+				MultipartThreshold: t.multipartThreshold,
+			})
+		})
+	},
+}
+
+// serveTarget is the storager to serve.
+type serveTarget struct {
+	store types.Storager
+	key   string
+	so    *operations.SingleOperator
+	do    *operations.DualOperator
+
+	multipartThreshold int64
+}
+
+// runServe serves the storager in the first arg with handler created by
+// newHandler until SIGINT or SIGTERM is received. Multipart options will be
+// parsed if writable is true.
+func runServe(c *cli.Context, writable bool, newHandler func(t *serveTarget) http.Handler) error {
+	logger, _ := zap.NewDevelopment()
+
+	cfg, err := loadConfig(c, true)
+	if err != nil {
+		logger.Error("load config", zap.Error(err))
+		return err
+	}
+
+	conn, key, err := cfg.ParseProfileInput(c.Args().First())
+	if err != nil {
+		logger.Error("parse profile input", zap.Error(err))
+		return err
+	}
+
+	store, err := newStorager(cfg, c.Args().First(), conn)
+	if err != nil {
+		logger.Error("init storager", zap.Error(err), zap.String("conn string", conn))
+		return err
+	}
+
+	t := &serveTarget{
+		store: store,
+		key:   key,
+		so:    operations.NewSingleOperator(store),
+		do:    operations.NewDualOperator(store, store),
+	}
+
+	if writable {
+		profileOpts := cfg.ParseProfileOptions(c.Args().First())
+		t.multipartThreshold, err = parseMultipartThreshold(c, serveFlagMultipartThresholdName, profileOpts)
+		if err != nil {
+			logger.Error("parse multipart-threshold", zap.Error(err))
+			return err
+		}
+		partSize, err := parsePartSizeOption(c, profileOpts)
+		if err != nil {
+			logger.Error("parse part-size", zap.Error(err))
+			return err
+		}
+		maxMemory, err := units.RAMInBytes(c.String(flagMaxMemoryName))
+		if err != nil {
+			logger.Error("max-memory is invalid",
+				zap.String("input", c.String(flagMaxMemoryName)),
+				zap.Error(err))
+			return err
+		}
+		operations.SetMaxMemory(maxMemory)
+		partConcurrency := intOption(c, flagPartConcurrencyName, profileOpts.PartConcurrency)
+
+		t.so.WithPartSize(partSize).WithPartConcurrency(partConcurrency)
+		t.do.WithPartSize(partSize).WithPartConcurrency(partConcurrency)
+		if workers, ok := workersOption(c, profileOpts); ok {
+			t.so.WithWorkers(workers)
+			t.do.WithWorkers(workers)
+		}
+	}
+
+	server := &http.Server{
+		Addr:    c.String(serveFlagAddr),
+		Handler: newHandler(t),
+	}
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigch)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, ok := <-sigch; !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error("shutdown server", zap.Error(err))
+		}
+	}()
+
+	fmt.Printf("Serving %s on %s\n", c.Args().First(), server.Addr)
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("serve", zap.String("addr", server.Addr), zap.Error(err))
+		return err
+	}
+	<-done
+	return nil
+}
+
+// defaultBucketName returns the profile name in input if it's a valid bucket
+// name, `byctl` otherwise.
+func defaultBucketName(input string) string {
+	idx := strings.Index(input, ":")
+	if idx < 3 || idx > 63 || strings.HasPrefix(input[idx:], "://") {
+		return "byctl"
+	}
+	name := input[:idx]
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return "byctl"
+		}
+	}
+	return name
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
+)
+
+const (
+	setmetaFlagRecursive = "recursive"
+)
+
+var setmetaFlags = []cli.Flag{
+	&cli.BoolFlag{
+		Name: setmetaFlagRecursive,
+		Aliases: []string{
+			"r",
+			"R",
+		},
+		Usage: "set metadata of all files under the directory recursively",
+	},
+}
+
+var setmetaCmd = &cli.Command{
+	Name:      "setmeta",
+	Usage:     "set metadata of files in place",
+	UsageText: "byctl setmeta [command options] [target]",
+	Description: `Metadata will be rewritten by copying files to themselves, so the storage
+   must support copy. The content type and user metadata not specified will
+   be kept, and the specified user metadata will be merged into them.
+
+   Target could be a glob pattern like profile:dir/*.html to set metadata of
+   all matched files.`,
+	Flags: mergeFlags(globalFlags, metadataFlags, setmetaFlags),
+	Before: func(c *cli.Context) error {
+		if args := c.Args().Len(); args < 1 {
+			return fmt.Errorf("setmeta command wants at least one args, but got %d", args)
+		}
+
+		for _, name := range []string{
+			flagContentTypeName, flagDetectContentTypeName, flagMetaName,
+			flagStorageClassName, flagCacheControlName,
+		} {
+			if c.IsSet(name) {
+				return nil
+			}
+		}
+		return fmt.Errorf("setmeta command wants at least one metadata flag")
+	},
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, true)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		for i := 0; i < c.Args().Len(); i++ {
+			input := c.Args().Get(i)
+			conn, key, err := cfg.ParseProfileInput(input)
+			if err != nil {
+				logger.Error("parse profile input from target", zap.Error(err))
+				continue
+			}
+
+			store, err := newStorager(cfg, input, conn)
+			if err != nil {
+				logger.Error("init target storager", zap.Error(err), zap.String("conn string", conn))
+				continue
+			}
+
+			meta, err := parseMetadataOptions(c, cfg.ParseProfileOptions(input))
+			if err != nil {
+				logger.Error("parse metadata", zap.Error(err))
+				continue
+			}
+
+			so := operations.NewSingleOperator(store)
+
+			paths := []string{key}
+			if hasGlob(key) || c.Bool(setmetaFlagRecursive) {
+				paths, err = expandPaths(so, key, c.Bool(setmetaFlagRecursive))
+				if err != nil {
+					logger.Error("list", zap.String("path", key), zap.Error(err))
+					continue
+				}
+			}
+
+			for _, p := range paths {
+				o, err := so.Stat(p)
+				if err != nil {
+					logger.Error("stat", zap.String("path", p), zap.Error(err))
+					continue
+				}
+				if o.Mode.IsDir() {
+					fmt.Printf("setmeta: -r not specified; omitting directory '%s'\n", p)
+					continue
+				}
+
+				// Keep the metadata not specified via flags.
+				m := meta
+				if m.ContentType == "" {
+					if v, ok := o.GetContentType(); ok {
+						m.ContentType = v
+					} else if c.Bool(flagDetectContentTypeName) {
+						m.ContentType = operations.DetectContentType(p, nil)
+					}
+				}
+				if v, ok := o.GetUserMetadata(); ok && len(v) > 0 {
+					m.UserMetadata = make(map[string]string, len(v)+len(meta.UserMetadata))
+					for k, v := range v {
+						m.UserMetadata[k] = v
+					}
+					for k, v := range meta.UserMetadata {
+						m.UserMetadata[k] = v
+					}
+				}
+
+				ps, err := m.pairs(conn)
+				if err != nil {
+					logger.Error("parse metadata pairs", zap.Error(err))
+					break
+				}
+
+				err = so.SetMetadata(p, ps...)
+				if err != nil {
+					logger.Error("set metadata", zap.String("path", p), zap.Error(err))
+					continue
+				}
+			}
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/config"
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/v5/services"
+)
+
+// maxCompletions is the max number of objects listed for path completion.
+const maxCompletions = 1000
+
+// shellCommands are commands that will be passed to byctl commands with path
+// args resolved from the current working directory.
+var shellCommands = []string{"cat", "cp", "ls", "mv", "rm", "sign", "stat"}
+
+// shellFlagNames are global flags of shell that will be passed to every byctl
+// command. Limits are process wide, they are applied by shell once and not
+// passed.
+var shellFlagNames = []string{flagWorkersName}
+
+// shellBuiltins are commands implemented by shell itself.
+var shellBuiltins = []string{"cd", "exit", "get", "help", "put", "pwd", "quit"}
+
+var shellCmd = &cli.Command{
+	Name:      "shell",
+	Usage:     "start an interactive shell on the storager",
+	UsageText: "byctl shell [command options] [profile:[dir]]",
+	Flags:     mergeFlags(globalFlags),
+	Action: func(c *cli.Context) error {
+		logger, _ := zap.NewDevelopment()
+
+		cfg, err := loadConfig(c, true)
+		if err != nil {
+			logger.Error("load config", zap.Error(err))
+			return err
+		}
+
+		sh, err := newShell(c, cfg, c.Args().First())
+		if err != nil {
+			logger.Error("init shell", zap.Error(err))
+			return err
+		}
+		return sh.run()
+	},
+}
+
+type shell struct {
+	app *cli.App
+	ctx *cli.Context
+	cfg *config.Config
+	// globalArgs will be passed to every byctl command.
+	globalArgs []string
+	// historyFile is the file to persist command history.
+	historyFile string
+
+	// profile is the name of current profile, empty means local fs.
+	profile string
+	// cwd is the current working directory which always ends with `/`.
+	// For profiles, cwd is relative to the work dir of profile and starts
+	// with `/`, for local fs, cwd is an absolute path.
+	cwd string
+}
+
+func newShell(c *cli.Context, cfg *config.Config, input string) (*shell, error) {
+	configPath := c.String(flagConfigName)
+	sh := &shell{
+		app:         c.App,
+		ctx:         c,
+		cfg:         cfg,
+		globalArgs:  []string{"--" + flagConfigName, configPath},
+		historyFile: filepath.Join(filepath.Dir(configPath), "shell_history"),
+	}
+	// Commands running in shell use the config loaded by shell.
+	sessionConfig = cfg
+	for _, name := range shellFlagNames {
+		if c.IsSet(name) {
+			sh.globalArgs = append(sh.globalArgs, fmt.Sprintf("--%s=%v", name, c.Value(name)))
+		}
+	}
+
+	if strings.Contains(input, "://") {
+		return nil, fmt.Errorf("connection string is not supported in shell, please add a profile instead")
+	}
+
+	if idx := strings.Index(input, ":"); idx != -1 && !isWindowsLocalPath(input) {
+		sh.profile = input[:idx]
+		if _, ok := cfg.GetProfile(sh.profile); !ok {
+			return nil, fmt.Errorf("profile with name %s not exist", sh.profile)
+		}
+		sh.cwd = "/"
+		return sh, sh.chdir(input[idx+1:])
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	sh.cwd = withSeparator(wd, string(filepath.Separator))
+	return sh, sh.chdir(input)
+}
+
+func (sh *shell) run() error {
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          sh.prompt(),
+		HistoryFile:     sh.historyFile,
+		AutoComplete:    sh,
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+	})
+	if err != nil {
+		return err
+	}
+	defer rl.Close()
+
+	for {
+		line, err := rl.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		args, err := config.SplitArgs(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "byctl: %v\n", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "exit" || args[0] == "quit" {
+			return nil
+		}
+
+		if err = sh.exec(args[0], args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		}
+		rl.SetPrompt(sh.prompt())
+	}
+}
+
+// exec executes a single command in shell.
+func (sh *shell) exec(name string, args []string) error {
+	switch name {
+	case "help":
+		fmt.Printf("builtin commands: %s\n", strings.Join(shellBuiltins, ", "))
+		fmt.Printf("byctl commands: %s\n", strings.Join(shellCommands, ", "))
+		fmt.Println("  put <local> [remote]  upload local file into current dir")
+		fmt.Println("  get <remote> [local]  download file into local dir")
+		return nil
+	case "pwd":
+		fmt.Println(sh.pwd())
+		return nil
+	case "cd":
+		if len(args) > 1 {
+			return fmt.Errorf("too many args")
+		}
+		dir := ""
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		return sh.chdir(dir)
+	case "put", "get":
+		return sh.transfer(name, args)
+	}
+
+	for _, v := range shellCommands {
+		if v == name {
+			flags, paths := sh.splitArgs(name, args)
+			for i := range paths {
+				paths[i] = sh.resolve(paths[i])
+			}
+			return sh.runCommand(name, flags, paths)
+		}
+	}
+	return fmt.Errorf("command not found, try help")
+}
+
+// transfer handles put and get via the cp command.
+func (sh *shell) transfer(name string, args []string) error {
+	flags, paths := sh.splitArgs("cp", args)
+	if len(paths) == 0 || len(paths) > 2 {
+		return fmt.Errorf("%s wants one or two args, but got %d", name, len(paths))
+	}
+
+	if name == "put" {
+		local, err := sh.localPath(paths[0])
+		if err != nil {
+			return err
+		}
+		remote := filepath.Base(local)
+		if len(paths) == 2 {
+			remote = paths[1]
+		}
+		return sh.runCommand("cp", flags, []string{local, sh.resolve(remote)})
+	}
+
+	remote := sh.resolve(paths[0])
+	local := path.Base(strings.TrimSuffix(paths[0], "/"))
+	if len(paths) == 2 {
+		local = paths[1]
+	}
+	local, err := sh.localPath(local)
+	if err != nil {
+		return err
+	}
+	return sh.runCommand("cp", flags, []string{remote, local})
+}
+
+// runCommand runs the action of byctl command in shell, so that config loaded
+// by shell and storagers initialized before will be reused.
+func (sh *shell) runCommand(name string, flags, paths []string) error {
+	cmd := sh.app.Command(name)
+	if cmd == nil {
+		return fmt.Errorf("command not found, try help")
+	}
+
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	for _, f := range cmd.Flags {
+		if err := f.Apply(set); err != nil {
+			return err
+		}
+	}
+
+	args := make([]string, 0, len(sh.globalArgs)+len(flags)+len(paths)+1)
+	// Global flags are defined by every command too, so they must be passed
+	// to the command, otherwise the defaults of the command will be used.
+	args = append(args, sh.globalArgs...)
+	args = append(args, flags...)
+	// Paths may start with `-`.
+	args = append(args, "--")
+	args = append(args, paths...)
+	err := set.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return cli.ShowCommandHelp(sh.ctx, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	c := cli.NewContext(sh.app, set, sh.ctx)
+	c.Command = cmd
+	if cmd.Before != nil {
+		if err = cmd.Before(c); err != nil {
+			return err
+		}
+	}
+	return cmd.Action(c)
+}
+
+// splitArgs splits args of command name into flags and paths, so that flags
+// could be placed before paths.
+func (sh *shell) splitArgs(name string, args []string) (flags, paths []string) {
+	var valueFlags map[string]bool
+	if cmd := sh.app.Command(name); cmd != nil {
+		valueFlags = valueFlagNames(cmd.Flags)
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			paths = append(paths, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			paths = append(paths, arg)
+			continue
+		}
+
+		flags = append(flags, arg)
+		if !strings.Contains(arg, "=") && valueFlags[strings.TrimLeft(arg, "-")] && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	return
+}
+
+// resolve resolves p from the current working directory into the input of
+// byctl commands.
+//
+// p with profile like `prod:dir/file` will be returned as is.
+func (sh *shell) resolve(p string) string {
+	if strings.Contains(p, ":") && !(sh.profile == "" && isWindowsLocalPath(p)) {
+		return p
+	}
+
+	if sh.profile == "" {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(sh.cwd, p)
+		}
+		return p
+	}
+
+	full := p
+	if !strings.HasPrefix(p, "/") {
+		full = sh.cwd + p
+	}
+	full = path.Clean(full)
+	// Keep the trailing `/` so that dirs will not be treated as prefix.
+	base := path.Base(p)
+	if strings.HasSuffix(p, "/") || p == "" || base == "." || base == ".." {
+		full = withSeparator(full, "/")
+	}
+	return sh.profile + ":" + strings.TrimPrefix(full, "/")
+}
+
+// chdir changes the current working directory into dir, empty dir means the
+// root of profile or the current dir of local fs.
+func (sh *shell) chdir(dir string) error {
+	if dir == "" {
+		if sh.profile != "" {
+			sh.cwd = "/"
+		}
+		return nil
+	}
+
+	if sh.profile == "" {
+		p := sh.resolve(dir)
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		sh.cwd = withSeparator(filepath.Clean(p), string(filepath.Separator))
+		return nil
+	}
+
+	input := sh.resolve(withSeparator(dir, "/"))
+	if !strings.HasPrefix(input, sh.profile+":") {
+		return fmt.Errorf("changing profile is not supported, please start another shell")
+	}
+
+	// Dirs may not exist as objects in object storage, so we only check that
+	// the target is not a file.
+	conn, key, err := sh.cfg.ParseProfileInput(strings.TrimSuffix(input, "/"))
+	if err != nil {
+		return err
+	}
+	store, err := newStorager(sh.cfg, input, conn)
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		o, err := operations.NewSingleOperator(store).Stat(key)
+		if err == nil && !o.Mode.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		if err != nil && !errors.Is(err, services.ErrObjectNotExist) {
+			return err
+		}
+	}
+
+	sh.cwd = "/" + strings.TrimPrefix(input, sh.profile+":")
+	return nil
+}
+
+// localBase returns the base dir of relative local paths, which is cwd in
+// local fs and the current dir of byctl otherwise.
+func (sh *shell) localBase() string {
+	if sh.profile == "" {
+		return sh.cwd
+	}
+	return ""
+}
+
+// localPath returns the absolute path of local path p.
+func (sh *shell) localPath(p string) (string, error) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(sh.localBase(), p)
+	}
+	return filepath.Abs(p)
+}
+
+func (sh *shell) pwd() string {
+	if sh.profile == "" {
+		return sh.cwd
+	}
+	return sh.profile + ":" + strings.TrimPrefix(sh.cwd, "/")
+}
+
+func (sh *shell) prompt() string {
+	return fmt.Sprintf("byctl %s> ", sh.pwd())
+}
+
+// Do implements readline.AutoCompleter.
+func (sh *shell) Do(line []rune, pos int) ([][]rune, int) {
+	text := string(line[:pos])
+	words, err := config.SplitArgs(text)
+	if err != nil {
+		return nil, 0
+	}
+	// Start a new word if the text ends with space.
+	if len(words) == 0 || strings.HasSuffix(text, " ") {
+		words = append(words, "")
+	}
+	word := words[len(words)-1]
+
+	var candidates []string
+	if len(words) == 1 {
+		candidates = append(candidates, shellBuiltins...)
+		candidates = append(candidates, shellCommands...)
+		candidates = filterPrefix(candidates, word)
+	} else if !strings.HasPrefix(word, "-") {
+		// The first arg of put and the second arg of get are local paths.
+		_, paths := sh.splitArgs(words[0], words[1:])
+		local := sh.profile == "" ||
+			(words[0] == "put" && len(paths) == 1) ||
+			(words[0] == "get" && len(paths) == 2)
+		candidates = sh.completePath(word, local)
+	}
+
+	out := make([][]rune, 0, len(candidates))
+	for _, v := range candidates {
+		out = append(out, []rune(strings.TrimPrefix(v, word)))
+	}
+	return out, len([]rune(word))
+}
+
+// completePath returns paths that start with word.
+//
+// Storagers are kept warm in shell, so remote keys will not be cached.
+func (sh *shell) completePath(word string, local bool) []string {
+	if strings.Contains(word, ":") && !isWindowsLocalPath(word) {
+		return nil
+	}
+	if local {
+		return completeLocalPath(sh.localBase(), word)
+	}
+
+	dir := ""
+	if idx := strings.LastIndex(word, "/"); idx != -1 {
+		dir = word[:idx+1]
+	}
+	conn, key, err := sh.cfg.ParseProfileInput(sh.resolve(dir))
+	if err != nil {
+		return nil
+	}
+	names, err := listNames(conn, key)
+	if err != nil {
+		return nil
+	}
+
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, dir+name)
+	}
+	return filterPrefix(paths, word)
+}
+
+// completeLocalPath returns local paths that start with word, relative paths
+// are based on base or the current dir if base is empty.
+func completeLocalPath(base, word string) []string {
+	dir := ""
+	if idx := strings.LastIndexAny(word, "/"+string(filepath.Separator)); idx != -1 {
+		dir = word[:idx+1]
+	}
+
+	p := dir
+	if !filepath.IsAbs(p) {
+		if base == "" {
+			wd, err := os.Getwd()
+			if err != nil {
+				return nil
+			}
+			base = wd
+		}
+		p = filepath.Join(base, p)
+	}
+	fis, err := ioutil.ReadDir(p)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, fi := range fis {
+		name := dir + fi.Name()
+		if fi.IsDir() {
+			name += string(filepath.Separator)
+		}
+		names = append(names, name)
+	}
+	return filterPrefix(names, word)
+}
+
+// filterPrefix returns sorted items in xs that start with prefix.
+func filterPrefix(xs []string, prefix string) []string {
+	var out []string
+	for _, x := range xs {
+		if strings.HasPrefix(x, prefix) {
+			out = append(out, x)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// isWindowsLocalPath checks whether p is a Windows-like local path like
+// `C:\path` while running on Windows.
+func isWindowsLocalPath(p string) bool {
+	return filepath.VolumeName(p) != ""
+}
+
+// withSeparator appends sep to p if p doesn't end with it.
+func withSeparator(p, sep string) string {
+	if strings.HasSuffix(p, sep) {
+		return p
+	}
+	return p + sep
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+
+	"go.beyondstorage.io/beyond-ctl/config"
+)
+
+func TestShellRunCommandWithGlobalFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "byctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, "config.toml")
+
+	var gotConfig string
+	var gotWorkers int
+	var gotCfg *config.Config
+	var gotArgs []string
+	before := 0
+	a := &cli.App{
+		Name:  "byctl",
+		Flags: mergeFlags(globalFlags),
+		Commands: []*cli.Command{
+			{
+				Name:  "probe",
+				Flags: mergeFlags(globalFlags),
+				Before: func(c *cli.Context) error {
+					before++
+					return nil
+				},
+				Action: func(c *cli.Context) error {
+					gotConfig = c.String(flagConfigName)
+					gotWorkers = c.Int(flagWorkersName)
+					gotArgs = c.Args().Slice()
+					gotCfg, err = loadConfig(c, true)
+					return err
+				},
+			},
+		},
+	}
+
+	set := flag.NewFlagSet("shell", flag.ContinueOnError)
+	for _, f := range globalFlags {
+		if err = f.Apply(set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = set.Parse([]string{
+		"--" + flagConfigName, configPath, "--" + flagWorkersName, "7",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.New()
+	sh, err := newShell(cli.NewContext(a, set, nil), cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { sessionConfig = nil }()
+
+	err = sh.runCommand("probe", nil, []string{"-a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, configPath, gotConfig)
+	assert.Equal(t, 7, gotWorkers)
+	assert.Equal(t, []string{"-a", "b"}, gotArgs)
+	assert.Equal(t, 1, before)
+	// Config loaded by shell should be reused.
+	assert.True(t, cfg == gotCfg)
+
+	err = sh.runCommand("probe", []string{"--" + flagWorkersName, "3"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, gotWorkers)
+
+	err = sh.runCommand("probe", []string{"--not-exist"}, nil)
+	assert.NotNil(t, err)
+}
@@ -1,25 +1,68 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
-	"time"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
 
+	"github.com/docker/go-units"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
 
 	"go.beyondstorage.io/beyond-ctl/operations"
-	"go.beyondstorage.io/v5/services"
 )
 
 const (
-	signFlagExpire = "expire"
+	signFlagExpire    = "expire"
+	signFlagMethod    = "method"
+	signFlagSize      = "size"
+	signFlagMultipart = "multipart"
+	signFlagRecursive = "recursive"
+	signFlagFormat    = "format"
+
+	signFormatText = "text"
+	signFormatJSON = "json"
+	signFormatCSV  = "csv"
+	signFormatCurl = "curl"
+	signFormatHTTP = "http"
 )
 
 var signFlags = []cli.Flag{
-	&cli.IntFlag{
+	&cli.StringFlag{
 		Name:  signFlagExpire,
-		Usage: "the number of seconds until the signed URL expires",
-		Value: 300,
+		Usage: "the number of seconds or the duration like 2h until the signed URL expires",
+		Value: "300",
+	},
+	&cli.StringFlag{
+		Name:  signFlagMethod,
+		Usage: "the method of signed URL, could be read, write or delete",
+		Value: operations.SignMethodRead,
+	},
+	&cli.StringFlag{
+		Name:  signFlagSize,
+		Usage: "the size of the file to upload, required by write method",
+	},
+	&cli.BoolFlag{
+		Name:  signFlagMultipart,
+		Usage: "create a multipart object and sign the URLs to upload its parts, only valid for write method",
+	},
+	&cli.BoolFlag{
+		Name: signFlagRecursive,
+		Aliases: []string{
+			"r",
+			"R",
+		},
+		Usage: "sign all files under the directory recursively",
+	},
+	&cli.StringFlag{
+		Name:  signFlagFormat,
+		Usage: "output format, could be text, json, csv, curl or http",
+		Value: signFormatText,
 	},
 }
 
@@ -27,11 +70,35 @@ var signCmd = &cli.Command{
 	Name:      "sign",
 	Usage:     "get the signed URL by the source",
 	UsageText: "byctl sign [command options] [source]",
-	Flags:     mergeFlags(globalFlags, signFlags),
+	Description: `Source could be a glob pattern like profile:dir/*.txt to sign all matched
+   files, patterns are matched against paths via path.Match.`,
+	Flags: mergeFlags(globalFlags, signFlags, []cli.Flag{flagPartSize}),
 	Before: func(c *cli.Context) error {
 		if args := c.Args().Len(); args < 1 {
 			return fmt.Errorf("sign command wants at least one args, but got %d", args)
 		}
+
+		switch c.String(signFlagMethod) {
+		case operations.SignMethodRead, operations.SignMethodDelete:
+			if c.Bool(signFlagMultipart) {
+				return fmt.Errorf("--%s is only valid for write method", signFlagMultipart)
+			}
+		case operations.SignMethodWrite:
+			if !c.IsSet(signFlagSize) {
+				return fmt.Errorf("write method requires --%s", signFlagSize)
+			}
+			if c.Bool(signFlagRecursive) {
+				return fmt.Errorf("write method can't be used with --%s", signFlagRecursive)
+			}
+		default:
+			return fmt.Errorf("sign method %s is not supported", c.String(signFlagMethod))
+		}
+
+		switch c.String(signFlagFormat) {
+		case signFormatText, signFormatJSON, signFormatCSV, signFormatCurl, signFormatHTTP:
+		default:
+			return fmt.Errorf("sign format %s is not supported", c.String(signFlagFormat))
+		}
 		return nil
 	},
 	Action: func(c *cli.Context) error {
@@ -43,44 +110,262 @@ var signCmd = &cli.Command{
 			return err
 		}
 
-		isFirst := true
+		method := c.String(signFlagMethod)
+		var size int64
+		if method == operations.SignMethodWrite {
+			size, err = units.RAMInBytes(c.String(signFlagSize))
+			if err != nil {
+				logger.Error("size is invalid",
+					zap.String("input", c.String(signFlagSize)),
+					zap.Error(err))
+				return err
+			}
+		}
+
+		var records []signRecord
+		// failed is the number of sources or paths failed to sign, they
+		// are logged and skipped, so that others could still be signed.
+		failed := 0
 		args := c.Args().Len()
+		// Print paths before URLs if there are multiple paths.
+		labeled := args > 1
 		for i := 0; i < args; i++ {
-			conn, key, err := cfg.ParseProfileInput(c.Args().Get(i))
+			input := c.Args().Get(i)
+			conn, key, err := cfg.ParseProfileInput(input)
 			if err != nil {
 				logger.Error("parse profile input from source", zap.Error(err))
+				failed++
 				continue
 			}
 
-			store, err := services.NewStoragerFromString(conn)
+			store, err := newStorager(cfg, input, conn)
 			if err != nil {
 				logger.Error("init source storager", zap.Error(err), zap.String("conn string", conn))
+				failed++
 				continue
 			}
 
 			so := operations.NewSingleOperator(store)
+			opts := cfg.ParseProfileOptions(input)
 
-			// The default is 300 second.
-			second := c.Int(signFlagExpire)
-			expire := time.Duration(second) * time.Second
-
-			url, err := so.Sign(key, expire)
+			// The default is 300 second, and could be overridden by profile.
+			expireText := c.String(signFlagExpire)
+			if !c.IsSet(signFlagExpire) && opts.SignExpire != "" {
+				expireText = opts.SignExpire
+			}
+			expire, err := parseExpire(expireText)
 			if err != nil {
-				logger.Error("run sign", zap.Error(err))
+				logger.Error("sign expire is invalid",
+					zap.String("input", expireText),
+					zap.Error(err))
+				failed++
+				continue
+			}
+
+			if c.Bool(signFlagMultipart) {
+				partSize, err := parsePartSizeOption(c, opts)
+				if err != nil {
+					logger.Error("parse part-size", zap.Error(err))
+					failed++
+					continue
+				}
+				so.WithPartSize(partSize)
+
+				sm, err := so.SignMultipart(key, size, expire)
+				if err != nil {
+					logger.Error("run sign multipart", zap.String("path", key), zap.Error(err))
+					failed++
+					continue
+				}
+				rs, err := multipartRecords(input, method, sm)
+				if err != nil {
+					logger.Error("read signed request", zap.String("path", key), zap.Error(err))
+					failed++
+					continue
+				}
+				records = append(records, rs...)
 				continue
 			}
 
-			if args > 1 {
-				if isFirst {
-					isFirst = false
-				} else {
-					fmt.Printf("\n")
+			paths := []string{key}
+			expanded := false
+			if method != operations.SignMethodWrite && (hasGlob(key) || c.Bool(signFlagRecursive)) {
+				paths, err = expandPaths(so, key, c.Bool(signFlagRecursive))
+				if err != nil {
+					logger.Error("list", zap.String("path", key), zap.Error(err))
+					failed++
+					continue
+				}
+				expanded = true
+				labeled = true
+			}
+
+			for _, p := range paths {
+				req, err := so.SignHTTP(method, p, size, expire)
+				if err != nil {
+					logger.Error("run sign", zap.String("path", p), zap.Error(err))
+					failed++
+					continue
+				}
+
+				name := input
+				if expanded {
+					name = displayPath(input, key, p)
+				}
+				r, err := newSignRecord(name, method, req, size)
+				if err != nil {
+					logger.Error("read signed request", zap.String("path", p), zap.Error(err))
+					failed++
+					continue
 				}
-				fmt.Printf("%s:\n", c.Args().Get(i))
+				records = append(records, r)
 			}
-			fmt.Println(url)
 		}
 
+		if err = printSignRecords(c.String(signFlagFormat), records, labeled); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("failed to sign %d of the paths", failed)
+		}
 		return nil
 	},
 }
+
+// signRecord is a signed request of path.
+type signRecord struct {
+	Path   string `json:"path"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Part is the index of part for multipart object, or `complete` for the
+	// URL to complete it.
+	Part        string `json:"part,omitempty"`
+	MultipartID string `json:"multipart_id,omitempty"`
+
+	// HTTPMethod and Header are required to send the signed request besides
+	// the URL.
+	HTTPMethod string      `json:"http_method"`
+	Header     http.Header `json:"header,omitempty"`
+	// Size is the expected size of the request body.
+	Size int64 `json:"size"`
+	// Offset is the offset of the part body in the file to upload.
+	Offset int64 `json:"offset,omitempty"`
+	// Body is the signed request body, like the part list to complete a
+	// multipart object.
+	Body string `json:"body,omitempty"`
+}
+
+const signPartComplete = "complete"
+
+// newSignRecord creates the record of req, size is the expected body size
+// if req doesn't carry the body itself.
+func newSignRecord(path, method string, req *http.Request, size int64) (signRecord, error) {
+	r := signRecord{
+		Path:       path,
+		Method:     method,
+		URL:        req.URL.String(),
+		HTTPMethod: req.Method,
+		Header:     req.Header,
+		Size:       size,
+	}
+	if r.HTTPMethod == "" {
+		r.HTTPMethod = http.MethodGet
+	}
+	if len(r.Header) == 0 {
+		r.Header = nil
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		defer req.Body.Close()
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return r, err
+		}
+		r.Body = string(body)
+		r.Size = int64(len(body))
+	}
+	return r, nil
+}
+
+func multipartRecords(input, method string, sm *operations.SignedMultipart) ([]signRecord, error) {
+	records := make([]signRecord, 0, len(sm.Parts)+1)
+	for _, p := range sm.Parts {
+		r, err := newSignRecord(input, method, p.Request, p.Size)
+		if err != nil {
+			return nil, err
+		}
+		r.Part = strconv.Itoa(p.Index)
+		r.MultipartID = sm.MultipartID
+		r.Offset = p.Offset
+		records = append(records, r)
+	}
+
+	r, err := newSignRecord(input, method, sm.Complete, 0)
+	if err != nil {
+		return nil, err
+	}
+	r.Part = signPartComplete
+	r.MultipartID = sm.MultipartID
+	records = append(records, r)
+	return records, nil
+}
+
+// printSignRecords prints records in format. In text and curl format, URLs
+// will be grouped by path, and path will be printed before URLs if labeled is
+// true.
+func printSignRecords(format string, records []signRecord, labeled bool) error {
+	switch format {
+	case signFormatJSON:
+		if records == nil {
+			records = []signRecord{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(records)
+	case signFormatCSV:
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write([]string{"path", "method", "part", "multipart_id", "url"})
+		for _, r := range records {
+			_ = w.Write([]string{r.Path, r.Method, r.Part, r.MultipartID, r.URL})
+		}
+		w.Flush()
+		return w.Error()
+	case signFormatCurl:
+		printCurlRecords(records, labeled)
+		return nil
+	case signFormatHTTP:
+		return printHTTPRecords(os.Stdout, records)
+	}
+
+	for i, r := range records {
+		first := i == 0 || records[i-1].Path != r.Path
+		if first && labeled {
+			if i > 0 {
+				fmt.Printf("\n")
+			}
+			fmt.Printf("%s:\n", r.Path)
+		}
+
+		if r.MultipartID == "" {
+			fmt.Println(r.URL)
+			continue
+		}
+		if first {
+			fmt.Printf("multipart id: %s\n", r.MultipartID)
+		}
+		if r.Part == signPartComplete {
+			fmt.Printf("complete: %s\n", r.URL)
+		} else {
+			fmt.Printf("part %s: %s\n", r.Part, r.URL)
+		}
+	}
+	return nil
+}
+
+// displayPath returns the path of object p in the same style as input whose
+// key is key, for example, `profile:dir/file`.
+func displayPath(input, key, p string) string {
+	if strings.HasSuffix(input, key) {
+		return input[:len(input)-len(key)] + p
+	}
+	return p
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
+)
+
+// printCurlRecords prints records as curl commands, so that they could be
+// sent by users without credentials of the storage.
+func printCurlRecords(records []signRecord, labeled bool) {
+	for i, r := range records {
+		first := i == 0 || records[i-1].Path != r.Path
+		if first {
+			if i > 0 {
+				fmt.Printf("\n")
+			}
+			if labeled {
+				fmt.Printf("# %s\n", r.Path)
+			}
+			if r.MultipartID != "" {
+				fmt.Printf("# multipart id: %s\n", r.MultipartID)
+			}
+		}
+		fmt.Println(curlCommand(r))
+	}
+}
+
+// curlCommand returns the curl command to send the signed request in r. The
+// body will be read from the local file which has the same name as the path.
+func curlCommand(r signRecord) string {
+	name := shellQuote(localName(r.Path))
+
+	args := []string{"curl", "-X", r.HTTPMethod}
+	for _, k := range headerKeys(r.Header) {
+		for _, v := range r.Header[k] {
+			args = append(args, "-H", shellQuote(k+": "+v))
+		}
+	}
+
+	prefix := ""
+	switch {
+	case r.Body != "":
+		args = append(args, dataBinaryArgs(r.Header, shellQuote(r.Body))...)
+	case r.Part != "":
+		// Cut the part from the file, curl will send it with Content-Length
+		// as the signed request requires.
+		prefix = fmt.Sprintf("tail -c +%d %s | head -c %d | ", r.Offset+1, name, r.Size)
+		args = append(args, dataBinaryArgs(r.Header, "@-")...)
+	case r.Method == operations.SignMethodWrite:
+		args = append(args, "--upload-file", name)
+	case r.Method == operations.SignMethodRead:
+		args = append(args, "-o", name)
+	}
+	args = append(args, shellQuote(r.URL))
+
+	return prefix + strings.Join(args, " ")
+}
+
+// dataBinaryArgs returns args to send data, curl will add a form Content-Type
+// for --data-binary which should be removed if it's not signed.
+func dataBinaryArgs(header http.Header, data string) []string {
+	if header.Get("Content-Type") != "" {
+		return []string{"--data-binary", data}
+	}
+	return []string{"-H", "'Content-Type:'", "--data-binary", data}
+}
+
+// printHTTPRecords prints records into w as raw HTTP requests separated by
+// `###`, which could be used by HTTP clients like REST Client of VS Code.
+func printHTTPRecords(w io.Writer, records []signRecord) error {
+	for i, r := range records {
+		if i > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+
+		title := r.Path
+		switch {
+		case r.Part == signPartComplete:
+			title += fmt.Sprintf(" complete multipart %s", r.MultipartID)
+		case r.Part != "":
+			title += fmt.Sprintf(" part %s of multipart %s (bytes %d-%d)",
+				r.Part, r.MultipartID, r.Offset, r.Offset+r.Size-1)
+		}
+		fmt.Fprintf(w, "### %s\n", title)
+
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return fmt.Errorf("parse signed url %s: %w", r.URL, err)
+		}
+		fmt.Fprintf(w, "%s %s HTTP/1.1\n", r.HTTPMethod, u.RequestURI())
+		fmt.Fprintf(w, "Host: %s\n", u.Host)
+		for _, k := range headerKeys(r.Header) {
+			for _, v := range r.Header[k] {
+				fmt.Fprintf(w, "%s: %s\n", k, v)
+			}
+		}
+		if r.Size > 0 || r.Method == operations.SignMethodWrite {
+			fmt.Fprintf(w, "Content-Length: %d\n", r.Size)
+		}
+
+		switch {
+		case r.Body != "":
+			fmt.Fprintf(w, "\n%s\n", r.Body)
+		case r.Part == "" && r.Method == operations.SignMethodWrite:
+			fmt.Fprintf(w, "\n< ./%s\n", localName(r.Path))
+		}
+	}
+	return nil
+}
+
+// headerKeys returns the sorted keys of header except Host and
+// Content-Length, which are set by clients.
+func headerKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		switch http.CanonicalHeaderKey(k) {
+		case "Host", "Content-Length":
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// localName returns the name of the local file for path like `profile:dir/file`.
+func localName(p string) string {
+	if idx := strings.Index(p, ":"); idx != -1 {
+		p = p[idx+1:]
+	}
+	return path.Base(p)
+}
+
+// shellQuote quotes s in single quotes for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
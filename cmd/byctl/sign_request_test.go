@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
+)
+
+func TestCurlCommand(t *testing.T) {
+	cases := []struct {
+		name   string
+		record signRecord
+		expect string
+	}{
+		{
+			"read",
+			signRecord{
+				Path: "p:dir/a.txt", Method: operations.SignMethodRead,
+				URL: "https://example.com/dir/a.txt?sig=x&e=1", HTTPMethod: http.MethodGet,
+			},
+			`curl -X GET -o 'a.txt' 'https://example.com/dir/a.txt?sig=x&e=1'`,
+		},
+		{
+			"write with headers",
+			signRecord{
+				Path: "p:a.txt", Method: operations.SignMethodWrite,
+				URL: "https://example.com/a.txt", HTTPMethod: http.MethodPut,
+				Header: http.Header{
+					"X-Amz-Date":   {"20210101T000000Z"},
+					"Content-Type": {"text/plain"},
+					"Host":         {"example.com"},
+				},
+				Size: 10,
+			},
+			`curl -X PUT -H 'Content-Type: text/plain' -H 'X-Amz-Date: 20210101T000000Z' --upload-file 'a.txt' 'https://example.com/a.txt'`,
+		},
+		{
+			"part",
+			signRecord{
+				Path: "p:a.bin", Method: operations.SignMethodWrite,
+				URL: "https://example.com/a.bin?part=2", HTTPMethod: http.MethodPut,
+				Part: "1", MultipartID: "mid", Size: 5, Offset: 10,
+			},
+			`tail -c +11 'a.bin' | head -c 5 | curl -X PUT -H 'Content-Type:' --data-binary @- 'https://example.com/a.bin?part=2'`,
+		},
+		{
+			"complete with body",
+			signRecord{
+				Path: "p:a.bin", Method: operations.SignMethodWrite,
+				URL: "https://example.com/a.bin?complete", HTTPMethod: http.MethodPost,
+				Header: http.Header{"Content-Type": {"application/xml"}},
+				Part:   signPartComplete, MultipartID: "mid", Body: "<a/>", Size: 4,
+			},
+			`curl -X POST -H 'Content-Type: application/xml' --data-binary '<a/>' 'https://example.com/a.bin?complete'`,
+		},
+		{
+			"delete",
+			signRecord{
+				Path: "p:a.txt", Method: operations.SignMethodDelete,
+				URL: "https://example.com/a.txt", HTTPMethod: http.MethodDelete,
+			},
+			`curl -X DELETE 'https://example.com/a.txt'`,
+		},
+		{
+			"quote",
+			signRecord{
+				Path: "p:it's.txt", Method: operations.SignMethodRead,
+				URL: "https://example.com/it's.txt", HTTPMethod: http.MethodGet,
+			},
+			`curl -X GET -o 'it'\''s.txt' 'https://example.com/it'\''s.txt'`,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, curlCommand(tt.record))
+		})
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `''`, shellQuote(""))
+	assert.Equal(t, `'a b'`, shellQuote("a b"))
+	assert.Equal(t, `'a'\''b'`, shellQuote("a'b"))
+	assert.Equal(t, `'$HOME "x"'`, shellQuote(`$HOME "x"`))
+}
+
+func TestPrintHTTPRecords(t *testing.T) {
+	records := []signRecord{
+		{
+			Path: "p:a.txt", Method: operations.SignMethodWrite,
+			URL: "https://example.com/a.txt?sig=x", HTTPMethod: http.MethodPut,
+			Header: http.Header{"X-Amz-Date": {"20210101T000000Z"}, "Content-Length": {"10"}},
+			Size:   10,
+		},
+		{
+			Path: "p:a.bin", Method: operations.SignMethodWrite,
+			URL: "https://example.com/a.bin?part=1", HTTPMethod: http.MethodPut,
+			Part: "0", MultipartID: "mid", Size: 5, Offset: 0,
+		},
+		{
+			Path: "p:a.bin", Method: operations.SignMethodWrite,
+			URL: "https://example.com/a.bin?complete", HTTPMethod: http.MethodPost,
+			Part: signPartComplete, MultipartID: "mid", Body: "<a/>", Size: 4,
+		},
+		{
+			Path: "p:b.txt", Method: operations.SignMethodRead,
+			URL: "https://example.com/b.txt", HTTPMethod: http.MethodGet,
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	err := printHTTPRecords(buf, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `### p:a.txt
+PUT /a.txt?sig=x HTTP/1.1
+Host: example.com
+X-Amz-Date: 20210101T000000Z
+Content-Length: 10
+
+< ./a.txt
+
+### p:a.bin part 0 of multipart mid (bytes 0-4)
+PUT /a.bin?part=1 HTTP/1.1
+Host: example.com
+Content-Length: 5
+
+### p:a.bin complete multipart mid
+POST /a.bin?complete HTTP/1.1
+Host: example.com
+Content-Length: 4
+
+<a/>
+
+### p:b.txt
+GET /b.txt HTTP/1.1
+Host: example.com
+`, buf.String())
+}
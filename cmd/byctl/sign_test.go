@@ -5,12 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
 
 	"go.beyondstorage.io/v5/pkg/randbytes"
 	"go.beyondstorage.io/v5/services"
@@ -126,3 +133,163 @@ func TestSignViaExpire(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestSignViaDurationExpire(t *testing.T) {
+	if os.Getenv("BEYOND_CTL_INTEGRATION_TEST") != "on" {
+		t.Skipf("BEYOND_CTL_INTEGRATION_TEST is not 'on', skipped")
+	}
+
+	base, path := setupSign(t)
+	defer tearDownSign(t, base)
+
+	err := app.Run([]string{
+		"byctl", "sign",
+		"--expire=2h",
+		fmt.Sprintf("%s:%s", base, path),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignViaMethod(t *testing.T) {
+	if os.Getenv("BEYOND_CTL_INTEGRATION_TEST") != "on" {
+		t.Skipf("BEYOND_CTL_INTEGRATION_TEST is not 'on', skipped")
+	}
+
+	base, path := setupSign(t)
+	defer tearDownSign(t, base)
+
+	err := app.Run([]string{
+		"byctl", "sign",
+		"--method=write",
+		"--size=1MiB",
+		fmt.Sprintf("%s:%s", base, uuid.NewString()),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = app.Run([]string{
+		"byctl", "sign",
+		"--method=delete",
+		fmt.Sprintf("%s:%s", base, path),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignRecursivelyInJSON(t *testing.T) {
+	if os.Getenv("BEYOND_CTL_INTEGRATION_TEST") != "on" {
+		t.Skipf("BEYOND_CTL_INTEGRATION_TEST is not 'on', skipped")
+	}
+
+	base, _ := setupSign(t)
+	defer tearDownSign(t, base)
+
+	err := app.Run([]string{
+		"byctl", "sign",
+		"-r",
+		"--format=json",
+		fmt.Sprintf("%s:", base),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = app.Run([]string{
+		"byctl", "sign",
+		"--format=csv",
+		fmt.Sprintf("%s:*", base),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignInCurlAndHTTP(t *testing.T) {
+	if os.Getenv("BEYOND_CTL_INTEGRATION_TEST") != "on" {
+		t.Skipf("BEYOND_CTL_INTEGRATION_TEST is not 'on', skipped")
+	}
+
+	base, path := setupSign(t)
+	defer tearDownSign(t, base)
+
+	err := app.Run([]string{
+		"byctl", "sign",
+		"--format=curl",
+		fmt.Sprintf("%s:%s", base, path),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = app.Run([]string{
+		"byctl", "sign",
+		"--method=write",
+		"--size=1MiB",
+		"--format=http",
+		fmt.Sprintf("%s:%s", base, uuid.NewString()),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSignFailed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "byctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = app.Run([]string{
+		"byctl", "sign",
+		"--config", filepath.Join(dir, "config.toml"),
+		fmt.Sprintf("%s:%s", uuid.NewString(), uuid.NewString()),
+	})
+	assert.Error(t, err, "sign should fail if any path failed")
+}
+
+func TestMultipartRecords(t *testing.T) {
+	newRequest := func(method, url, body string) *http.Request {
+		var r io.Reader
+		if body != "" {
+			r = strings.NewReader(body)
+		}
+		req, err := http.NewRequest(method, url, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	sm := &operations.SignedMultipart{
+		MultipartID: "mid",
+		Parts: []*operations.SignedPart{
+			{Index: 0, Offset: 0, Size: 5, Request: newRequest(http.MethodPut, "https://example.com/a?part=0", "")},
+			{Index: 1, Offset: 5, Size: 3, Request: newRequest(http.MethodPut, "https://example.com/a?part=1", "")},
+		},
+		Complete: newRequest(http.MethodPost, "https://example.com/a?complete", "<parts/>"),
+	}
+
+	records, err := multipartRecords("p:a", operations.SignMethodWrite, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []signRecord{
+		{
+			Path: "p:a", Method: operations.SignMethodWrite, URL: "https://example.com/a?part=0",
+			Part: "0", MultipartID: "mid", HTTPMethod: http.MethodPut, Size: 5,
+		},
+		{
+			Path: "p:a", Method: operations.SignMethodWrite, URL: "https://example.com/a?part=1",
+			Part: "1", MultipartID: "mid", HTTPMethod: http.MethodPut, Size: 3, Offset: 5,
+		},
+		{
+			Path: "p:a", Method: operations.SignMethodWrite, URL: "https://example.com/a?complete",
+			Part: signPartComplete, MultipartID: "mid", HTTPMethod: http.MethodPost, Size: 8, Body: "<parts/>",
+		},
+	}, records)
+}
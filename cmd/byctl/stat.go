@@ -4,19 +4,24 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"text/template"
 	"time"
 
+	"github.com/docker/go-units"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
 
 	"go.beyondstorage.io/beyond-ctl/operations"
-	"go.beyondstorage.io/v5/services"
 	"go.beyondstorage.io/v5/types"
 )
 
 const (
-	statFlagJson = "json"
+	statFlagJson      = "json"
+	statFlagJsonLines = "json-lines"
+	statFlagFormat    = "format"
+	statFlagRecursive = "recursive"
 )
 
 var statFlags = []cli.Flag{
@@ -24,17 +29,57 @@ var statFlags = []cli.Flag{
 		Name:  statFlagJson,
 		Usage: "Output in json format",
 	},
+	&cli.BoolFlag{
+		Name:  statFlagJsonLines,
+		Usage: "Output in json lines format, one compact json object per line",
+	},
+	&cli.StringFlag{
+		Name:  statFlagFormat,
+		Usage: "Output via Go template, for example, '{{.Path}} {{.ContentLength}}'",
+	},
+	&cli.BoolFlag{
+		Name: statFlagRecursive,
+		Aliases: []string{
+			"r",
+			"R",
+		},
+		Usage: "stat all files under the directory recursively",
+	},
 }
 
 var statCmd = &cli.Command{
 	Name:      "stat",
 	Usage:     "get file or storage info",
 	UsageText: "byctl stat [command options] [source]",
-	Flags:     mergeFlags(globalFlags, statFlags),
+	Description: `Source could be a glob pattern like profile:dir/*.txt to stat all matched
+   files. Files are stated concurrently via workers.
+
+   Template of --format is executed with fields of the file, which are ID,
+   Path, Mode, LastModified, ContentLength, Etag, ContentType, SystemMetadata
+   and UserMetadata, or fields of the storage, which are Service, Name,
+   WorkDir and Location. Function json could be used to format a field in
+   json, for example, '{{.Path}} {{json .UserMetadata}}'.`,
+	Flags: mergeFlags(globalFlags, statFlags),
 	Before: func(c *cli.Context) error {
 		if args := c.Args().Len(); args < 1 {
 			return fmt.Errorf("stat command wants at least one args, but got %d", args)
 		}
+
+		formats := 0
+		for _, name := range []string{statFlagJson, statFlagJsonLines, statFlagFormat} {
+			if c.IsSet(name) {
+				formats++
+			}
+		}
+		if formats > 1 {
+			return fmt.Errorf("--%s, --%s and --%s are mutually exclusive",
+				statFlagJson, statFlagJsonLines, statFlagFormat)
+		}
+
+		if c.IsSet(statFlagFormat) {
+			_, err := parseStatTemplate(c.String(statFlagFormat))
+			return err
+		}
 		return nil
 	},
 	Action: func(c *cli.Context) (err error) {
@@ -46,67 +91,105 @@ var statCmd = &cli.Command{
 			return err
 		}
 
+		f := statFormatter{layout: normalFormat}
+		switch {
+		case c.Bool(statFlagJson):
+			f.layout = jsonFormat
+		case c.Bool(statFlagJsonLines):
+			f.layout = jsonLinesFormat
+		case c.IsSet(statFlagFormat):
+			f.layout = templateFormat
+			f.tmpl, err = parseStatTemplate(c.String(statFlagFormat))
+			if err != nil {
+				logger.Error("parse format", zap.Error(err))
+				return err
+			}
+		}
+
 		isFirst := true
 		args := c.Args().Len()
+		// Print paths before outputs if there are multiple outputs.
+		labeled := args > 1
+		printOut := func(label, out string) {
+			if labeled && f.labeled() {
+				if isFirst {
+					isFirst = false
+				} else {
+					fmt.Printf("\n")
+				}
+				fmt.Printf("%s\n", label)
+			}
+			fmt.Println(out)
+		}
+
 		for i := 0; i < args; i++ {
-			conn, key, err := cfg.ParseProfileInput(c.Args().Get(i))
+			input := c.Args().Get(i)
+			conn, key, err := cfg.ParseProfileInput(input)
 			if err != nil {
 				logger.Error("parse profile input from src", zap.Error(err))
 				continue
 			}
 
-			store, err := services.NewStoragerFromString(conn)
+			store, err := newStorager(cfg, input, conn)
 			if err != nil {
 				logger.Error("init src storager", zap.Error(err), zap.String("conn string", conn))
 				continue
 			}
 
 			so := operations.NewSingleOperator(store)
-
-			format := normalFormat
-			if c.Bool(statFlagJson) {
-				format = jsonFormat
+			if workers, ok := workersOption(c, cfg.ParseProfileOptions(input)); ok {
+				so.WithWorkers(workers)
 			}
 
-			var out string
-			if key == "" {
+			if key == "" && !c.Bool(statFlagRecursive) {
 				meta := so.StatStorager()
-				sm := parseStorager(meta, conn)
+				sm := parseStorager(meta, conn, so.Capabilities())
 
-				out, err = sm.FormatStorager(format)
+				out, err := f.format(sm)
 				if err != nil {
 					logger.Error("format storager", zap.Error(err))
 					continue
 				}
-			} else {
-				o, err := so.Stat(key)
+				printOut(input, out)
+				continue
+			}
+
+			paths := []string{key}
+			expanded := false
+			if hasGlob(key) || c.Bool(statFlagRecursive) {
+				paths, err = expandPaths(so, key, c.Bool(statFlagRecursive))
 				if err != nil {
-					logger.Error("stat", zap.Error(err))
+					logger.Error("list", zap.String("path", key), zap.Error(err))
+					continue
+				}
+				expanded = true
+				labeled = true
+			}
+
+			for v := range so.StatMany(paths) {
+				if v.Error != nil {
+					logger.Error("stat", zap.Error(v.Error))
 					continue
 				}
 
-				fm, err := parseFileObject(o)
+				fm, err := parseFileObject(v.Object)
 				if err != nil {
 					logger.Error("parse file object", zap.Error(err))
 					continue
 				}
 
-				out, err = fm.FormatFile(format)
+				out, err := f.format(fm)
 				if err != nil {
 					logger.Error("format file", zap.Error(err))
 					continue
 				}
-			}
 
-			if args > 1 {
-				if isFirst {
-					isFirst = false
-				} else {
-					fmt.Printf("\n")
+				label := input
+				if expanded {
+					label = displayPath(input, key, v.Object.Path)
 				}
-				fmt.Printf("%s\n", c.Args().Get(i))
+				printOut(label, out)
 			}
-			fmt.Println(out)
 		}
 
 		return
@@ -116,8 +199,60 @@ var statCmd = &cli.Command{
 const (
 	normalFormat = iota
 	jsonFormat
+	jsonLinesFormat
+	templateFormat
 )
 
+// statFormatter formats files and storages in layout, tmpl is only used by
+// template format.
+type statFormatter struct {
+	layout int
+	tmpl   *template.Template
+}
+
+// labeled reports whether outputs should be labeled by paths, outputs of json
+// lines and template are not labeled, so that they could be processed by
+// other tools.
+func (f statFormatter) labeled() bool {
+	return f.layout == normalFormat || f.layout == jsonFormat
+}
+
+// format formats v which is either *fileMessage or *storageMessage.
+func (f statFormatter) format(v interface{}) (string, error) {
+	switch f.layout {
+	case jsonLinesFormat:
+		b, err := json.Marshal(v)
+		return string(b), err
+	case templateFormat:
+		buf := new(bytes.Buffer)
+		err := f.tmpl.Execute(buf, v)
+		return buf.String(), err
+	}
+
+	switch v := v.(type) {
+	case *fileMessage:
+		return v.FormatFile(f.layout)
+	case *storageMessage:
+		return v.FormatStorager(f.layout)
+	default:
+		panic("not support message")
+	}
+}
+
+// parseStatTemplate parses text of flag format into template.
+func parseStatTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("stat").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("format %s is invalid: %w", text, err)
+	}
+	return tmpl, nil
+}
+
 type fileMessage struct {
 	ID             string            // file absolute path
 	Path           string            // file relative path
@@ -154,30 +289,79 @@ func (fm *fileMessage) normalFileFormat() (string, error) {
 	buf.AppendString(fmt.Sprintf("ContentType: %s\n", fm.ContentType))
 
 	buf.AppendString(fmt.Sprint("\nSystemMetadata:"))
-	// Convert system metadata into a map, so that all the data in it can be retrieved.
-	sysMeta, err := json.Marshal(fm.SystemMetadata)
-	if err != nil {
-		return "", err
-	}
-	var m map[string]interface{}
-	err = json.Unmarshal(sysMeta, &m)
+	sysMeta, err := formatSystemMetadata(fm.SystemMetadata)
 	if err != nil {
 		return "", err
 	}
-	for k, v := range m {
-		buf.AppendString(fmt.Sprintf("\n%s: \"%s\"", k, v))
+	for _, v := range sysMeta {
+		buf.AppendString("\n" + v)
 	}
 
 	if fm.UserMetadata != nil {
 		buf.AppendString(fmt.Sprint("\n\nUserMetadata:"))
-		for k, v := range fm.UserMetadata {
-			buf.AppendString(fmt.Sprintf("\n%s: \"%s\"", k, v))
+		keys := make([]string, 0, len(fm.UserMetadata))
+		for k := range fm.UserMetadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf.AppendString(fmt.Sprintf("\n%s: %q", k, fm.UserMetadata[k]))
 		}
 	}
 
 	return buf.String(), nil
 }
 
+// formatSystemMetadata formats fields of system metadata into lines like
+// `key: value` sorted by key. Numbers are kept as they are, and values that
+// are not strings will be formatted in json.
+func formatSystemMetadata(sysMeta interface{}) ([]string, error) {
+	b, err := json.Marshal(sysMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	err = d.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		// System metadata is not a struct, for example, nil.
+		if v == nil {
+			return nil, nil
+		}
+		return []string{string(b)}, nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		switch value := m[k].(type) {
+		case string:
+			lines = append(lines, fmt.Sprintf("%s: %q", k, value))
+		case json.Number:
+			lines = append(lines, fmt.Sprintf("%s: %s", k, value))
+		default:
+			vb, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", k, vb))
+		}
+	}
+	return lines, nil
+}
+
 func (fm *fileMessage) jsonFileFormat() (string, error) {
 	b, err := json.Marshal(&fm)
 	if err != nil {
@@ -194,10 +378,41 @@ func (fm *fileMessage) jsonFileFormat() (string, error) {
 }
 
 type storageMessage struct {
-	Service  string // service name
-	Name     string // bucket name
-	WorkDir  string // work dir
-	Location string // bucket location
+	Service      string          // service name
+	Name         string          // bucket name
+	WorkDir      string          // work dir
+	Location     string          // bucket location
+	Capabilities map[string]bool // implemented optional interfaces
+	Limits       *storageLimits  `json:",omitempty"` // limits of operations
+}
+
+// storageLimits are limits of operations in storage metadata, zero means
+// unlimited or unknown.
+type storageLimits struct {
+	MultipartNumberMaximum int   `json:",omitempty"`
+	MultipartSizeMaximum   int64 `json:",omitempty"`
+	MultipartSizeMinimum   int64 `json:",omitempty"`
+	WriteSizeMaximum       int64 `json:",omitempty"`
+	CopySizeMaximum        int64 `json:",omitempty"`
+	AppendNumberMaximum    int   `json:",omitempty"`
+	AppendSizeMaximum      int64 `json:",omitempty"`
+	AppendTotalSizeMaximum int64 `json:",omitempty"`
+}
+
+// capabilityUsages are commands or flags that require the capability, which
+// will be printed so that users know why they fail.
+var capabilityUsages = map[string]string{
+	"Multiparter":         "tee, multipart of cp, mv and sync",
+	"Copier":              "setmeta",
+	"Mover":               "rename in mount",
+	"StorageHTTPSigner":   "sign",
+	"MultipartHTTPSigner": "sign --multipart",
+}
+
+// capabilityNames are names of capabilities in the order to print.
+var capabilityNames = []string{
+	"Multiparter", "Appender", "Copier", "Mover", "Direr", "Linker", "Fetcher",
+	"StorageHTTPSigner", "MultipartHTTPSigner",
 }
 
 func (sm *storageMessage) FormatStorager(layout int) (string, error) {
@@ -223,6 +438,47 @@ func (sm *storageMessage) normalStoragerFormat() (string, error) {
 		buf.AppendString(fmt.Sprintf("\nLocation: %s", sm.Location))
 	}
 
+	if sm.Capabilities != nil {
+		buf.AppendString("\n\nCapabilities:")
+		for _, name := range capabilityNames {
+			supported, ok := sm.Capabilities[name]
+			if !ok {
+				continue
+			}
+			buf.AppendString(fmt.Sprintf("\n%s: %t", name, supported))
+			if usage := capabilityUsages[name]; usage != "" {
+				buf.AppendString(fmt.Sprintf(" (required by %s)", usage))
+			}
+		}
+	}
+
+	if l := sm.Limits; l != nil {
+		buf.AppendString("\n\nLimits:")
+		for _, v := range []struct {
+			name  string
+			value int64
+			size  bool
+		}{
+			{"MultipartNumberMaximum", int64(l.MultipartNumberMaximum), false},
+			{"MultipartSizeMaximum", l.MultipartSizeMaximum, true},
+			{"MultipartSizeMinimum", l.MultipartSizeMinimum, true},
+			{"WriteSizeMaximum", l.WriteSizeMaximum, true},
+			{"CopySizeMaximum", l.CopySizeMaximum, true},
+			{"AppendNumberMaximum", int64(l.AppendNumberMaximum), false},
+			{"AppendSizeMaximum", l.AppendSizeMaximum, true},
+			{"AppendTotalSizeMaximum", l.AppendTotalSizeMaximum, true},
+		} {
+			if v.value == 0 {
+				continue
+			}
+			if v.size {
+				buf.AppendString(fmt.Sprintf("\n%s: %s", v.name, units.BytesSize(float64(v.value))))
+			} else {
+				buf.AppendString(fmt.Sprintf("\n%s: %d", v.name, v.value))
+			}
+		}
+	}
+
 	return buf.String(), nil
 }
 
@@ -271,7 +527,7 @@ func parseFileObject(o *types.Object) (*fileMessage, error) {
 	return fm, nil
 }
 
-func parseStorager(meta *types.StorageMeta, conn string) *storageMessage {
+func parseStorager(meta *types.StorageMeta, conn string, caps []operations.Capability) *storageMessage {
 	sm := &storageMessage{
 		Name:    meta.Name,
 		WorkDir: meta.WorkDir,
@@ -281,6 +537,42 @@ func parseStorager(meta *types.StorageMeta, conn string) *storageMessage {
 		sm.Location = v
 	}
 
+	if len(caps) > 0 {
+		sm.Capabilities = make(map[string]bool, len(caps))
+		for _, v := range caps {
+			sm.Capabilities[v.Name] = v.Supported
+		}
+	}
+
+	l := &storageLimits{}
+	if v, ok := meta.GetMultipartNumberMaximum(); ok {
+		l.MultipartNumberMaximum = v
+	}
+	if v, ok := meta.GetMultipartSizeMaximum(); ok {
+		l.MultipartSizeMaximum = v
+	}
+	if v, ok := meta.GetMultipartSizeMinimum(); ok {
+		l.MultipartSizeMinimum = v
+	}
+	if v, ok := meta.GetWriteSizeMaximum(); ok {
+		l.WriteSizeMaximum = v
+	}
+	if v, ok := meta.GetCopySizeMaximum(); ok {
+		l.CopySizeMaximum = v
+	}
+	if v, ok := meta.GetAppendNumberMaximum(); ok {
+		l.AppendNumberMaximum = v
+	}
+	if v, ok := meta.GetAppendSizeMaximum(); ok {
+		l.AppendSizeMaximum = v
+	}
+	if v, ok := meta.GetAppendTotalSizeMaximum(); ok {
+		l.AppendTotalSizeMaximum = v
+	}
+	if *l != (storageLimits{}) {
+		sm.Limits = l
+	}
+
 	// Get service name by conn.
 	// For example: conn = s3://bucketname/workdir?credential=xxx&endpoint=xxx&location=xxx
 	// We can get "s3".
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go.beyondstorage.io/v5/services"
+	"go.beyondstorage.io/v5/types"
+)
+
+func getStatTestService(s string) string {
+	if s != "" {
+		s += "/"
+	}
+	return fmt.Sprintf(os.Getenv("BEYOND_CTL_TEST_SERVICE"), s)
+}
+
+func setupStat(t *testing.T) (base string) {
+	store, err := services.NewStoragerFromString(getStatTestService(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base = uuid.NewString()
+	for i := 0; i < 4; i++ {
+		content := []byte(uuid.NewString())
+		_, err = store.Write(fmt.Sprintf("%s/dir/%d.txt", base, i),
+			bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = os.Setenv(
+		fmt.Sprintf("BEYOND_CTL_PROFILE_%s", base),
+		getStatTestService(base),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base
+}
+
+func tearDownStat(t *testing.T, base string) {
+	store, err := services.NewStoragerFromString(getStatTestService(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := store.List(base + "/dir/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		o, err := it.Next()
+		if err != nil && errors.Is(err, types.IterateDone) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Delete(o.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = os.Unsetenv(fmt.Sprintf("BEYOND_CTL_PROFILE_%s", base))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStatRecursivelyInJSONLines(t *testing.T) {
+	if os.Getenv("BEYOND_CTL_INTEGRATION_TEST") != "on" {
+		t.Skipf("BEYOND_CTL_INTEGRATION_TEST is not 'on', skipped")
+	}
+
+	base := setupStat(t)
+	defer tearDownStat(t, base)
+
+	err := app.Run([]string{
+		"byctl", "stat",
+		"-r",
+		"--json-lines",
+		fmt.Sprintf("%s:dir/", base),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatViaFormat(t *testing.T) {
+	if os.Getenv("BEYOND_CTL_INTEGRATION_TEST") != "on" {
+		t.Skipf("BEYOND_CTL_INTEGRATION_TEST is not 'on', skipped")
+	}
+
+	base := setupStat(t)
+	defer tearDownStat(t, base)
+
+	err := app.Run([]string{
+		"byctl", "stat",
+		"--format={{.Path}} {{.ContentLength}}",
+		fmt.Sprintf("%s:dir/*.txt", base),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatStorager(t *testing.T) {
+	if os.Getenv("BEYOND_CTL_INTEGRATION_TEST") != "on" {
+		t.Skipf("BEYOND_CTL_INTEGRATION_TEST is not 'on', skipped")
+	}
+
+	base := setupStat(t)
+	defer tearDownStat(t, base)
+
+	err := app.Run([]string{
+		"byctl", "stat",
+		"--json",
+		fmt.Sprintf("%s:", base),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
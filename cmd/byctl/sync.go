@@ -9,8 +9,6 @@ import (
 	"go.uber.org/zap"
 
 	"go.beyondstorage.io/beyond-ctl/operations"
-	"go.beyondstorage.io/v5/services"
-	"go.beyondstorage.io/v5/types"
 )
 
 const (
@@ -71,7 +69,7 @@ var syncCmd = &cli.Command{
 	Name:      "sync",
 	Usage:     "sync file from source storager to target storager",
 	UsageText: "byctl sync [command options] [source] [target]",
-	Flags:     mergeFlags(globalFlags, ioFlags, syncFlags),
+	Flags:     mergeFlags(globalFlags, ioFlags, multipartFlags, metadataFlags, syncFlags, []cli.Flag{flagPreserve}),
 	Before: func(c *cli.Context) error {
 		if args := c.Args().Len(); args < 2 {
 			return fmt.Errorf("sync command wants at least two args, but got %d", args)
@@ -100,47 +98,46 @@ var syncCmd = &cli.Command{
 			return fmt.Errorf("target is not a directory")
 		}
 
-		dst, err := services.NewStoragerFromString(dstConn)
+		dst, err := newStorager(cfg, c.Args().Get(argsNum-1), dstConn)
 		if err != nil {
 			logger.Error("init dst storager", zap.Error(err), zap.String("conn string", dstConn))
 			return err
 		}
 
-		// Handle read pairs.
-		var readPairs []types.Pair
-		if c.IsSet(flagReadSpeedLimitName) {
-			limitPair, err := parseLimit(c.String(flagReadSpeedLimitName))
-			if err != nil {
-				logger.Error("read limit is invalid",
-					zap.String("input", c.String(flagReadSpeedLimitName)),
-					zap.Error(err))
-				return err
-			}
-
-			readPairs = append(readPairs, limitPair)
+		// Handle write pairs, write options are picked from the target profile.
+		dstOpts := cfg.ParseProfileOptions(c.Args().Get(argsNum - 1))
+		writePairs, err := parseWritePairs(c, cfg, c.Args().Get(argsNum-1), dstConn)
+		if err != nil {
+			logger.Error("parse write pairs", zap.Error(err))
+			return err
+		}
+		metadataPairs, err := parseMetadataPairs(c, dstOpts, dstConn)
+		if err != nil {
+			logger.Error("parse metadata pairs", zap.Error(err))
+			return err
 		}
 
-		// Handle write pairs.
-		var writePairs []types.Pair
-		if c.IsSet(flagWriteSpeedLimitName) {
-			limitPair, err := parseLimit(c.String(flagWriteSpeedLimitName))
-			if err != nil {
-				logger.Error("write limit is invalid",
-					zap.String("input", c.String(flagWriteSpeedLimitName)),
-					zap.Error(err))
-				return err
-			}
+		// parse flag multipart-threshold, 1GB is the default value
+		multipartThreshold, err := parseMultipartThreshold(c, syncFlagMultipartThreshold, dstOpts)
+		if err != nil {
+			logger.Error("parse multipart-threshold", zap.Error(err))
+			return err
+		}
 
-			writePairs = append(writePairs, limitPair)
+		partSize, err := parsePartSizeOption(c, dstOpts)
+		if err != nil {
+			logger.Error("parse part-size", zap.Error(err))
+			return err
 		}
 
-		// parse flag multipart-threshold, 1GB is the default value
-		multipartThreshold, err := units.RAMInBytes(c.String(syncFlagMultipartThreshold))
+		maxMemory, err := units.RAMInBytes(c.String(flagMaxMemoryName))
 		if err != nil {
-			logger.Error("multipart-threshold is invalid",
-				zap.String("input", c.String(syncFlagMultipartThreshold)),
+			logger.Error("max-memory is invalid",
+				zap.String("input", c.String(flagMaxMemoryName)),
 				zap.Error(err))
+			return err
 		}
+		operations.SetMaxMemory(maxMemory)
 
 		// Initialization of `sync` options.
 		opts := operations.SyncOptions{
@@ -169,12 +166,20 @@ var syncCmd = &cli.Command{
 				return fmt.Errorf("source is not a directory")
 			}
 
-			src, err := services.NewStoragerFromString(srcConn)
+			src, err := newStorager(cfg, c.Args().Get(i), srcConn)
 			if err != nil {
 				logger.Error("init src storager", zap.Error(err), zap.String("conn string", srcConn))
 				continue
 			}
 
+			// Handle read pairs, read options are picked from the source profile.
+			srcOpts := cfg.ParseProfileOptions(c.Args().Get(i))
+			readPairs, err := parseReadPairs(c, cfg, c.Args().Get(i), srcConn)
+			if err != nil {
+				logger.Error("parse read pairs", zap.Error(err))
+				continue
+			}
+
 			so := operations.NewSingleOperator(src)
 
 			_, err = so.Stat(srcKey)
@@ -183,13 +188,24 @@ var syncCmd = &cli.Command{
 				return err
 			}
 
+			preserve, err := parsePreserveOptions(c, src, srcConn, dst, dstConn)
+			if err != nil {
+				logger.Error("parse preserve", zap.Error(err))
+				continue
+			}
+
 			do := operations.NewDualOperator(src, dst)
-			if c.IsSet(flagWorkersName) {
-				do.WithWorkers(c.Int(flagWorkersName))
+			if workers, ok := workersOption(c, dstOpts, srcOpts); ok {
+				do.WithWorkers(workers)
 			}
+			do.WithPartSize(partSize)
+			do.WithPartConcurrency(intOption(c, flagPartConcurrencyName, dstOpts.PartConcurrency))
 
 			do.WithReadPairs(readPairs...)
 			do.WithWritePairs(writePairs...)
+			do.WithMetadataPairs(metadataPairs...)
+			do.WithContentTypeDetection(c.Bool(flagDetectContentTypeName))
+			do.WithPreserve(preserve)
 
 			ch, err := do.SyncDir(srcKey, dstKey, opts)
 			if err != nil {
@@ -9,7 +9,6 @@ import (
 	"go.uber.org/zap"
 
 	"go.beyondstorage.io/beyond-ctl/operations"
-	"go.beyondstorage.io/v5/services"
 )
 
 const (
@@ -28,7 +27,7 @@ var teeCmd = &cli.Command{
 	Name:      "tee",
 	Usage:     "used to read data from standard input and output its contents to a file",
 	UsageText: "byctl tee [command options] [target]",
-	Flags:     mergeFlags(globalFlags, teeFlags),
+	Flags:     mergeFlags(globalFlags, multipartFlags, metadataFlags, teeFlags),
 	Before: func(c *cli.Context) error {
 		if args := c.Args().Len(); args < 1 {
 			return fmt.Errorf("tee command wants at least one args, but got %d", args)
@@ -44,12 +43,27 @@ var teeCmd = &cli.Command{
 			return err
 		}
 
-		buf := new(bytes.Buffer)
-		_, err = buf.ReadFrom(c.App.Reader)
+		maxMemory, err := units.RAMInBytes(c.String(flagMaxMemoryName))
 		if err != nil {
-			logger.Error("read data", zap.Error(err))
+			logger.Error("max-memory is invalid",
+				zap.String("input", c.String(flagMaxMemoryName)),
+				zap.Error(err))
 			return err
 		}
+		operations.SetMaxMemory(maxMemory)
+
+		// Stdin is streamed into the only target via part buffers, and only
+		// buffered in memory while it will be written to multiple targets.
+		var data []byte
+		if c.Args().Len() > 1 {
+			buf := new(bytes.Buffer)
+			_, err = buf.ReadFrom(c.App.Reader)
+			if err != nil {
+				logger.Error("read data", zap.Error(err))
+				return err
+			}
+			data = buf.Bytes()
+		}
 
 		for i := 0; i < c.Args().Len(); i++ {
 			conn, key, err := cfg.ParseProfileInput(c.Args().Get(i))
@@ -58,13 +72,33 @@ var teeCmd = &cli.Command{
 				continue
 			}
 
-			store, err := services.NewStoragerFromString(conn)
+			store, err := newStorager(cfg, c.Args().Get(i), conn)
 			if err != nil {
 				logger.Error("init target storager", zap.Error(err), zap.String("conn string", conn))
 				continue
 			}
 
+			opts := cfg.ParseProfileOptions(c.Args().Get(i))
+			partSize, err := parsePartSizeOption(c, opts)
+			if err != nil {
+				logger.Error("parse part-size", zap.Error(err))
+				continue
+			}
+
+			metadataPairs, err := parseMetadataPairs(c, opts, conn)
+			if err != nil {
+				logger.Error("parse metadata pairs", zap.Error(err))
+				continue
+			}
+
 			so := operations.NewSingleOperator(store)
+			if workers, ok := workersOption(c, opts); ok {
+				so.WithWorkers(workers)
+			}
+			so.WithPartSize(partSize)
+			so.WithPartConcurrency(intOption(c, flagPartConcurrencyName, opts.PartConcurrency))
+			so.WithMetadataPairs(metadataPairs...)
+			so.WithContentTypeDetection(c.Bool(flagDetectContentTypeName))
 
 			expectedSize, err := units.RAMInBytes(c.String(teeFlagExpectSize))
 			if err != nil {
@@ -72,7 +106,11 @@ var teeCmd = &cli.Command{
 				continue
 			}
 
-			ch, err := so.TeeRun(key, expectedSize, bytes.NewReader(buf.Bytes()))
+			r := c.App.Reader
+			if c.Args().Len() > 1 {
+				r = bytes.NewReader(data)
+			}
+			ch, err := so.TeeRun(key, expectedSize, r)
 			if err != nil {
 				logger.Error("run tee", zap.Error(err))
 				continue
@@ -129,3 +129,29 @@ func TestTeeViaExpectedSize(t *testing.T) {
 		t.Error("tee failed")
 	}
 }
+
+func TestTeeViaContentType(t *testing.T) {
+	if os.Getenv("BEYOND_CTL_INTEGRATION_TEST") != "on" {
+		t.Skipf("BEYOND_CTL_INTEGRATION_TEST is not 'on', skipped")
+	}
+
+	base, path := setupTee(t)
+	defer tearDownTee(t, base, path)
+
+	size := rand.Intn(1024 * 1024)
+	app.Reader = io.LimitReader(randbytes.NewRand(), int64(size))
+
+	err := app.Run([]string{
+		"byctl", "tee",
+		"--content-type=application/octet-stream",
+		fmt.Sprintf("%s:%s", base, path),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	n := checkResult(t, base, path)
+	if n != int64(size) {
+		t.Error("tee failed")
+	}
+}
@@ -2,51 +2,186 @@ package main
 
 import (
 	"fmt"
-	"time"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/Xuanwo/go-bufferpool"
 	"github.com/docker/go-units"
 	"github.com/urfave/cli/v2"
-	"golang.org/x/time/rate"
 
 	"go.beyondstorage.io/beyond-ctl/config"
-	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/v5/services"
 	"go.beyondstorage.io/v5/types"
 )
 
 var pool = bufferpool.New(128)
 
-func loadConfig(c *cli.Context, loadEnv bool) (*config.Config, error) {
+// storagers caches storagers indexed by connection string, so that
+// connections could be reused by commands running in the same process, for
+// example, in shell mode.
+var storagers = struct {
+	sync.Mutex
+	m map[string]types.Storager
+}{m: make(map[string]types.Storager)}
+
+// partSizeAuto means the part size will be calculated from object size and
+// the multipart restrictions of the target service.
+const partSizeAuto = "auto"
+
+// sessionConfig is the layered config loaded by shell, which will be used by
+// commands running in shell instead of loading config again.
+var sessionConfig *config.Config
+
+// loadConfig loads config for commands.
+//
+// If layered is true, config will be merged from system, user, project config
+// and environment variables. Otherwise, only user config will be loaded, so
+// that it's safe to write it back.
+func loadConfig(c *cli.Context, layered bool) (*config.Config, error) {
+	if layered && sessionConfig != nil {
+		return sessionConfig, nil
+	}
 	path := c.String(flagConfigName)
-	cfg, err := config.LoadFromFile(path)
+
+	var cfg *config.Config
+	var err error
+	if layered {
+		cfg, err = config.LoadLayered(path, true)
+	} else {
+		cfg, err = config.LoadFromFile(path)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("load config %s: %w", path, err)
 	}
-	if loadEnv {
-		cfg.MergeProfileFromEnv()
-	}
 	return cfg, nil
 }
 
-func parseLimit(text string) (types.Pair, error) {
-	limit, err := units.FromHumanSize(text)
+// writeConfig writes cfg back to user config, and tells the user where the
+// original config is backed up if it's migrated while loading.
+func writeConfig(c *cli.Context, cfg *config.Config) error {
+	if err := cfg.WriteToFile(c.String(flagConfigName)); err != nil {
+		return err
+	}
+
+	if plan := cfg.Migration(); plan != nil && plan.Backup != "" {
+		fmt.Fprintf(os.Stderr, "config is migrated from ver. %d to ver. %d, original config is backed up to %s\n",
+			plan.From, plan.To, plan.Backup)
+	}
+	return nil
+}
+
+// newStorager returns the storager for conn parsed from input via cfg, which
+// will be reused if it has been initialized before. cfg could be nil if the
+// request quota of profile is not needed.
+func newStorager(cfg *config.Config, input, conn string) (types.Storager, error) {
+	storagers.Lock()
+	defer storagers.Unlock()
+
+	store, ok := storagers.m[conn]
+	if !ok {
+		var err error
+		store, err = services.NewStoragerFromString(conn)
+		if err != nil {
+			return nil, err
+		}
+		storagers.m[conn] = store
+	}
+	if cfg != nil {
+		registerRequestQuota(cfg, input, store)
+	}
+	limitRequests(store)
+	return store, nil
+}
+
+// parsePartSize parses the part size input. Zero means the part size should be
+// calculated automatically.
+func parsePartSize(text string) (int64, error) {
+	if text == "" || text == partSizeAuto {
+		return 0, nil
+	}
+
+	size, err := units.RAMInBytes(text)
 	if err != nil {
-		return types.Pair{}, err
+		return 0, err
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("part size must be positive, but got %s", text)
+	}
+	return size, nil
+}
+
+// hasGlob reports whether p contains glob meta characters.
+func hasGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// expandPaths returns paths of files matched by pattern, or under dir pattern
+// if recursive is true and pattern doesn't contain glob meta characters.
+//
+// Glob patterns are matched via path.Match, so `*` doesn't match `/`. Dirs
+// will be listed recursively only if there are `/` after the first meta
+// character.
+func expandPaths(so *operations.SingleOperator, pattern string, recursive bool) ([]string, error) {
+	if !hasGlob(pattern) {
+		if !recursive {
+			return []string{pattern}, nil
+		}
+		ch, err := so.ListRecursively(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return collectPaths(ch, func(string) bool { return true })
 	}
 
-	limter := rate.NewLimiter(rate.Limit(limit), int(limit))
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
 
-	return pairs.WithIoCallback(func(bs []byte) {
-		l := len(bs)
+	meta := strings.IndexAny(pattern, "*?[")
+	dir := pattern[:strings.LastIndex(pattern[:meta], "/")+1]
 
-		for l > 0 {
-			n := limter.Burst()
-			if n > l {
-				n = l
+	var ch chan *operations.ObjectResult
+	var err error
+	if recursive || strings.Contains(pattern[meta:], "/") {
+		ch, err = so.ListRecursively(dir)
+	} else {
+		ch, err = so.List(dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return collectPaths(ch, func(p string) bool {
+		ok, _ := path.Match(pattern, p)
+		return ok
+	})
+}
+
+// collectPaths collects paths of files in ch that match.
+func collectPaths(ch chan *operations.ObjectResult, match func(p string) bool) ([]string, error) {
+	var paths []string
+	var err error
+	for v := range ch {
+		// Keep draining ch, so that the list goroutine could exit.
+		if v.Error != nil {
+			if err == nil {
+				err = v.Error
 			}
-			r := limter.ReserveN(time.Now(), n)
-			time.Sleep(r.Delay())
-			l -= n
+			continue
+		}
+		if err != nil || v.Object.Mode.IsDir() {
+			continue
 		}
-	}), nil
+		if match(v.Object.Path) {
+			paths = append(paths, v.Object.Path)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
 }
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxAliasDepth is the max depth of aliases that refer to other aliases.
+const maxAliasDepth = 16
+
+// AddAlias adds an alias which will be expanded into def.
+func (c *Config) AddAlias(name, def string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Aliases == nil {
+		c.Aliases = make(map[string]string)
+	}
+	c.Aliases[name] = def
+}
+
+// GetAlias returns the definition of alias with name.
+func (c *Config) GetAlias(name string) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	def, ok := c.Aliases[name]
+	return def, ok
+}
+
+// AliasNames returns names of all aliases in order.
+func (c *Config) AliasNames() []string {
+	c.Lock()
+	defer c.Unlock()
+
+	names := make([]string, 0, len(c.Aliases))
+	for name := range c.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExpandAlias expands alias name with args into command args.
+//
+// Aliases could refer to other aliases, isCommand is used to check whether
+// the first arg of expanded args is a command, which will stop the expansion.
+func (c *Config) ExpandAlias(name string, args []string, isCommand func(name string) bool) ([]string, error) {
+	seen := make(map[string]bool)
+	for depth := 0; depth < maxAliasDepth; depth++ {
+		def, ok := c.GetAlias(name)
+		if !ok {
+			return nil, fmt.Errorf("alias %s not found", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("alias %s refers to itself", name)
+		}
+		seen[name] = true
+
+		expanded, err := expandAlias(def, args)
+		if err != nil {
+			return nil, fmt.Errorf("alias %s: %w", name, err)
+		}
+		if len(expanded) == 0 {
+			return nil, fmt.Errorf("alias %s is empty", name)
+		}
+		if isCommand(expanded[0]) {
+			return expanded, nil
+		}
+		name, args = expanded[0], expanded[1:]
+	}
+	return nil, fmt.Errorf("alias %s is nested too deep", name)
+}
+
+// expandAlias splits def into args and substitutes placeholders with args.
+//
+// Supported placeholders:
+// - `$1`, `$2`, ...: the nth arg
+// - `$@`: all args, expanded into multiple args if used as a whole word
+// - `$$`: a literal `$`
+//
+// All args will be appended to the end if def has no placeholders, which
+// works the same as git aliases.
+func expandAlias(def string, args []string) ([]string, error) {
+	words, err := SplitArgs(def)
+	if err != nil {
+		return nil, err
+	}
+
+	used := false
+	out := make([]string, 0, len(words)+len(args))
+	for _, word := range words {
+		if word == "$@" {
+			out = append(out, args...)
+			used = true
+			continue
+		}
+
+		var sb strings.Builder
+		for i := 0; i < len(word); i++ {
+			if word[i] != '$' || i == len(word)-1 {
+				sb.WriteByte(word[i])
+				continue
+			}
+
+			next := word[i+1]
+			switch {
+			case next == '$':
+				sb.WriteByte('$')
+				i++
+			case next == '@':
+				sb.WriteString(strings.Join(args, " "))
+				used = true
+				i++
+			case next >= '1' && next <= '9':
+				j := i + 1
+				for j < len(word) && word[j] >= '0' && word[j] <= '9' {
+					j++
+				}
+				n, _ := strconv.Atoi(word[i+1 : j])
+				if n > len(args) {
+					return nil, fmt.Errorf("wants at least %d args, but got %d", n, len(args))
+				}
+				sb.WriteString(args[n-1])
+				used = true
+				i = j - 1
+			default:
+				sb.WriteByte('$')
+			}
+		}
+		out = append(out, sb.String())
+	}
+
+	if !used {
+		out = append(out, args...)
+	}
+	return out, nil
+}
+
+// SplitArgs splits s into args like a POSIX shell without expansion.
+//
+// Single quotes, double quotes and backslash escapes are supported.
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var sb strings.Builder
+	// inArg is used to keep empty quoted args like `''`.
+	inArg := false
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote == '\'':
+			if ch == '\'' {
+				quote = 0
+			} else {
+				sb.WriteByte(ch)
+			}
+		case quote == '"':
+			switch {
+			case ch == '"':
+				quote = 0
+			case ch == '\\' && i+1 < len(s) && strings.IndexByte("\"\\", s[i+1]) != -1:
+				i++
+				sb.WriteByte(s[i])
+			default:
+				sb.WriteByte(ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inArg = true
+		case ch == '\\':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			}
+			inArg = true
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, sb.String())
+				sb.Reset()
+				inArg = false
+			}
+		default:
+			sb.WriteByte(ch)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inArg {
+		args = append(args, sb.String())
+	}
+	return args, nil
+}
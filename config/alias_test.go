@@ -0,0 +1,82 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitArgs(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		expect []string
+		hasErr bool
+	}{
+		{"plain", "sync -r prod: backup:", []string{"sync", "-r", "prod:", "backup:"}, false},
+		{"extra spaces", "  ls \t -l  ", []string{"ls", "-l"}, false},
+		{"single quote", `sync --exclude '\.tmp$' a: b:`, []string{"sync", "--exclude", `\.tmp$`, "a:", "b:"}, false},
+		{"double quote", `cp "a b" "say \"hi\""`, []string{"cp", "a b", `say "hi"`}, false},
+		{"escape", `cp a\ b c`, []string{"cp", "a b", "c"}, false},
+		{"empty quoted", `cp '' x`, []string{"cp", "", "x"}, false},
+		{"unterminated", `cp 'a`, nil, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := SplitArgs(tt.input)
+			if tt.hasErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, args)
+		})
+	}
+}
+
+func TestConfig_ExpandAlias(t *testing.T) {
+	cfg := New()
+	cfg.AddAlias("backup", `sync -r --update --exclude '\.tmp$' prod: backup:`)
+	cfg.AddAlias("put", "cp $1 prod:$2")
+	cfg.AddAlias("all", "rm -r $@ $$HOME")
+	cfg.AddAlias("nested", "put -r")
+	cfg.AddAlias("loop1", "loop2")
+	cfg.AddAlias("loop2", "loop1")
+
+	isCommand := func(name string) bool {
+		switch name {
+		case "sync", "cp", "rm":
+			return true
+		}
+		return false
+	}
+
+	cases := []struct {
+		name   string
+		alias  string
+		args   []string
+		expect []string
+		hasErr bool
+	}{
+		{"append args", "backup", []string{"--remove"}, []string{"sync", "-r", "--update", "--exclude", `\.tmp$`, "prod:", "backup:", "--remove"}, false},
+		{"positional args", "put", []string{"a.txt", "dir/a.txt"}, []string{"cp", "a.txt", "prod:dir/a.txt"}, false},
+		{"missing args", "put", []string{"a.txt"}, nil, true},
+		{"all args", "all", []string{"a:", "b:"}, []string{"rm", "-r", "a:", "b:", "$HOME"}, false},
+		{"nested", "nested", []string{"a", "b"}, []string{"cp", "-r", "prod:a"}, false},
+		{"loop", "loop1", nil, nil, true},
+		{"not found", "unknown", nil, nil, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := cfg.ExpandAlias(tt.alias, tt.args, isCommand)
+			if tt.hasErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, args)
+		})
+	}
+}
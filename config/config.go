@@ -14,10 +14,24 @@ import (
 // Version of config
 const Version = 1
 
+// configFileMode is the permission of config file.
+const configFileMode os.FileMode = 0600
+
 type Config struct {
 	sync.Mutex
-	Version  int                `json:"version" toml:"version"`
-	Profiles map[string]Profile `json:"profile" toml:"profile"`
+	Version    int                `json:"version" toml:"version"`
+	Encryption *Encryption        `json:"encryption,omitempty" toml:"encryption,omitempty"`
+	Profiles   map[string]Profile `json:"profile" toml:"profile"`
+	Aliases    map[string]string  `json:"alias,omitempty" toml:"alias,omitempty"`
+
+	// key is used to encrypt secrets while writing config file.
+	// Secrets are kept in plain text in memory if key is set.
+	key []byte
+	// origins records where items come from, indexed by item key.
+	origins map[string]string
+	// migration is the pending migration applied in memory, the config file
+	// will be backed up before being rewritten.
+	migration *MigrationPlan
 }
 
 func New() *Config {
@@ -48,16 +62,57 @@ func LoadFromFile(path string) (*Config, error) {
 		return nil, err
 	}
 
+	return parseConfig(data)
+}
+
+// parseConfig parses config from data read from file.
+//
+// Config written by older versions of byctl will only be migrated in memory,
+// the file is upgraded by `byctl config migrate` or backed up before being
+// rewritten.
+func parseConfig(data []byte) (*Config, error) {
+	cfg := New()
+
+	plan, err := planMigration(data, Version, migrations)
+	if err != nil {
+		return nil, err
+	}
+	if plan.NeedMigrate() {
+		data = plan.Migrated
+	}
+
 	if err = toml.Unmarshal(data, cfg); err != nil {
 		return nil, err
 	}
+	if plan.NeedMigrate() {
+		cfg.migration = plan
+	}
 
-	if cfg.Version != Version {
-		return nil, fmt.Errorf("config ver. %d is expected, version of config file is not compatible", Version)
+	// Secrets will be kept encrypted if password or key file not provided.
+	if cfg.Encryption != nil {
+		secret, ok, err := LoadSecretFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if err = cfg.decrypt(secret); err != nil {
+				return nil, fmt.Errorf("decrypt config: %w", err)
+			}
+		}
 	}
 	return cfg, nil
 }
 
+// Migration returns the migration applied in memory while loading config,
+// nil will be returned if config file is up to date.
+func (c *Config) Migration() *MigrationPlan {
+	return c.migration
+}
+
+// WriteToFile writes config to path.
+//
+// If config is migrated while loading, the original file will be backed up
+// first, and Backup of Migration will be set.
 func (c *Config) WriteToFile(path string) error {
 	c.Lock()
 	defer c.Unlock()
@@ -67,18 +122,151 @@ func (c *Config) WriteToFile(path string) error {
 		return err
 	}
 
+	// Secrets are encrypted before opening the file, so that the file will
+	// not be truncated if they could not be encrypted.
+	out, err := c.encrypted()
+	if err != nil {
+		return err
+	}
+
 	// make parent dirs
-	if err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err != nil {
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0700); err != nil {
 		return err
 	}
 
-	f, err := os.Create(fullPath)
+	if c.migration != nil && c.migration.Backup == "" {
+		if _, err := backupConfig(fullPath, c.migration); err != nil {
+			return err
+		}
+	}
+
+	// config file may contain secrets, so only the owner could access it.
+	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, configFileMode)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	return toml.NewEncoder(f).Encode(c)
+	// OpenFile will not change the permission of existing file.
+	if err := f.Chmod(configFileMode); err != nil {
+		return err
+	}
+
+	return toml.NewEncoder(f).Encode(out)
+}
+
+// IsEncrypted returns whether secrets in config file are encrypted.
+func (c *Config) IsEncrypted() bool {
+	return c.Encryption != nil
+}
+
+// Encrypt enables encryption of secrets via key derived from secret.
+//
+// Secrets will be encrypted while writing config file.
+func (c *Config) Encrypt(secret []byte) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Encryption != nil && c.key == nil {
+		return ErrSecretEncrypted
+	}
+
+	e, key, err := newEncryption(secret)
+	if err != nil {
+		return err
+	}
+
+	c.Encryption = e
+	c.key = key
+	return nil
+}
+
+// Decrypt disables encryption of secrets.
+//
+// Secrets will be written in plain text while writing config file.
+func (c *Config) Decrypt() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Encryption != nil && c.key == nil {
+		return ErrSecretEncrypted
+	}
+
+	c.Encryption = nil
+	c.key = nil
+	return nil
+}
+
+// decrypt decrypts all secrets via key derived from secret.
+func (c *Config) decrypt(secret []byte) error {
+	key, err := c.Encryption.deriveKey(secret)
+	if err != nil {
+		return err
+	}
+
+	for name, prof := range c.Profiles {
+		prof, err = prof.mapSecrets(func(value string) (string, error) {
+			if !isEncrypted(value) {
+				return value, nil
+			}
+			return decryptSecret(key, value)
+		})
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		c.Profiles[name] = prof
+	}
+
+	c.key = key
+	return nil
+}
+
+// encrypted returns a copy of config with all secrets encrypted.
+//
+// If the config is encrypted but the key is not loaded, ErrSecretEncrypted
+// will be returned for any secret in plain text, so that new secrets will not
+// be written without encryption.
+//
+// encrypted must be called with c locked.
+func (c *Config) encrypted() (*Config, error) {
+	out := &Config{
+		Version:    c.Version,
+		Encryption: c.Encryption,
+		Profiles:   c.Profiles,
+		Aliases:    c.Aliases,
+	}
+	if c.Encryption == nil {
+		return out, nil
+	}
+	if c.key == nil {
+		for name, prof := range c.Profiles {
+			_, err := prof.mapSecrets(func(value string) (string, error) {
+				if isEncrypted(value) {
+					return value, nil
+				}
+				return "", ErrSecretEncrypted
+			})
+			if err != nil {
+				return nil, fmt.Errorf("profile %s: %w", name, err)
+			}
+		}
+		return out, nil
+	}
+
+	out.Profiles = make(map[string]Profile, len(c.Profiles))
+	for name, prof := range c.Profiles {
+		prof, err := prof.mapSecrets(func(value string) (string, error) {
+			if isEncrypted(value) {
+				return value, nil
+			}
+			return encryptSecret(c.key, value)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		out.Profiles[name] = prof
+	}
+	return out, nil
 }
 
 func expandHomeDir(path string) (string, error) {
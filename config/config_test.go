@@ -0,0 +1,49 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_WriteToFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permission is not supported on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "byctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.toml")
+	// Existing config file with loose permission should be fixed.
+	err = ioutil.WriteFile(path, []byte("version = 1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := New()
+	_ = cfg.AddProfile("test", Profile{Connection: "s3://bucket?credential=hmac:ak:sk"})
+	err = cfg.WriteToFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	loaded, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, cfg.Profiles, loaded.Profiles)
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	// maskedSecret is used to replace secrets while displaying profiles.
+	maskedSecret = "******"
+
+	credentialParamKey = "credential"
+)
+
+// Credential describes where the credential of a profile comes from.
+//
+// The resolved credential will be `<protocol>:<value>`, which is the same as
+// the credential pair in connection string, for example, `hmac:ak:sk`.
+// Only one of Value, Env, File and Command should be set.
+type Credential struct {
+	// Protocol is the credential protocol supported by services, like hmac,
+	// apikey, basic and so on.
+	Protocol string `json:"protocol,omitempty" toml:"protocol,omitempty"`
+	// Value is the credential value in plain text.
+	Value string `json:"value,omitempty" toml:"value,omitempty"`
+	// Env is the name of environment variable that holds the credential value.
+	Env string `json:"env,omitempty" toml:"env,omitempty"`
+	// File is the path of file that holds the credential value.
+	File string `json:"file,omitempty" toml:"file,omitempty"`
+	// Command is an external credential helper command, whose stdout will be
+	// used as the credential value.
+	Command string `json:"command,omitempty" toml:"command,omitempty"`
+}
+
+// Resolve returns the credential string used in connection string.
+func (c *Credential) Resolve() (string, error) {
+	if c.Protocol == "" {
+		return "", errors.New("credential protocol is empty")
+	}
+
+	var value string
+	switch {
+	case c.Value != "":
+		if isEncrypted(c.Value) {
+			return "", ErrSecretEncrypted
+		}
+		value = c.Value
+	case c.Env != "":
+		v, ok := os.LookupEnv(c.Env)
+		if !ok {
+			return "", fmt.Errorf("credential env %s is not set", c.Env)
+		}
+		value = v
+	case c.File != "":
+		path, err := expandHomeDir(c.File)
+		if err != nil {
+			return "", err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read credential file %s: %w", c.File, err)
+		}
+		value = string(content)
+	case c.Command != "":
+		v, err := runCredentialCommand(c.Command)
+		if err != nil {
+			return "", fmt.Errorf("run credential command: %w", err)
+		}
+		value = v
+	default:
+		// Protocols like env don't need any value.
+		return c.Protocol, nil
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return c.Protocol, nil
+	}
+	return c.Protocol + ":" + value, nil
+}
+
+// Masked returns a copy of credential with secret value masked.
+func (c *Credential) Masked() *Credential {
+	x := *c
+	if x.Value != "" {
+		x.Value = maskedSecret
+	}
+	return &x
+}
+
+func runCredentialCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// maskConnection masks the secret part of credential in connection string.
+//
+// For example, `s3://bucket?credential=hmac:ak:sk` will be masked as
+// `s3://bucket?credential=hmac:******`.
+func maskConnection(conn string) string {
+	conn, _ = mapConnectionCredential(conn, func(value string) (string, error) {
+		return maskCredentialValue(value), nil
+	})
+	return conn
+}
+
+// maskCredentialValue masks the secret in credential value like
+// `hmac:ak:sk`, the protocol is kept.
+func maskCredentialValue(value string) string {
+	protocol := strings.SplitN(value, ":", 2)
+	if len(protocol) < 2 {
+		return value
+	}
+	return protocol[0] + ":" + maskedSecret
+}
+
+// mapConnectionCredential replaces the credential value in connection string
+// with the output of fn.
+func mapConnectionCredential(conn string, fn func(value string) (string, error)) (string, error) {
+	idx := strings.Index(conn, "?")
+	if idx == -1 {
+		return conn, nil
+	}
+
+	params := strings.Split(conn[idx+1:], "&")
+	for i, param := range params {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 || kv[0] != credentialParamKey {
+			continue
+		}
+
+		value, err := fn(kv[1])
+		if err != nil {
+			return "", err
+		}
+		params[i] = credentialParamKey + "=" + value
+	}
+	return conn[:idx+1] + strings.Join(params, "&"), nil
+}
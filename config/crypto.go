@@ -0,0 +1,183 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// EnvConfigPassword is the environment variable that holds the password
+	// used to encrypt and decrypt secrets in config file.
+	EnvConfigPassword = "BEYOND_CTL_CONFIG_PASSWORD"
+	// EnvConfigKeyFile is the environment variable that holds the path of key
+	// file used to encrypt and decrypt secrets in config file.
+	EnvConfigKeyFile = "BEYOND_CTL_CONFIG_KEY_FILE"
+
+	// encryptedPrefix is the prefix of all encrypted values.
+	encryptedPrefix = "enc:"
+
+	kdfPBKDF2SHA256         = "pbkdf2-sha256"
+	defaultKDFIterations    = 200000
+	encryptionKeySize       = 32 // AES-256
+	encryptionSaltSize      = 16
+	encryptionVerifierValue = "beyond-ctl"
+)
+
+// ErrSecretEncrypted will be returned while using an encrypted secret without
+// password or key file provided.
+var ErrSecretEncrypted = fmt.Errorf("secret is encrypted, please set %s or %s", EnvConfigPassword, EnvConfigKeyFile)
+
+// Encryption describes how secrets in config file are encrypted.
+//
+// Secrets are encrypted by AES-256-GCM, and the key is derived from password
+// or key file content via KDF.
+type Encryption struct {
+	KDF        string `json:"kdf" toml:"kdf"`
+	Salt       string `json:"salt" toml:"salt"`
+	Iterations int    `json:"iterations" toml:"iterations"`
+	// Verifier is a known value encrypted by the key, which is used to check
+	// whether the provided password is correct.
+	Verifier string `json:"verifier" toml:"verifier"`
+}
+
+// LoadSecretFromEnv loads password or key file content from environment.
+//
+// ok will be false if neither of them is provided.
+func LoadSecretFromEnv() (secret []byte, ok bool, err error) {
+	if v := os.Getenv(EnvConfigPassword); v != "" {
+		return []byte(v), true, nil
+	}
+
+	if v := os.Getenv(EnvConfigKeyFile); v != "" {
+		path, err := expandHomeDir(v)
+		if err != nil {
+			return nil, false, err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, false, fmt.Errorf("read key file %s: %w", v, err)
+		}
+		if len(content) == 0 {
+			return nil, false, fmt.Errorf("key file %s is empty", v)
+		}
+		return content, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func newEncryption(secret []byte) (*Encryption, []byte, error) {
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, nil, err
+	}
+
+	e := &Encryption{
+		KDF:        kdfPBKDF2SHA256,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Iterations: defaultKDFIterations,
+	}
+
+	key, err := e.deriveKey(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e.Verifier, err = encryptSecret(key, encryptionVerifierValue)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, key, nil
+}
+
+// deriveKey derives the encryption key from secret and checks it against
+// the verifier if exists.
+func (e *Encryption) deriveKey(secret []byte) ([]byte, error) {
+	if e.KDF != kdfPBKDF2SHA256 {
+		return nil, fmt.Errorf("kdf %s is not supported", e.KDF)
+	}
+	if e.Iterations <= 0 {
+		return nil, fmt.Errorf("kdf iterations %d is invalid", e.Iterations)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(e.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("decode salt: %w", err)
+	}
+
+	key := pbkdf2.Key(secret, salt, e.Iterations, encryptionKeySize, sha256.New)
+
+	if e.Verifier != "" {
+		v, err := decryptSecret(key, e.Verifier)
+		if err != nil || v != encryptionVerifierValue {
+			return nil, errors.New("password or key file is incorrect")
+		}
+	}
+	return key, nil
+}
+
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// encryptSecret encrypts value via AES-GCM, the output will be
+// `enc:<base64(nonce|ciphertext)>`.
+func encryptSecret(key []byte, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	out := gcm.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(out), nil
+}
+
+func decryptSecret(key []byte, value string) (string, error) {
+	if !isEncrypted(value) {
+		return "", errors.New("value is not encrypted")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decode encrypted value: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	out, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+	return string(out), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryption_DeriveKey(t *testing.T) {
+	cases := []struct {
+		name   string
+		e      Encryption
+		secret string
+		key    string
+		hasErr bool
+	}{
+		{
+			// Test vector from RFC 7914, section 11, truncated to key size.
+			name:   "rfc 7914",
+			e:      Encryption{KDF: kdfPBKDF2SHA256, Salt: "c2FsdA==", Iterations: 1},
+			secret: "passwd",
+			key:    "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc",
+		},
+		{
+			name: "with verifier",
+			e: Encryption{
+				KDF:        kdfPBKDF2SHA256,
+				Salt:       "MDEyMzQ1Njc4OWFiY2RlZg==",
+				Iterations: 1000,
+				Verifier:   "enc:MDEyMzQ1Njc4OWFiCcrH6DEIFoWTYgrSeGGSyaZPiyTAo57b3Sc=",
+			},
+			secret: "password",
+			key:    "8514638175a45bc45eb1f22f04ff7d27f4f8be480498c455ff4b494ce8d1e7d2",
+		},
+		{
+			name: "wrong password",
+			e: Encryption{
+				KDF:        kdfPBKDF2SHA256,
+				Salt:       "MDEyMzQ1Njc4OWFiY2RlZg==",
+				Iterations: 1000,
+				Verifier:   "enc:MDEyMzQ1Njc4OWFiCcrH6DEIFoWTYgrSeGGSyaZPiyTAo57b3Sc=",
+			},
+			secret: "wrong",
+			hasErr: true,
+		},
+		{
+			name:   "unsupported kdf",
+			e:      Encryption{KDF: "md5", Salt: "c2FsdA==", Iterations: 1},
+			secret: "passwd",
+			hasErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		key, err := tt.e.deriveKey([]byte(tt.secret))
+		if tt.hasErr {
+			assert.NotNil(t, err, tt.name)
+			continue
+		}
+
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, tt.key, hex.EncodeToString(key), tt.name)
+	}
+}
+
+func TestDecryptSecret(t *testing.T) {
+	key := make([]byte, encryptionKeySize)
+	value, err := encryptSecret(key, "ak:sk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := decryptSecret(key, value)
+	assert.Nil(t, err)
+	assert.Equal(t, "ak:sk", got)
+
+	// Wrong key should be rejected.
+	wrong := make([]byte, encryptionKeySize)
+	wrong[0] = 1
+	_, err = decryptSecret(wrong, value)
+	assert.NotNil(t, err)
+
+	// Tampered ciphertext should be rejected.
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 1
+	_, err = decryptSecret(key, encryptedPrefix+base64.StdEncoding.EncodeToString(data))
+	assert.NotNil(t, err)
+}
+
+func TestConfig_Encrypt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "byctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+
+	cfg := New()
+	_ = cfg.AddProfile("conn", Profile{
+		Connection: "s3://bucket?credential=hmac:ak:sk&endpoint=https:s3.example.com",
+	})
+	_ = cfg.AddProfile("cred", Profile{
+		Service:    "s3",
+		Name:       "bucket",
+		Credential: &Credential{Protocol: "hmac", Value: "ak:sk"},
+	})
+
+	err = cfg.Encrypt([]byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.WriteToFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, strings.Contains(string(content), "ak:sk"), "secrets should be encrypted")
+
+	// Secrets should be kept encrypted without password.
+	_ = os.Unsetenv(EnvConfigPassword)
+	loaded, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loaded.Profiles["conn"].ConnectionString()
+	assert.Equal(t, ErrSecretEncrypted, err)
+
+	// Encrypted secrets could be written back without password, but new
+	// secrets should not be written in plain text.
+	err = loaded.WriteToFile(path)
+	assert.Nil(t, err)
+	_ = loaded.AddProfile("new", Profile{Connection: "s3://c?credential=hmac:ak2:sk2"})
+	err = loaded.WriteToFile(path)
+	assert.ErrorIs(t, err, ErrSecretEncrypted)
+	content, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, strings.Contains(string(content), "sk2"), "new secrets should not be written")
+	assert.True(t, strings.Contains(string(content), "encryption"), "config should not be truncated")
+
+	// Wrong password should be rejected.
+	_ = os.Setenv(EnvConfigPassword, "wrong")
+	_, err = LoadFromFile(path)
+	assert.NotNil(t, err)
+
+	_ = os.Setenv(EnvConfigPassword, "password")
+	defer os.Unsetenv(EnvConfigPassword)
+	loaded, err = LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, cfg.Profiles, loaded.Profiles)
+
+	conn, err := loaded.Profiles["cred"].ConnectionString()
+	assert.Nil(t, err)
+	assert.Equal(t, "s3://bucket?credential=hmac:ak:sk", conn)
+
+	// Decrypt should write secrets in plain text.
+	err = loaded.Decrypt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = loaded.WriteToFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.Contains(string(content), "ak:sk"))
+	assert.False(t, strings.Contains(string(content), "encryption"))
+}
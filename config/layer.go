@@ -0,0 +1,248 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+)
+
+const (
+	// ProjectConfigName is the name of project config file, which will be
+	// discovered from current dir up to the root dir.
+	ProjectConfigName = ".byctl.toml"
+
+	// EnvSystemConfig could be used to override the path of system config.
+	EnvSystemConfig = "BEYOND_CTL_SYSTEM_CONFIG"
+
+	OriginSystem  = "system"
+	OriginUser    = "user"
+	OriginProject = "project"
+	OriginEnv     = "env"
+)
+
+// LoadLayered loads config from all layers and merges them.
+//
+// Layers are merged in the following order, and later layers take precedence
+// over earlier ones:
+// - system config: /etc/byctl/config.toml
+// - user config: the file at userPath
+// - project config: .byctl.toml discovered in current dir tree
+// - environment variables
+//
+// Project config comes from the dir tree being worked on, which may not be
+// trusted, so it could only set aliases and options of existing profiles.
+// Connections, endpoints and credentials could not be changed by it.
+//
+// Only user config will be created if not exist.
+func LoadLayered(userPath string, loadEnv bool) (*Config, error) {
+	return loadLayered(userPath, loadEnv, false)
+}
+
+// LoadLayeredReadOnly is the same as LoadLayered, but never creates or
+// migrates the user config, which is used by shell completion.
+func LoadLayeredReadOnly(userPath string, loadEnv bool) (*Config, error) {
+	return loadLayered(userPath, loadEnv, true)
+}
+
+func loadLayered(userPath string, loadEnv, readOnly bool) (*Config, error) {
+	cfg := New()
+
+	systemPath := SystemConfigPath()
+	system, err := loadLayer(systemPath)
+	if err != nil {
+		return nil, fmt.Errorf("load system config %s: %w", systemPath, err)
+	}
+	cfg.merge(system, OriginSystem+":"+systemPath)
+
+	var user *Config
+	if readOnly {
+		user, err = loadLayer(userPath)
+	} else {
+		user, err = LoadFromFile(userPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fullUserPath, err := expandHomeDir(userPath)
+	if err != nil {
+		return nil, err
+	}
+	cfg.merge(user, OriginUser+":"+fullUserPath)
+
+	projectPath, err := findProjectConfig()
+	if err != nil {
+		return nil, err
+	}
+	if projectPath != "" {
+		project, err := loadLayer(projectPath)
+		if err != nil {
+			return nil, fmt.Errorf("load project config %s: %w", projectPath, err)
+		}
+		if err = cfg.mergeProject(project, OriginProject+":"+projectPath); err != nil {
+			return nil, fmt.Errorf("load project config %s: %w", projectPath, err)
+		}
+	}
+
+	if loadEnv {
+		cfg.MergeProfileFromEnv()
+	}
+	return cfg, nil
+}
+
+// SystemConfigPath returns the path of system config.
+func SystemConfigPath() string {
+	if v := os.Getenv(EnvSystemConfig); v != "" {
+		return v
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "byctl", "config.toml")
+	}
+	return "/etc/byctl/config.toml"
+}
+
+// Origin returns where the item with key comes from, for example,
+// `user:/home/user/.config/byctl/config.toml`.
+//
+// key is the full name of an item like `profile.<name>`. Empty string will be
+// returned if the origin is unknown.
+func (c *Config) Origin(key string) string {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.origins[key]
+}
+
+// OriginKeys returns keys of all items with known origin in order.
+func (c *Config) OriginKeys() []string {
+	c.Lock()
+	defer c.Unlock()
+
+	keys := make([]string, 0, len(c.origins))
+	for k := range c.origins {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// merge merges all items in o into c.
+func (c *Config) merge(o *Config, origin string) {
+	if o == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	for name, prof := range o.Profiles {
+		c.Profiles[name] = prof
+		c.setOrigin(profileOriginKey(name), origin)
+	}
+	for name, def := range o.Aliases {
+		if c.Aliases == nil {
+			c.Aliases = make(map[string]string)
+		}
+		c.Aliases[name] = def
+		c.setOrigin(aliasOriginKey(name), origin)
+	}
+}
+
+// mergeProject merges aliases and options of profiles in project config o into
+// c. Other fields of profiles are not allowed, and options will replace the
+// options of the profile with the same name in c.
+func (c *Config) mergeProject(o *Config, origin string) error {
+	if o == nil {
+		return nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	for name, prof := range o.Profiles {
+		if !reflect.DeepEqual(prof, Profile{Options: prof.Options}) {
+			return fmt.Errorf("profile %s: only options could be set in project config", name)
+		}
+		p, ok := c.Profiles[name]
+		if !ok {
+			return fmt.Errorf("profile %s: profile should be defined in system or user config", name)
+		}
+		p.Options = prof.Options
+		c.Profiles[name] = p
+		c.setOrigin(profileOptionsOriginKey(name), origin)
+	}
+	for name, def := range o.Aliases {
+		if c.Aliases == nil {
+			c.Aliases = make(map[string]string)
+		}
+		c.Aliases[name] = def
+		c.setOrigin(aliasOriginKey(name), origin)
+	}
+	return nil
+}
+
+// setOrigin must be called with c locked.
+func (c *Config) setOrigin(key, origin string) {
+	if c.origins == nil {
+		c.origins = make(map[string]string)
+	}
+	c.origins[key] = origin
+}
+
+func profileOriginKey(name string) string {
+	return "profile." + name
+}
+
+func profileOptionsOriginKey(name string) string {
+	return profileOriginKey(name) + ".options"
+}
+
+func aliasOriginKey(name string) string {
+	return "alias." + name
+}
+
+// loadLayer loads config at path without creating or rewriting it.
+//
+// nil will be returned if config file not exist.
+func loadLayer(path string) (*Config, error) {
+	fullPath, err := expandHomeDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(fullPath)
+	if err != nil && os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(data)
+}
+
+// findProjectConfig finds project config from current dir up to the root dir.
+//
+// Empty string will be returned if not found.
+func findProjectConfig() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectConfigName)
+		// Dirs that could not be accessed will be skipped.
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			return path, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
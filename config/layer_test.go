@@ -0,0 +1,140 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadLayered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "byctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(path, content string) {
+		err := ioutil.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	systemPath := filepath.Join(dir, "system.toml")
+	write(systemPath, `version = 1
+[profile.system]
+connection = "s3://system"
+[profile.shared]
+connection = "s3://from-system"
+`)
+	userPath := filepath.Join(dir, "user.toml")
+	write(userPath, `version = 1
+[profile.user]
+connection = "s3://user"
+[profile.shared]
+connection = "s3://from-user"
+`)
+	projectDir := filepath.Join(dir, "project")
+	workDir := filepath.Join(projectDir, "sub", "dir")
+	if err = os.MkdirAll(workDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	projectPath := filepath.Join(projectDir, ProjectConfigName)
+	write(projectPath, `version = 1
+[profile.shared.options]
+workers = 8
+[alias]
+l = "ls -l"
+`)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err = os.Chdir(workDir); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = os.Setenv(EnvSystemConfig, systemPath)
+	defer os.Unsetenv(EnvSystemConfig)
+	_ = os.Setenv(profileEnvPrefix+"env", "s3://env")
+	defer os.Unsetenv(profileEnvPrefix + "env")
+
+	cfg, err := LoadLayered(userPath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		conn   string
+		origin string
+	}{
+		{"system", "s3://system", OriginSystem + ":" + systemPath},
+		{"user", "s3://user", OriginUser + ":" + userPath},
+		{"shared", "s3://from-user", OriginUser + ":" + userPath},
+		{"env", "s3://env", OriginEnv + ":" + profileEnvPrefix + "env"},
+	}
+	for _, tt := range cases {
+		prof, ok := cfg.GetProfile(tt.name)
+		assert.True(t, ok, tt.name)
+		assert.Equal(t, tt.conn, prof.Connection, tt.name)
+		assert.Equal(t, tt.origin, cfg.Origin(profileOriginKey(tt.name)), tt.name)
+	}
+
+	// Project config could only set options and aliases.
+	prof, _ := cfg.GetProfile("shared")
+	assert.Equal(t, &ProfileOptions{Workers: 8}, prof.Options)
+	assert.Equal(t, OriginProject+":"+projectPath, cfg.Origin(profileOptionsOriginKey("shared")))
+	def, _ := cfg.GetAlias("l")
+	assert.Equal(t, "ls -l", def)
+
+	restricted := []string{
+		"[profile.shared]\nconnection = \"s3://from-project\"\n",
+		"[profile.shared]\nendpoint = \"https:evil.example.com\"\n",
+		"[profile.shared.credential]\nprotocol = \"hmac\"\ncommand = \"touch pwned\"\n",
+		"[profile.project.options]\nworkers = 8\n",
+	}
+	for _, content := range restricted {
+		write(projectPath, "version = 1\n"+content)
+		_, err = LoadLayered(userPath, true)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestLoadLayeredReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "byctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_ = os.Setenv(EnvSystemConfig, filepath.Join(dir, "system.toml"))
+	defer os.Unsetenv(EnvSystemConfig)
+
+	// User config should not be created.
+	userPath := filepath.Join(dir, "user.toml")
+	cfg, err := LoadLayeredReadOnly(userPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, cfg.Profiles)
+	_, err = os.Stat(userPath)
+	assert.True(t, os.IsNotExist(err))
+
+	content := "version = 1\n\n[profile.user]\nconnection = \"s3://user\"\n"
+	err = ioutil.WriteFile(userPath, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadLayeredReadOnly(userPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prof, _ := cfg.GetProfile("user")
+	assert.Equal(t, "s3://user", prof.Connection)
+}
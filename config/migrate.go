@@ -0,0 +1,279 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+const versionKey = "version"
+
+// Migration upgrades the raw config from version From to From+1.
+//
+// Migrate works on the raw config decoded from file instead of Config, so
+// that it will not be affected by later changes of Config.
+type Migration struct {
+	From        int
+	Description string
+	Migrate     func(raw map[string]interface{}) error
+}
+
+// migrations holds all registered migrations, indexed by From.
+var migrations = make(map[int]Migration)
+
+// registerMigration registers a migration, it should be called in init.
+func registerMigration(m Migration) {
+	if _, ok := migrations[m.From]; ok {
+		panic(fmt.Sprintf("migration from config ver. %d has been registered", m.From))
+	}
+	migrations[m.From] = m
+}
+
+// MigrationPlan describes how a config file will be upgraded.
+type MigrationPlan struct {
+	From       int
+	To         int
+	Migrations []Migration
+
+	// Original and Migrated are the content of config file before and after
+	// migration.
+	Original []byte
+	Migrated []byte
+	// Backup is the path of the original config file once it has been
+	// backed up.
+	Backup string
+}
+
+// NeedMigrate returns whether the config file needs to be migrated.
+func (p *MigrationPlan) NeedMigrate() bool {
+	return p.From != p.To
+}
+
+// DropsComments returns whether comments in the original config file will be
+// dropped by migration, since the migrated config is re-encoded from the
+// decoded content.
+func (p *MigrationPlan) DropsComments() bool {
+	return p.NeedMigrate() && hasComments(p.Original)
+}
+
+// Masked returns the content of config file before and after migration with
+// secrets masked, so that they could be displayed safely.
+//
+// Both of them are re-encoded, so they only differ in the migrated items.
+func (p *MigrationPlan) Masked() (original, migrated []byte, err error) {
+	original, err = maskConfigData(p.Original)
+	if err != nil {
+		return nil, nil, err
+	}
+	migrated, err = maskConfigData(p.Migrated)
+	if err != nil {
+		return nil, nil, err
+	}
+	return original, migrated, nil
+}
+
+func maskConfigData(data []byte) ([]byte, error) {
+	raw := make(map[string]interface{})
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	maskRawSecrets(raw)
+
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(raw); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// maskRawSecrets masks credentials in raw config decoded from file, which may
+// be written by any version of byctl.
+func maskRawSecrets(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			switch item := item.(type) {
+			case string:
+				switch k {
+				case "connection":
+					v[k] = maskConnection(item)
+				case credentialParamKey:
+					v[k] = maskCredentialValue(item)
+				}
+			case map[string]interface{}:
+				if value, ok := item["value"].(string); ok && k == credentialParamKey && value != "" {
+					item["value"] = maskedSecret
+				}
+				maskRawSecrets(item)
+			default:
+				maskRawSecrets(item)
+			}
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			maskRawSecrets(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			maskRawSecrets(item)
+		}
+	}
+}
+
+// hasComments checks whether TOML data contains comments. Quotes are tracked
+// per line, so that `#` in single line strings will not be treated as
+// comments.
+func hasComments(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var quote byte
+		for i := 0; i < len(line); i++ {
+			ch := line[i]
+			switch {
+			case quote == 0 && ch == '#':
+				return true
+			case quote == 0 && (ch == '"' || ch == '\''):
+				quote = ch
+			case quote == '"' && ch == '\\':
+				i++
+			case ch == quote:
+				quote = 0
+			}
+		}
+	}
+	return false
+}
+
+// PlanMigration reads config file at path and calculates how it will be
+// upgraded to current Version without changing the file.
+func PlanMigration(path string) (*MigrationPlan, error) {
+	fullPath, err := expandHomeDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	return planMigration(data, Version, migrations)
+}
+
+// MigrateFile upgrades config file at path to current Version.
+//
+// The original file will be backed up as `<path>.v<version>.bak` before
+// rewriting.
+func MigrateFile(path string) (plan *MigrationPlan, backup string, err error) {
+	fullPath, err := expandHomeDir(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	plan, err = PlanMigration(fullPath)
+	if err != nil {
+		return nil, "", err
+	}
+	if !plan.NeedMigrate() {
+		return plan, "", nil
+	}
+
+	backup, err = applyMigration(fullPath, plan)
+	if err != nil {
+		return nil, "", err
+	}
+	return plan, backup, nil
+}
+
+func planMigration(data []byte, target int, registry map[int]Migration) (*MigrationPlan, error) {
+	raw := make(map[string]interface{})
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	// Config without version will be treated as the current version.
+	from := target
+	if v, ok := raw[versionKey]; ok {
+		n, ok := v.(int64)
+		if !ok {
+			return nil, fmt.Errorf("config version %v is invalid", v)
+		}
+		from = int(n)
+	}
+
+	plan := &MigrationPlan{
+		From:     from,
+		To:       target,
+		Original: data,
+		Migrated: data,
+	}
+	if from > target {
+		return nil, fmt.Errorf("config ver. %d is newer than supported ver. %d, please upgrade byctl", from, target)
+	}
+	if from == target {
+		return plan, nil
+	}
+
+	for v := from; v < target; v++ {
+		m, ok := registry[v]
+		if !ok {
+			return nil, fmt.Errorf("config ver. %d is expected, migration from ver. %d is not supported", target, v)
+		}
+		if err := m.Migrate(raw); err != nil {
+			return nil, fmt.Errorf("migrate config from ver. %d: %w", v, err)
+		}
+		raw[versionKey] = int64(v + 1)
+		plan.Migrations = append(plan.Migrations, m)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(raw); err != nil {
+		return nil, err
+	}
+	plan.Migrated = buf.Bytes()
+	return plan, nil
+}
+
+// applyMigration backs up the original config file and writes the migrated one.
+func applyMigration(fullPath string, plan *MigrationPlan) (backup string, err error) {
+	backup, err = backupConfig(fullPath, plan)
+	if err != nil {
+		return "", err
+	}
+
+	// Write into a temp file and rename it, so that config file will not be
+	// broken if write failed.
+	f, err := ioutil.TempFile(filepath.Dir(fullPath), filepath.Base(fullPath)+".tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(plan.Migrated)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err = os.Chmod(f.Name(), configFileMode); err != nil {
+		return "", err
+	}
+	if err = os.Rename(f.Name(), fullPath); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// backupConfig writes the original config file to `<path>.v<version>.bak`.
+func backupConfig(fullPath string, plan *MigrationPlan) (string, error) {
+	backup := fmt.Sprintf("%s.v%d.bak", fullPath, plan.From)
+	err := ioutil.WriteFile(backup, plan.Original, configFileMode)
+	if err != nil {
+		return "", fmt.Errorf("backup config: %w", err)
+	}
+	plan.Backup = backup
+	return backup, nil
+}
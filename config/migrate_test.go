@@ -0,0 +1,184 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanMigration(t *testing.T) {
+	registry := map[int]Migration{
+		1: {
+			From:        1,
+			Description: "rename connection to conn",
+			Migrate: func(raw map[string]interface{}) error {
+				profiles, _ := raw["profile"].(map[string]interface{})
+				for _, v := range profiles {
+					prof := v.(map[string]interface{})
+					prof["conn"] = prof["connection"]
+					delete(prof, "connection")
+				}
+				return nil
+			},
+		},
+		2: {
+			From:        2,
+			Description: "add default profile",
+			Migrate: func(raw map[string]interface{}) error {
+				raw["default"] = "test"
+				return nil
+			},
+		},
+	}
+
+	data := []byte(`version = 1
+
+[profile.test]
+connection = "s3://bucket"
+`)
+
+	plan, err := planMigration(data, 3, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, plan.NeedMigrate())
+	assert.Equal(t, 1, plan.From)
+	assert.Equal(t, 3, plan.To)
+	assert.Equal(t, 2, len(plan.Migrations))
+	assert.Equal(t, `default = "test"
+version = 3
+
+[profile]
+  [profile.test]
+    conn = "s3://bucket"
+`, string(plan.Migrated))
+
+	assert.False(t, plan.DropsComments())
+
+	plan, err = planMigration(data, 1, registry)
+	assert.Nil(t, err)
+	assert.False(t, plan.NeedMigrate())
+
+	commented := []byte(`# profiles of byctl
+version = 1
+
+[profile.test]
+connection = "s3://bucket" # production
+`)
+	plan, err = planMigration(commented, 3, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, plan.DropsComments())
+
+	_, err = planMigration([]byte("version = 4\n"), 3, registry)
+	assert.NotNil(t, err, "newer version")
+
+	_, err = planMigration([]byte("version = 0\n"), 3, registry)
+	assert.NotNil(t, err, "migration not registered")
+}
+
+func TestMigrationPlan_Masked(t *testing.T) {
+	plan := &MigrationPlan{
+		Original: []byte(`version = 0
+
+[profile.conn]
+connection = "s3://bucket?credential=hmac:ak:sk"
+
+[profile.cred]
+service = "s3"
+[profile.cred.params]
+credential = "hmac:ak:sk"
+[profile.cred.credential]
+protocol = "hmac"
+value = "ak:sk"
+`),
+		Migrated: []byte(`version = 1
+
+[profile.conn]
+connection = "s3://bucket?credential=hmac:ak:sk"
+`),
+	}
+
+	original, migrated, err := plan.Masked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range [][]byte{original, migrated} {
+		assert.False(t, strings.Contains(string(v), "ak:sk"), string(v))
+	}
+	assert.True(t, strings.Contains(string(original), `credential = "hmac:******"`))
+	assert.True(t, strings.Contains(string(migrated), `connection = "s3://bucket?credential=hmac:******"`))
+}
+
+func TestLoadFromFile_Migrate(t *testing.T) {
+	migrations[0] = Migration{
+		From:        0,
+		Description: "test migration",
+		Migrate: func(raw map[string]interface{}) error {
+			return nil
+		},
+	}
+	defer delete(migrations, 0)
+
+	dir, err := ioutil.TempDir("", "byctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+
+	data := []byte("# old config\nversion = 0\n")
+	err = ioutil.WriteFile(path, data, configFileMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Loading should not rewrite config file.
+	cfg, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Version, cfg.Version)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, content)
+
+	// Writing should back up the original config first.
+	err = cfg.WriteToFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup := cfg.Migration().Backup
+	assert.Equal(t, path+".v0.bak", backup)
+	content, err = ioutil.ReadFile(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, content)
+}
+
+func TestHasComments(t *testing.T) {
+	cases := []struct {
+		input  string
+		expect bool
+	}{
+		{"version = 1\n", false},
+		{"# comment\nversion = 1\n", true},
+		{"version = 1 # comment\n", true},
+		{`connection = "s3://bucket#key"`, false},
+		{`connection = 's3://bucket#key'`, false},
+		{`name = "a\"#b"`, false},
+		{`name = "a" # "b"`, true},
+	}
+
+	for _, tt := range cases {
+		assert.Equal(t, tt.expect, hasComments([]byte(tt.input)), tt.input)
+	}
+}
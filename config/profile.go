@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -13,8 +14,208 @@ const (
 	profileEnvPrefix = "BEYOND_CTL_PROFILE_"
 )
 
+// Profile is the storage service that could be referred by name.
+//
+// A profile could be described by a full connection string, or by structured
+// fields. Structured fields will override the parameters with the same key in
+// Connection, so that secrets could be stored separately via Credential.
 type Profile struct {
-	Connection string `json:"connection" toml:"connection"`
+	Connection string `json:"connection,omitempty" toml:"connection,omitempty"`
+
+	// Service is the service type, like s3, gcs and so on.
+	Service string `json:"service,omitempty" toml:"service,omitempty"`
+	// Name is the name of storage, like bucket name.
+	Name string `json:"name,omitempty" toml:"name,omitempty"`
+	// WorkDir is the work dir of storage.
+	WorkDir  string `json:"work_dir,omitempty" toml:"work_dir,omitempty"`
+	Endpoint string `json:"endpoint,omitempty" toml:"endpoint,omitempty"`
+	Location string `json:"location,omitempty" toml:"location,omitempty"`
+	// Params holds other service specific parameters in connection string.
+	Params map[string]string `json:"params,omitempty" toml:"params,omitempty"`
+
+	Credential *Credential `json:"credential,omitempty" toml:"credential,omitempty"`
+
+	// Options holds default options of commands that use this profile.
+	Options *ProfileOptions `json:"options,omitempty" toml:"options,omitempty"`
+}
+
+// ProfileOptions holds default options that will be applied automatically
+// while this profile is used. Options set via flags or environment variables
+// take precedence over them.
+//
+// Options related to read will be picked from the source profile, and options
+// related to write will be picked from the target profile.
+type ProfileOptions struct {
+	Workers            int    `json:"workers,omitempty" toml:"workers,omitempty"`
+	ReadSpeedLimit     string `json:"read_speed_limit,omitempty" toml:"read_speed_limit,omitempty"`
+	WriteSpeedLimit    string `json:"write_speed_limit,omitempty" toml:"write_speed_limit,omitempty"`
+	MultipartThreshold string `json:"multipart_threshold,omitempty" toml:"multipart_threshold,omitempty"`
+	PartSize           string `json:"part_size,omitempty" toml:"part_size,omitempty"`
+	PartConcurrency    int    `json:"part_concurrency,omitempty" toml:"part_concurrency,omitempty"`
+	StorageClass       string `json:"storage_class,omitempty" toml:"storage_class,omitempty"`
+	SignExpire         string `json:"sign_expire,omitempty" toml:"sign_expire,omitempty"`
+
+	// BandwidthLimit is shared by both reads and writes of this profile, it
+	// could be a schedule like `09:00-18:00=10MB,unlimited`.
+	BandwidthLimit string `json:"bandwidth_limit,omitempty" toml:"bandwidth_limit,omitempty"`
+	// MaxRPS is the max requests per second sent to this profile, it's
+	// shared by all operations in the process.
+	MaxRPS float64 `json:"max_rps,omitempty" toml:"max_rps,omitempty"`
+
+	// ReadPairs and WritePairs are service specific pairs that will be passed
+	// to read and write operations. Only string values are supported.
+	ReadPairs  map[string]string `json:"read_pairs,omitempty" toml:"read_pairs,omitempty"`
+	WritePairs map[string]string `json:"write_pairs,omitempty" toml:"write_pairs,omitempty"`
+}
+
+// ConnectionString builds the connection string of this profile.
+//
+// Structured fields override the parameters with the same key in Connection,
+// and all parameters will be URL-escaped. Secrets referred by Credential will
+// be resolved here.
+func (p Profile) ConnectionString() (string, error) {
+	conn := p.Connection
+	if hasEncryptedSecret(conn) {
+		return "", ErrSecretEncrypted
+	}
+	if conn == "" {
+		if p.Service == "" {
+			return "", errors.New("neither connection nor service is set")
+		}
+
+		workDir := p.WorkDir
+		if workDir != "" && !strings.HasPrefix(workDir, "/") {
+			workDir = "/" + workDir
+		}
+		conn = p.Service + "://" + p.Name + workDir
+	}
+
+	// Service specific params are set first, so that they could not override
+	// the structured fields.
+	params := make(map[string]string, len(p.Params)+3)
+	for k, v := range p.Params {
+		params[k] = v
+	}
+	if p.Endpoint != "" {
+		params["endpoint"] = p.Endpoint
+	}
+	if p.Location != "" {
+		params["location"] = p.Location
+	}
+	if p.Credential != nil {
+		cred, err := p.Credential.Resolve()
+		if err != nil {
+			return "", err
+		}
+		params[credentialParamKey] = cred
+	}
+
+	if len(params) == 0 {
+		return conn, nil
+	}
+	return setConnectionParams(conn, params)
+}
+
+// setConnectionParams sets params in the query of conn, existing params with
+// the same keys will be replaced.
+//
+// go-storage splits params by `&` and `=` without unescaping, so params are
+// kept as written, and values containing `&` are rejected.
+func setConnectionParams(conn string, params map[string]string) (string, error) {
+	var items []string
+	if idx := strings.Index(conn, "?"); idx != -1 {
+		if query := conn[idx+1:]; query != "" {
+			items = strings.Split(query, "&")
+		}
+		conn = conn[:idx]
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if strings.Contains(params[k], "&") {
+			return "", fmt.Errorf("value of param %s contains &, which is not supported in connection string", k)
+		}
+		item := k + "=" + params[k]
+
+		replaced := false
+		for i, v := range items {
+			if strings.SplitN(v, "=", 2)[0] != k {
+				continue
+			}
+			if replaced {
+				items[i] = ""
+			} else {
+				items[i] = item
+				replaced = true
+			}
+		}
+		if !replaced {
+			items = append(items, item)
+		}
+	}
+
+	query := make([]string, 0, len(items))
+	for _, v := range items {
+		if v != "" {
+			query = append(query, v)
+		}
+	}
+	return conn + "?" + strings.Join(query, "&"), nil
+}
+
+// mapSecrets returns a copy of profile with all secrets replaced by the
+// output of fn.
+func (p Profile) mapSecrets(fn func(value string) (string, error)) (Profile, error) {
+	conn, err := mapConnectionCredential(p.Connection, fn)
+	if err != nil {
+		return p, err
+	}
+	p.Connection = conn
+
+	if p.Credential != nil && p.Credential.Value != "" {
+		cred := *p.Credential
+		cred.Value, err = fn(cred.Value)
+		if err != nil {
+			return p, err
+		}
+		p.Credential = &cred
+	}
+	return p, nil
+}
+
+// hasEncryptedSecret checks whether the credential in connection string is
+// encrypted.
+func hasEncryptedSecret(conn string) (ok bool) {
+	_, _ = mapConnectionCredential(conn, func(value string) (string, error) {
+		if isEncrypted(value) {
+			ok = true
+		}
+		return value, nil
+	})
+	return ok
+}
+
+// Masked returns a copy of profile with all secrets masked, which is safe to
+// display.
+func (p Profile) Masked() Profile {
+	p.Connection = maskConnection(p.Connection)
+	if p.Credential != nil {
+		p.Credential = p.Credential.Masked()
+	}
+	if cred, ok := p.Params[credentialParamKey]; ok {
+		params := make(map[string]string, len(p.Params))
+		for k, v := range p.Params {
+			params[k] = v
+		}
+		params[credentialParamKey] = maskCredentialValue(cred)
+		p.Params = params
+	}
+	return p
 }
 
 func (c *Config) AddProfile(name string, prof Profile) error {
@@ -35,6 +236,51 @@ func (c *Config) AddProfile(name string, prof Profile) error {
 	return nil
 }
 
+// UpdateProfile replaces the profile with the same name.
+func (c *Config) UpdateProfile(name string, prof Profile) error {
+	c.Lock()
+	defer c.Unlock()
+
+	_, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile with name %s not exist", name)
+	}
+	c.Profiles[name] = prof
+	return nil
+}
+
+// RenameProfile renames profile from oldName to newName.
+func (c *Config) RenameProfile(oldName, newName string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	// profile name cannot contains ':'
+	if newName == "" || strings.Contains(newName, profileSeparator) {
+		return errors.New("profile name invalid")
+	}
+
+	prof, ok := c.Profiles[oldName]
+	if !ok {
+		return fmt.Errorf("profile with name %s not exist", oldName)
+	}
+	if _, ok := c.Profiles[newName]; ok {
+		return errors.New("profile already exists")
+	}
+
+	delete(c.Profiles, oldName)
+	c.Profiles[newName] = prof
+	return nil
+}
+
+// GetProfile returns the profile with name.
+func (c *Config) GetProfile(name string) (prof Profile, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	prof, ok = c.Profiles[name]
+	return
+}
+
 func (c *Config) RemoveProfile(name string) {
 	c.Lock()
 	defer c.Unlock()
@@ -70,15 +316,28 @@ func (c *Config) ParseProfileInput(input string) (conn, key string, err error) {
 		return
 	}
 
+	// handle inline connection string
+	if isInlineConnection(input) {
+		conn, key = splitInlineConnection(input)
+		return
+	}
+
 	// handle profile
 	name := input[:sepIdx]
 	prof, ok := c.Profiles[name]
-	// profile not exist, treat as full connection string
 	if !ok {
+		// Windows-like path will be treated as normal fs path if there is no
+		// profile with the same name as the drive.
+		if isWindowsPath(input) {
+			return "fs:///", input, nil
+		}
 		return "", "", fmt.Errorf("profile with name %s not exist", name)
 	}
 
-	conn = prof.Connection
+	conn, err = prof.ConnectionString()
+	if err != nil {
+		return "", "", fmt.Errorf("profile %s: %w", name, err)
+	}
 
 	// handle key
 	// if input end with ':', set key to blank
@@ -90,6 +349,47 @@ func (c *Config) ParseProfileInput(input string) (conn, key string, err error) {
 	return
 }
 
+// ParseProfileOptions returns default options of the profile used by input.
+//
+// Empty options will be returned if input is a local path or the profile has
+// no options.
+func (c *Config) ParseProfileOptions(input string) ProfileOptions {
+	c.Lock()
+	defer c.Unlock()
+
+	prof, ok := c.Profiles[c.profileName(input)]
+	if !ok || prof.Options == nil {
+		return ProfileOptions{}
+	}
+	return *prof.Options
+}
+
+// ParseProfileName returns the name of the profile used by input.
+//
+// Empty name will be returned if input is a local path or an inline
+// connection string.
+func (c *Config) ParseProfileName(input string) string {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.profileName(input)
+}
+
+// profileName is the same as ParseProfileName, but should be called with
+// lock held.
+func (c *Config) profileName(input string) string {
+	sepIdx := strings.Index(input, profileSeparator)
+	if sepIdx == -1 || isInlineConnection(input) {
+		return ""
+	}
+
+	name := input[:sepIdx]
+	if _, ok := c.Profiles[name]; !ok {
+		return ""
+	}
+	return name
+}
+
 func (c *Config) MergeProfileFromEnv() {
 	c.Lock()
 	defer c.Unlock()
@@ -107,5 +407,56 @@ func (c *Config) MergeProfileFromEnv() {
 		// environ like BEYOND_CTL_PROFILE_xxx=val would be added as xxx:val
 		name := strings.TrimPrefix(key, profileEnvPrefix)
 		c.Profiles[name] = Profile{Connection: value}
+		c.setOrigin(profileOriginKey(name), OriginEnv+":"+key)
+	}
+}
+
+// isInlineConnection checks whether input is a connection string like
+// `s3://bucket/path/to/key?credential=env` instead of `profile:key`.
+//
+// The service type must contain at least two chars, so that Windows-like path
+// `C://path` will not be treated as connection string.
+func isInlineConnection(input string) bool {
+	idx := strings.Index(input, "://")
+	if idx < 2 || strings.Index(input, profileSeparator) != idx {
+		return false
+	}
+	for _, ch := range input[:idx] {
+		if !(ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' || ch == '_' || ch == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// splitInlineConnection splits inline connection string into the connection
+// string of the storage and the object key.
+//
+// For `s3://bucket/path/to/key?credential=env`, the connection string will be
+// `s3://bucket/?credential=env` and the key will be `path/to/key`.
+func splitInlineConnection(input string) (conn, key string) {
+	idx := strings.Index(input, "://")
+	ty, rest := input[:idx], input[idx+3:]
+
+	query := ""
+	if idx := strings.Index(rest, "?"); idx != -1 {
+		rest, query = rest[:idx], rest[idx:]
+	}
+
+	// name will be empty for connection string like `fs:///path/to/file`.
+	name, path := rest, ""
+	if idx := strings.Index(rest, "/"); idx != -1 {
+		name, path = rest[:idx], rest[idx:]
+	}
+	return ty + "://" + name + "/" + query, strings.TrimPrefix(path, "/")
+}
+
+// isWindowsPath checks whether input is a Windows-like path such as
+// `C:\path\to\file` or `C:/path/to/file`.
+func isWindowsPath(input string) bool {
+	if len(input) < 3 || input[1] != ':' || (input[2] != '\\' && input[2] != '/') {
+		return false
 	}
+	ch := input[0]
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
 }
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.beyondstorage.io/v5/services"
+	"go.beyondstorage.io/v5/types"
 )
 
 func TestConfig_ParseProfileInput(t *testing.T) {
@@ -61,6 +64,48 @@ func TestConfig_ParseProfileInput(t *testing.T) {
 			key:    "/path/to/file",
 			hasErr: false,
 		},
+		{
+			name:   "inline connection string",
+			input:  "s3://bucket-name/dir/to/file?credential=env&endpoint=https:s3.example.com",
+			conn:   "s3://bucket-name/?credential=env&endpoint=https:s3.example.com",
+			key:    "dir/to/file",
+			hasErr: false,
+		},
+		{
+			name:   "inline connection string with dir",
+			input:  "s3://bucket-name/dir/",
+			conn:   "s3://bucket-name/",
+			key:    "dir/",
+			hasErr: false,
+		},
+		{
+			name:   "inline connection string without key",
+			input:  "s3://bucket-name?credential=env",
+			conn:   "s3://bucket-name/?credential=env",
+			key:    "",
+			hasErr: false,
+		},
+		{
+			name:   "inline connection string without name",
+			input:  "fs:///path/to/file",
+			conn:   "fs:///",
+			key:    "path/to/file",
+			hasErr: false,
+		},
+		{
+			name:   "windows path",
+			input:  `C:\path\to\file`,
+			conn:   "fs:///",
+			key:    `C:\path\to\file`,
+			hasErr: false,
+		},
+		{
+			name:   "windows path with slash",
+			input:  "D:/path/to/file",
+			conn:   "fs:///",
+			key:    "D:/path/to/file",
+			hasErr: false,
+		},
 	}
 
 	for _, tt := range cases {
@@ -75,3 +120,230 @@ func TestConfig_ParseProfileInput(t *testing.T) {
 		assert.Equal(t, tt.key, key, tt.name)
 	}
 }
+
+func TestProfile_ConnectionString(t *testing.T) {
+	err := os.Setenv("BEYOND_CTL_TEST_CREDENTIAL", "ak:sk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("BEYOND_CTL_TEST_CREDENTIAL")
+
+	cases := []struct {
+		name    string
+		profile Profile
+		conn    string
+		hasErr  bool
+	}{
+		{
+			name:    "connection only",
+			profile: Profile{Connection: "s3://bucket/dir?credential=hmac:ak:sk"},
+			conn:    "s3://bucket/dir?credential=hmac:ak:sk",
+		},
+		{
+			name: "structured fields",
+			profile: Profile{
+				Service:  "s3",
+				Name:     "bucket",
+				WorkDir:  "dir",
+				Endpoint: "https:s3.example.com",
+				Location: "us-east-1",
+				Params:   map[string]string{"enable_virtual_dir": "on"},
+			},
+			conn: "s3://bucket/dir?enable_virtual_dir=on&endpoint=https:s3.example.com&location=us-east-1",
+		},
+		{
+			name: "credential from env",
+			profile: Profile{
+				Connection: "s3://bucket?endpoint=https:s3.example.com",
+				Credential: &Credential{Protocol: "hmac", Env: "BEYOND_CTL_TEST_CREDENTIAL"},
+			},
+			conn: "s3://bucket?endpoint=https:s3.example.com&credential=hmac:ak:sk",
+		},
+		{
+			name: "credential from command",
+			profile: Profile{
+				Service:    "s3",
+				Name:       "bucket",
+				Credential: &Credential{Protocol: "hmac", Command: "echo ak:sk"},
+			},
+			conn: "s3://bucket?credential=hmac:ak:sk",
+		},
+		{
+			name: "fields override connection params",
+			profile: Profile{
+				Connection: "s3://bucket?endpoint=http:old.example.com&credential=hmac:old:old&location=us",
+				Endpoint:   "https:s3.example.com",
+				Params:     map[string]string{"endpoint": "http:ignored.example.com", "location": "eu"},
+				Credential: &Credential{Protocol: "hmac", Env: "BEYOND_CTL_TEST_CREDENTIAL"},
+			},
+			conn: "s3://bucket?endpoint=https:s3.example.com&credential=hmac:ak:sk&location=eu",
+		},
+		{
+			name: "values with special chars",
+			profile: Profile{
+				Service:    "s3",
+				Name:       "bucket",
+				Params:     map[string]string{"prefix": "a/b=c"},
+				Credential: &Credential{Protocol: "hmac", Value: "ak:s+k/=="},
+			},
+			conn: "s3://bucket?credential=hmac:ak:s+k/==&prefix=a/b=c",
+		},
+		{
+			name: "values with &",
+			profile: Profile{
+				Service:    "s3",
+				Name:       "bucket",
+				Credential: &Credential{Protocol: "hmac", Value: "ak:s&k"},
+			},
+			hasErr: true,
+		},
+		{
+			name: "credential without value",
+			profile: Profile{
+				Service:    "s3",
+				Name:       "bucket",
+				Credential: &Credential{Protocol: "env"},
+			},
+			conn: "s3://bucket?credential=env",
+		},
+		{
+			name: "credential env not set",
+			profile: Profile{
+				Service:    "s3",
+				Credential: &Credential{Protocol: "hmac", Env: "BEYOND_CTL_TEST_NOT_EXIST"},
+			},
+			hasErr: true,
+		},
+		{
+			name:    "empty profile",
+			profile: Profile{},
+			hasErr:  true,
+		},
+	}
+
+	for _, tt := range cases {
+		conn, err := tt.profile.ConnectionString()
+		if tt.hasErr {
+			assert.NotNil(t, err, tt.name)
+			continue
+		}
+
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, tt.conn, conn, tt.name)
+	}
+}
+
+func TestProfile_Masked(t *testing.T) {
+	prof := Profile{
+		Connection: "s3://bucket?credential=hmac:ak:sk&endpoint=https:s3.example.com",
+		Credential: &Credential{Protocol: "hmac", Value: "ak:sk"},
+	}
+
+	masked := prof.Masked()
+	assert.Equal(t, "s3://bucket?credential=hmac:******&endpoint=https:s3.example.com", masked.Connection)
+	assert.Equal(t, "******", masked.Credential.Value)
+	// The original profile should not be changed.
+	assert.Equal(t, "ak:sk", prof.Credential.Value)
+
+	prof = Profile{
+		Service: "s3",
+		Params:  map[string]string{"credential": "hmac:ak:sk", "location": "us"},
+	}
+	masked = prof.Masked()
+	assert.Equal(t, "hmac:******", masked.Params["credential"])
+	assert.Equal(t, "us", masked.Params["location"])
+	assert.Equal(t, "hmac:ak:sk", prof.Params["credential"])
+}
+
+func TestProfile_ConnectionStringParsed(t *testing.T) {
+	var got []types.Pair
+	services.RegisterStorager("byctl-test", func(ps ...types.Pair) (types.Storager, error) {
+		got = ps
+		return nil, nil
+	})
+
+	prof := Profile{
+		Connection: "byctl-test://bucket?endpoint=http:old.example.com",
+		Endpoint:   "https:s3.example.com",
+		Params:     map[string]string{"prefix": "a/b=c"},
+		Credential: &Credential{Protocol: "hmac", Value: "ak:s+k/=="},
+	}
+	conn, err := prof.ConnectionString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = services.NewStoragerFromString(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]interface{})
+	for _, v := range got {
+		values[v.Key] = v.Value
+	}
+	assert.Equal(t, "https:s3.example.com", values["endpoint"])
+	assert.Equal(t, "hmac:ak:s+k/==", values["credential"])
+	assert.Equal(t, "a/b=c", values["prefix"])
+}
+
+func TestConfig_RenameProfile(t *testing.T) {
+	cfg := New()
+	_ = cfg.AddProfile("test1", Profile{Connection: "s3://bucket-1"})
+	_ = cfg.AddProfile("test2", Profile{Connection: "s3://bucket-2"})
+
+	assert.NotNil(t, cfg.RenameProfile("test1", "test2"), "target exists")
+	assert.NotNil(t, cfg.RenameProfile("test3", "test4"), "source not exist")
+	assert.NotNil(t, cfg.RenameProfile("test1", "test:3"), "invalid name")
+
+	assert.Nil(t, cfg.RenameProfile("test1", "test3"))
+	_, ok := cfg.GetProfile("test1")
+	assert.False(t, ok)
+	prof, ok := cfg.GetProfile("test3")
+	assert.True(t, ok)
+	assert.Equal(t, "s3://bucket-1", prof.Connection)
+}
+
+func TestConfig_UpdateProfile(t *testing.T) {
+	cfg := New()
+	_ = cfg.AddProfile("test1", Profile{Connection: "s3://bucket-1"})
+
+	assert.NotNil(t, cfg.UpdateProfile("test2", Profile{Connection: "s3://bucket-2"}))
+	assert.Nil(t, cfg.UpdateProfile("test1", Profile{Connection: "s3://bucket-2"}))
+
+	prof, _ := cfg.GetProfile("test1")
+	assert.Equal(t, "s3://bucket-2", prof.Connection)
+}
+
+func TestConfig_ParseProfileOptions(t *testing.T) {
+	cfg := New()
+	_ = cfg.AddProfile("test1", Profile{
+		Connection: "s3://bucket-name/dir/",
+		Options: &ProfileOptions{
+			Workers:        8,
+			ReadSpeedLimit: "10MiB",
+		},
+	})
+	_ = cfg.AddProfile("test2", Profile{
+		Connection: "s3://bucket-name/dir/",
+	})
+
+	opts := cfg.ParseProfileOptions("test1:object_key")
+	assert.Equal(t, 8, opts.Workers)
+	assert.Equal(t, "10MiB", opts.ReadSpeedLimit)
+
+	assert.Equal(t, ProfileOptions{}, cfg.ParseProfileOptions("test2:object_key"))
+	assert.Equal(t, ProfileOptions{}, cfg.ParseProfileOptions("/path/to/file"))
+	assert.Equal(t, ProfileOptions{}, cfg.ParseProfileOptions("test3:object_key"))
+}
+
+func TestConfig_ParseProfileName(t *testing.T) {
+	cfg := New()
+	_ = cfg.AddProfile("test1", Profile{
+		Connection: "s3://bucket-name/dir/",
+	})
+
+	assert.Equal(t, "test1", cfg.ParseProfileName("test1:object_key"))
+	assert.Equal(t, "", cfg.ParseProfileName("test2:object_key"))
+	assert.Equal(t, "", cfg.ParseProfileName("/path/to/file"))
+	assert.Equal(t, "", cfg.ParseProfileName("s3://bucket-name/dir/:object_key"))
+}
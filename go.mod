@@ -3,8 +3,10 @@ module go.beyondstorage.io/beyond-ctl
 go 1.15
 
 require (
+	bazil.org/fuse v0.0.0-20200117225306-7b5117fecadc
 	github.com/BurntSushi/toml v1.3.2
 	github.com/Xuanwo/go-bufferpool v0.2.0
+	github.com/chzyer/readline v1.5.1
 	github.com/docker/go-units v0.5.0
 	github.com/google/uuid v1.6.0
 	github.com/panjf2000/ants/v2 v2.10.0
@@ -26,6 +28,7 @@ require (
 	go.beyondstorage.io/services/uss/v3 v3.0.0
 	go.beyondstorage.io/v5 v5.0.0
 	go.uber.org/zap v1.23.0
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 	golang.org/x/text v0.3.8 // indirect
 	golang.org/x/time v0.9.0
 )
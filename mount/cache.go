@@ -0,0 +1,105 @@
+package mount
+
+import (
+	"sync"
+	"time"
+
+	"go.beyondstorage.io/v5/types"
+)
+
+// metaCache caches objects listed from dirs, so that lookups and attrs will
+// not hit the storager every time.
+type metaCache struct {
+	sync.Mutex
+	ttl  time.Duration
+	now  func() time.Time
+	dirs map[string]*dirEntry
+}
+
+type dirEntry struct {
+	// children are objects in dir indexed by name.
+	children map[string]*types.Object
+	expire   time.Time
+}
+
+// newMetaCache creates a cache, zero ttl means cache is disabled.
+func newMetaCache(ttl time.Duration) *metaCache {
+	return &metaCache{
+		ttl:  ttl,
+		now:  time.Now,
+		dirs: make(map[string]*dirEntry),
+	}
+}
+
+// getDir returns cached children of dir.
+func (c *metaCache) getDir(dir string) (map[string]*types.Object, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.dirs[dir]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(e.expire) {
+		delete(c.dirs, dir)
+		return nil, false
+	}
+	return e.children, true
+}
+
+// setDir caches children of dir.
+func (c *metaCache) setDir(dir string, children map[string]*types.Object) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.dirs[dir] = &dirEntry{
+		children: children,
+		expire:   c.now().Add(c.ttl),
+	}
+}
+
+// put updates the object with name in dir if dir is cached.
+//
+// Children map will be copied, so that maps returned by getDir will not be
+// changed.
+func (c *metaCache) put(dir, name string, o *types.Object) {
+	c.update(dir, func(children map[string]*types.Object) {
+		children[name] = o
+	})
+}
+
+// remove removes the object with name in dir if dir is cached.
+func (c *metaCache) remove(dir, name string) {
+	c.update(dir, func(children map[string]*types.Object) {
+		delete(children, name)
+	})
+}
+
+func (c *metaCache) update(dir string, fn func(children map[string]*types.Object)) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.dirs[dir]
+	if !ok {
+		return
+	}
+
+	children := make(map[string]*types.Object, len(e.children)+1)
+	for k, v := range e.children {
+		children[k] = v
+	}
+	fn(children)
+	e.children = children
+}
+
+// invalidate removes dir from cache.
+func (c *metaCache) invalidate(dir string) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.dirs, dir)
+}
@@ -0,0 +1,58 @@
+package mount
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.beyondstorage.io/v5/types"
+)
+
+func TestMetaCache(t *testing.T) {
+	now := time.Now()
+	c := newMetaCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	_, ok := c.getDir("dir/")
+	assert.False(t, ok)
+
+	a := &types.Object{Path: "dir/a"}
+	c.setDir("dir/", map[string]*types.Object{"a": a})
+
+	children, ok := c.getDir("dir/")
+	assert.True(t, ok)
+	assert.Equal(t, a, children["a"])
+
+	// Updates should not change maps returned before.
+	b := &types.Object{Path: "dir/b"}
+	c.put("dir/", "b", b)
+	c.remove("dir/", "a")
+	assert.Len(t, children, 1)
+
+	children, ok = c.getDir("dir/")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]*types.Object{"b": b}, children)
+
+	// Updates on dirs not cached should be ignored.
+	c.put("other/", "c", &types.Object{Path: "other/c"})
+	_, ok = c.getDir("other/")
+	assert.False(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = c.getDir("dir/")
+	assert.False(t, ok, "dir should expire after ttl")
+
+	c.setDir("dir/", map[string]*types.Object{})
+	c.invalidate("dir/")
+	_, ok = c.getDir("dir/")
+	assert.False(t, ok)
+}
+
+func TestMetaCache_Disabled(t *testing.T) {
+	c := newMetaCache(0)
+	c.setDir("dir/", map[string]*types.Object{})
+
+	_, ok := c.getDir("dir/")
+	assert.False(t, ok)
+}
@@ -0,0 +1,744 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+// Package mount exposes a storager as a read-mostly FUSE filesystem.
+package mount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/services"
+	"go.beyondstorage.io/v5/types"
+)
+
+// Options are options of the mounted filesystem.
+type Options struct {
+	// CacheTTL is the duration that metadata of objects will be cached.
+	CacheTTL time.Duration
+	// ReadOnly will reject all write operations.
+	ReadOnly bool
+	// AllowOther allows other users to access the filesystem.
+	AllowOther bool
+	// MultipartThreshold is the size that files larger than it will be
+	// uploaded via multipart.
+	MultipartThreshold int64
+}
+
+// FS is a FUSE filesystem backed by a storager.
+type FS struct {
+	store types.Storager
+	so    *operations.SingleOperator
+	// root is the key prefix of the mounted dir, which is empty or ends with
+	// `/`.
+	root   string
+	opts   Options
+	cache  *metaCache
+	logger *zap.Logger
+
+	uid, gid uint32
+
+	// writers are handles with buffered writes indexed by path, so that
+	// attrs could reflect writes not flushed yet.
+	writersLock sync.Mutex
+	writers     map[string]*writeHandle
+}
+
+// New creates a filesystem which exposes objects under root in storager of so.
+func New(store types.Storager, so *operations.SingleOperator, root string, opts Options) *FS {
+	if root != "" && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+
+	// TODO: we will allow user config log level.
+	logger, _ := zap.NewDevelopment()
+
+	return &FS{
+		store:   store,
+		so:      so,
+		root:    root,
+		opts:    opts,
+		cache:   newMetaCache(opts.CacheTTL),
+		logger:  logger,
+		uid:     uint32(os.Getuid()),
+		gid:     uint32(os.Getgid()),
+		writers: make(map[string]*writeHandle),
+	}
+}
+
+// Mount mounts f at mountpoint and serves requests until it's unmounted or
+// ctx is done.
+func Mount(ctx context.Context, mountpoint, name string, f *FS) error {
+	options := []fuse.MountOption{
+		fuse.FSName(name),
+		fuse.Subtype("byctl"),
+	}
+	if f.opts.ReadOnly {
+		options = append(options, fuse.ReadOnly())
+	}
+	if f.opts.AllowOther {
+		options = append(options, fuse.AllowOther())
+	}
+
+	c, err := fuse.Mount(mountpoint, options...)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := fuse.Unmount(mountpoint); err != nil {
+				f.logger.Error("unmount", zap.String("mountpoint", mountpoint), zap.Error(err))
+			}
+		case <-done:
+		}
+	}()
+
+	if err = fs.Serve(c, f); err != nil {
+		return err
+	}
+
+	<-c.Ready
+	return c.MountError
+}
+
+// Root implements fs.FS.
+func (f *FS) Root() (fs.Node, error) {
+	return &Dir{fs: f, path: f.root}, nil
+}
+
+// list lists children of dir indexed by name.
+func (f *FS) list(ctx context.Context, dir string) (map[string]*types.Object, error) {
+	if children, ok := f.cache.getDir(dir); ok {
+		return children, nil
+	}
+
+	it, err := f.store.ListWithContext(ctx, dir, pairs.WithListMode(types.ListModeDir))
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[string]*types.Object)
+	for {
+		o, err := it.Next()
+		if err != nil && errors.Is(err, types.IterateDone) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(o.Path, dir), "/")
+		// Skip the dir itself which may be returned by some services.
+		if name == "" {
+			continue
+		}
+		children[name] = o
+	}
+
+	f.cache.setDir(dir, children)
+	return children, nil
+}
+
+func (f *FS) writer(p string) (*writeHandle, bool) {
+	f.writersLock.Lock()
+	defer f.writersLock.Unlock()
+
+	h, ok := f.writers[p]
+	return h, ok
+}
+
+// acquireWriter returns the opened write handle of p with refs increased.
+//
+// If p is not opened and h is not nil, h will be installed as the handle of p,
+// and false will be returned.
+func (f *FS) acquireWriter(p string, h *writeHandle) (*writeHandle, bool) {
+	f.writersLock.Lock()
+	defer f.writersLock.Unlock()
+
+	if opened, ok := f.writers[p]; ok {
+		opened.refs++
+		return opened, true
+	}
+	if h != nil {
+		f.writers[p] = h
+	}
+	return h, false
+}
+
+func (f *FS) fileMode() os.FileMode {
+	if f.opts.ReadOnly {
+		return 0444
+	}
+	return 0644
+}
+
+func (f *FS) dirMode() os.FileMode {
+	if f.opts.ReadOnly {
+		return os.ModeDir | 0555
+	}
+	return os.ModeDir | 0755
+}
+
+// Dir is a dir in filesystem.
+type Dir struct {
+	fs *FS
+	// path is the key prefix of dir which is empty or ends with `/`.
+	path string
+}
+
+var (
+	_ fs.Node               = (*Dir)(nil)
+	_ fs.NodeStringLookuper = (*Dir)(nil)
+	_ fs.HandleReadDirAller = (*Dir)(nil)
+	_ fs.NodeCreater        = (*Dir)(nil)
+	_ fs.NodeMkdirer        = (*Dir)(nil)
+	_ fs.NodeRemover        = (*Dir)(nil)
+	_ fs.NodeRenamer        = (*Dir)(nil)
+)
+
+// Attr implements fs.Node.
+func (d *Dir) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Valid = d.fs.opts.CacheTTL
+	a.Mode = d.fs.dirMode()
+	a.Uid = d.fs.uid
+	a.Gid = d.fs.gid
+	return nil
+}
+
+// Lookup implements fs.NodeStringLookuper.
+func (d *Dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	children, err := d.fs.list(ctx, d.path)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+
+	o, ok := children[name]
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+	if o.Mode.IsDir() {
+		return &Dir{fs: d.fs, path: d.path + name + "/"}, nil
+	}
+	return &File{fs: d.fs, path: d.path + name, obj: o}, nil
+}
+
+// ReadDirAll implements fs.HandleReadDirAller.
+func (d *Dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	children, err := d.fs.list(ctx, d.path)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+
+	dirents := make([]fuse.Dirent, 0, len(children))
+	for name, o := range children {
+		typ := fuse.DT_File
+		if o.Mode.IsDir() {
+			typ = fuse.DT_Dir
+		}
+		dirents = append(dirents, fuse.Dirent{Name: name, Type: typ})
+	}
+	sort.Slice(dirents, func(i, j int) bool {
+		return dirents[i].Name < dirents[j].Name
+	})
+	return dirents, nil
+}
+
+// Create implements fs.NodeCreater.
+func (d *Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	if d.fs.opts.ReadOnly {
+		return nil, nil, fuse.Errno(syscall.EROFS)
+	}
+
+	f := &File{fs: d.fs, path: d.path + req.Name}
+	h, err := f.openWriter(ctx, true)
+	if err != nil {
+		return nil, nil, toErrno(err)
+	}
+
+	o := d.fs.store.Create(f.path)
+	o.Mode = types.ModeRead
+	o.SetContentLength(0)
+	o.SetLastModified(time.Now())
+	f.obj = o
+	d.fs.cache.put(d.path, req.Name, o)
+	return f, h, nil
+}
+
+// Mkdir implements fs.NodeMkdirer.
+//
+// Dirs will be created via Direr if supported. Otherwise, dirs only exist in
+// metadata cache until files are written into them, because object storage
+// services don't have real dirs.
+func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	if d.fs.opts.ReadOnly {
+		return nil, fuse.Errno(syscall.EROFS)
+	}
+
+	p := d.path + req.Name + "/"
+	var o *types.Object
+	if direr, ok := d.fs.store.(types.Direr); ok {
+		var err error
+		o, err = direr.CreateDir(strings.TrimSuffix(p, "/"))
+		if err != nil {
+			return nil, toErrno(err)
+		}
+	} else {
+		o = d.fs.store.Create(p)
+	}
+	o.Mode = types.ModeDir
+
+	d.fs.cache.put(d.path, req.Name, o)
+	d.fs.cache.setDir(p, map[string]*types.Object{})
+	return &Dir{fs: d.fs, path: p}, nil
+}
+
+// Remove implements fs.NodeRemover.
+func (d *Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	if d.fs.opts.ReadOnly {
+		return fuse.Errno(syscall.EROFS)
+	}
+
+	p := d.path + req.Name
+	if req.Dir {
+		p += "/"
+		children, err := d.fs.list(ctx, p)
+		if err != nil {
+			return toErrno(err)
+		}
+		if len(children) > 0 {
+			return fuse.Errno(syscall.ENOTEMPTY)
+		}
+	}
+
+	err := d.fs.store.DeleteWithContext(ctx, p)
+	// Dirs in object storage may not exist as objects.
+	if err != nil && !(req.Dir && errors.Is(err, services.ErrObjectNotExist)) {
+		return toErrno(err)
+	}
+
+	d.fs.cache.remove(d.path, req.Name)
+	if req.Dir {
+		d.fs.cache.invalidate(p)
+	}
+	return nil
+}
+
+// Rename implements fs.NodeRenamer.
+//
+// Only files in storager that support Mover could be renamed, EXDEV will be
+// returned otherwise, so that tools like mv will fallback to copy and delete.
+func (d *Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	if d.fs.opts.ReadOnly {
+		return fuse.Errno(syscall.EROFS)
+	}
+
+	target, ok := newDir.(*Dir)
+	if !ok {
+		return fuse.Errno(syscall.EXDEV)
+	}
+	mover, ok := d.fs.store.(types.Mover)
+	if !ok {
+		return fuse.Errno(syscall.EXDEV)
+	}
+
+	children, err := d.fs.list(ctx, d.path)
+	if err != nil {
+		return toErrno(err)
+	}
+	o, ok := children[req.OldName]
+	if !ok {
+		return fuse.ENOENT
+	}
+	if o.Mode.IsDir() {
+		return fuse.Errno(syscall.EXDEV)
+	}
+
+	err = mover.Move(d.path+req.OldName, target.path+req.NewName)
+	if err != nil {
+		return toErrno(err)
+	}
+
+	d.fs.cache.remove(d.path, req.OldName)
+	d.fs.cache.invalidate(target.path)
+	return nil
+}
+
+// File is a file in filesystem.
+type File struct {
+	fs   *FS
+	path string
+	obj  *types.Object
+}
+
+var (
+	_ fs.Node          = (*File)(nil)
+	_ fs.NodeOpener    = (*File)(nil)
+	_ fs.NodeSetattrer = (*File)(nil)
+	_ fs.NodeFsyncer   = (*File)(nil)
+)
+
+// Attr implements fs.Node.
+func (f *File) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Valid = f.fs.opts.CacheTTL
+	a.Mode = f.fs.fileMode()
+	a.Uid = f.fs.uid
+	a.Gid = f.fs.gid
+
+	// Writes not flushed should be visible.
+	if h, ok := f.fs.writer(f.path); ok {
+		size, err := h.size()
+		if err != nil {
+			return toErrno(err)
+		}
+		a.Valid = 0
+		a.Size = uint64(size)
+		a.Mtime = time.Now()
+		return nil
+	}
+
+	if f.obj != nil {
+		if n, ok := f.obj.GetContentLength(); ok {
+			a.Size = uint64(n)
+		}
+		if t, ok := f.obj.GetLastModified(); ok {
+			a.Mtime = t
+			a.Ctime = t
+		}
+	}
+	return nil
+}
+
+// Open implements fs.NodeOpener.
+func (f *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	if req.Flags.IsReadOnly() {
+		return &readHandle{file: f}, nil
+	}
+	if f.fs.opts.ReadOnly {
+		return nil, fuse.Errno(syscall.EROFS)
+	}
+
+	h, err := f.openWriter(ctx, req.Flags&fuse.OpenTruncate != 0)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	return h, nil
+}
+
+// Setattr implements fs.NodeSetattrer.
+//
+// Only changing size is supported, others will be ignored.
+func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if req.Valid.Size() {
+		if f.fs.opts.ReadOnly {
+			return fuse.Errno(syscall.EROFS)
+		}
+
+		h, ok := f.fs.writer(f.path)
+		switch {
+		case ok:
+			if err := h.truncate(int64(req.Size)); err != nil {
+				return toErrno(err)
+			}
+		case req.Size == 0:
+			// Truncate a file which is not opened, for example, `: > file`.
+			h, err := f.openWriter(ctx, true)
+			if err != nil {
+				return toErrno(err)
+			}
+			if err = h.release(ctx); err != nil {
+				return toErrno(err)
+			}
+		default:
+			return fuse.Errno(syscall.ENOTSUP)
+		}
+	}
+	return f.Attr(ctx, &resp.Attr)
+}
+
+// Fsync implements fs.NodeFsyncer.
+func (f *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	if h, ok := f.fs.writer(f.path); ok {
+		return toErrno(h.flush(ctx))
+	}
+	return nil
+}
+
+// openWriter opens a write handle for file. Existing content will be
+// downloaded into the buffer if truncate is false.
+//
+// Opening the same file for write multiple times will share the same handle.
+func (f *File) openWriter(ctx context.Context, truncate bool) (*writeHandle, error) {
+	if h, ok := f.fs.acquireWriter(f.path, nil); ok {
+		if truncate {
+			return h, h.truncate(0)
+		}
+		return h, nil
+	}
+
+	// Content is downloaded without holding writersLock, so that other files
+	// could be opened or released meanwhile.
+	tmp, err := ioutil.TempFile("", "byctl-mount-")
+	if err != nil {
+		return nil, err
+	}
+	h := &writeHandle{file: f, tmp: tmp, refs: 1, dirty: truncate}
+
+	if !truncate && f.obj != nil {
+		_, err = f.fs.store.ReadWithContext(ctx, f.path, tmp)
+		if err != nil && !errors.Is(err, services.ErrObjectNotExist) {
+			h.close()
+			return nil, err
+		}
+	}
+
+	// The file may have been opened by others while downloading, the handle
+	// opened first will be shared, since it may have been written.
+	if shared, ok := f.fs.acquireWriter(f.path, h); ok {
+		h.close()
+		if truncate {
+			return shared, shared.truncate(0)
+		}
+		return shared, nil
+	}
+	return h, nil
+}
+
+// readHandle reads file via ranged Read.
+type readHandle struct {
+	file *File
+}
+
+var _ fs.HandleReader = (*readHandle)(nil)
+
+// Read implements fs.HandleReader.
+func (h *readHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	// Read from the write buffer if file is being written.
+	if w, ok := h.file.fs.writer(h.file.path); ok {
+		return w.Read(ctx, req, resp)
+	}
+
+	size := int64(req.Size)
+	if h.file.obj != nil {
+		if n, ok := h.file.obj.GetContentLength(); ok {
+			if req.Offset >= n {
+				resp.Data = resp.Data[:0]
+				return nil
+			}
+			if req.Offset+size > n {
+				size = n - req.Offset
+			}
+		}
+	}
+
+	buf := newFixedBuffer(resp.Data[:0], int(size))
+	_, err := h.file.fs.store.ReadWithContext(ctx, h.file.path, buf,
+		pairs.WithOffset(req.Offset), pairs.WithSize(size))
+	if err != nil {
+		return toErrno(err)
+	}
+	resp.Data = buf.b
+	return nil
+}
+
+// writeHandle buffers writes in a temp file and uploads it while flushing.
+type writeHandle struct {
+	file *File
+
+	mu    sync.Mutex
+	tmp   *os.File
+	dirty bool
+	// refs is the number of opened handles, guarded by fs.writersLock.
+	refs int
+}
+
+var (
+	_ fs.HandleReader   = (*writeHandle)(nil)
+	_ fs.HandleWriter   = (*writeHandle)(nil)
+	_ fs.HandleFlusher  = (*writeHandle)(nil)
+	_ fs.HandleReleaser = (*writeHandle)(nil)
+)
+
+// Read implements fs.HandleReader.
+func (h *writeHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buf := resp.Data[:req.Size]
+	n, err := h.tmp.ReadAt(buf, req.Offset)
+	if err != nil && err != io.EOF {
+		return toErrno(err)
+	}
+	resp.Data = buf[:n]
+	return nil
+}
+
+// Write implements fs.HandleWriter.
+func (h *writeHandle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, err := h.tmp.WriteAt(req.Data, req.Offset)
+	if err != nil {
+		return toErrno(err)
+	}
+	h.dirty = true
+	resp.Size = n
+	return nil
+}
+
+// Flush implements fs.HandleFlusher.
+func (h *writeHandle) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+	return toErrno(h.flush(ctx))
+}
+
+// Release implements fs.HandleReleaser.
+func (h *writeHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	return toErrno(h.release(ctx))
+}
+
+func (h *writeHandle) size() (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fi, err := h.tmp.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (h *writeHandle) truncate(size int64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.tmp.Truncate(size); err != nil {
+		return err
+	}
+	h.dirty = true
+	return nil
+}
+
+// flush uploads the buffer if it has been changed.
+func (h *writeHandle) flush(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.dirty {
+		return nil
+	}
+
+	fi, err := h.tmp.Stat()
+	if err != nil {
+		return err
+	}
+	size := fi.Size()
+
+	f := h.file
+	r := io.NewSectionReader(h.tmp, 0, size)
+	_, isMultiparter := f.fs.store.(types.Multiparter)
+	if size >= f.fs.opts.MultipartThreshold && isMultiparter {
+		ch, err := f.fs.so.TeeRun(f.path, size, r)
+		if err != nil {
+			return err
+		}
+		for v := range ch {
+			if v.Error != nil {
+				err = v.Error
+			}
+		}
+	} else {
+		_, err = f.fs.store.WriteWithContext(ctx, f.path, r, size)
+	}
+	if err != nil {
+		f.fs.logger.Error("upload file", zap.String("path", f.path), zap.Error(err))
+		return err
+	}
+	h.dirty = false
+
+	o := f.fs.store.Create(f.path)
+	o.Mode = types.ModeRead
+	o.SetContentLength(size)
+	o.SetLastModified(time.Now())
+	f.obj = o
+	dir, name := path.Split(f.path)
+	f.fs.cache.put(dir, name, o)
+	return nil
+}
+
+// release flushes the buffer and removes it if no handles refer to it.
+func (h *writeHandle) release(ctx context.Context) error {
+	err := h.flush(ctx)
+
+	fsys := h.file.fs
+	fsys.writersLock.Lock()
+	defer fsys.writersLock.Unlock()
+
+	h.refs--
+	if h.refs > 0 {
+		return err
+	}
+	delete(fsys.writers, h.file.path)
+	h.close()
+	return err
+}
+
+func (h *writeHandle) close() {
+	name := h.tmp.Name()
+	_ = h.tmp.Close()
+	_ = os.Remove(name)
+}
+
+// fixedBuffer is an io.Writer which writes into a preallocated slice.
+type fixedBuffer struct {
+	b   []byte
+	max int
+}
+
+func newFixedBuffer(b []byte, max int) *fixedBuffer {
+	return &fixedBuffer{b: b, max: max}
+}
+
+func (w *fixedBuffer) Write(p []byte) (int, error) {
+	if len(w.b)+len(p) > w.max {
+		return 0, fmt.Errorf("read %d bytes more than expected %d", len(w.b)+len(p), w.max)
+	}
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+// toErrno converts storager errors into errors that could be returned to
+// the kernel.
+func toErrno(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, services.ErrObjectNotExist):
+		return fuse.ENOENT
+	case errors.Is(err, services.ErrPermissionDenied):
+		return fuse.EPERM
+	case errors.Is(err, context.Canceled):
+		return fuse.EINTR
+	}
+	return err
+}
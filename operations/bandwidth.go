@@ -0,0 +1,286 @@
+package operations
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+	"golang.org/x/time/rate"
+
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/types"
+)
+
+// BandwidthTotal is the key of the bandwidth limit shared by all reads and
+// writes in the process.
+const BandwidthTotal = "total"
+
+// BandwidthRule limits the bandwidth to Limit bytes per second during
+// [Start, End) of every day. Start and End are offsets from midnight, and the
+// rule crosses midnight if End is not after Start.
+type BandwidthRule struct {
+	Start time.Duration
+	End   time.Duration
+	Limit int64
+}
+
+func (r BandwidthRule) match(offset time.Duration) bool {
+	if r.Start < r.End {
+		return offset >= r.Start && offset < r.End
+	}
+	return offset >= r.Start || offset < r.End
+}
+
+// BandwidthSchedule is the bandwidth limit which could change with the time of
+// day. Zero limit means unlimited.
+type BandwidthSchedule struct {
+	// Rules are checked in order, and the first matched one wins.
+	Rules []BandwidthRule
+	// Default is the limit if no rule matches.
+	Default int64
+}
+
+// ParseBandwidthSchedule parses a comma separated list of limits like
+// `09:00-18:00=10MB,unlimited`. Limits with a time range are applied during
+// the range in local time, and the limit without a range is applied at other
+// times. Limits are human readable sizes per second like 10MB or 10MB/s, and
+// 0, off and unlimited mean unlimited.
+func ParseBandwidthSchedule(text string) (s BandwidthSchedule, err error) {
+	hasDefault := false
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		idx := strings.Index(item, "=")
+		if idx == -1 {
+			if hasDefault {
+				return BandwidthSchedule{}, fmt.Errorf("bandwidth limit %s has more than one default limit", text)
+			}
+			s.Default, err = parseBandwidthLimit(item)
+			if err != nil {
+				return BandwidthSchedule{}, err
+			}
+			hasDefault = true
+			continue
+		}
+
+		r, err := parseBandwidthRule(item[:idx], item[idx+1:])
+		if err != nil {
+			return BandwidthSchedule{}, err
+		}
+		s.Rules = append(s.Rules, r)
+	}
+	return s, nil
+}
+
+func parseBandwidthRule(period, limit string) (r BandwidthRule, err error) {
+	times := strings.Split(period, "-")
+	if len(times) != 2 {
+		return BandwidthRule{}, fmt.Errorf("time range %s is invalid, it should be like 09:00-18:00", period)
+	}
+	if r.Start, err = parseTimeOfDay(times[0]); err != nil {
+		return BandwidthRule{}, err
+	}
+	if r.End, err = parseTimeOfDay(times[1]); err != nil {
+		return BandwidthRule{}, err
+	}
+	if r.Start == r.End {
+		return BandwidthRule{}, fmt.Errorf("time range %s is empty", period)
+	}
+	if r.Limit, err = parseBandwidthLimit(limit); err != nil {
+		return BandwidthRule{}, err
+	}
+	return r, nil
+}
+
+// parseTimeOfDay parses HH:MM into the offset from midnight, 24:00 is
+// allowed as the end of day.
+func parseTimeOfDay(text string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(text), ":")
+	if len(parts) == 2 {
+		h, herr := strconv.Atoi(parts[0])
+		m, merr := strconv.Atoi(parts[1])
+		if herr == nil && merr == nil && h >= 0 && m >= 0 && m < 60 && (h < 24 || h == 24 && m == 0) {
+			return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+		}
+	}
+	return 0, fmt.Errorf("time %s is invalid, it should be like 09:00", text)
+}
+
+func parseBandwidthLimit(text string) (int64, error) {
+	text = strings.TrimSuffix(strings.TrimSpace(text), "/s")
+	switch strings.ToLower(text) {
+	case "0", "off", "unlimited":
+		return 0, nil
+	}
+
+	limit, err := units.FromHumanSize(text)
+	if err != nil {
+		return 0, fmt.Errorf("bandwidth limit %s is invalid: %w", text, err)
+	}
+	if limit <= 0 {
+		return 0, fmt.Errorf("bandwidth limit %s must be positive", text)
+	}
+	return limit, nil
+}
+
+// Unlimited reports whether s is unlimited at any time.
+func (s BandwidthSchedule) Unlimited() bool {
+	for _, r := range s.Rules {
+		if r.Limit > 0 {
+			return false
+		}
+	}
+	return s.Default <= 0
+}
+
+// LimitAt returns the limit at t, zero means unlimited.
+func (s BandwidthSchedule) LimitAt(t time.Time) int64 {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	for _, r := range s.Rules {
+		if r.match(offset) {
+			return r.Limit
+		}
+	}
+	return s.Default
+}
+
+// bandwidthLimiter limits the bandwidth via a token bucket, whose rate follows
+// the schedule.
+type bandwidthLimiter struct {
+	mu       sync.Mutex
+	schedule BandwidthSchedule
+	limit    int64
+	limiter  *rate.Limiter
+}
+
+func (l *bandwidthLimiter) setSchedule(s BandwidthSchedule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.schedule = s
+}
+
+func (l *bandwidthLimiter) unlimited() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.schedule.Unlimited()
+}
+
+// reserve takes n bytes from the bucket at now, and returns the delay before
+// they could be transferred.
+func (l *bandwidthLimiter) reserve(now time.Time, n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The schedule is checked on every reservation, so that changes of the
+	// time of day and the schedule will be applied to running transfers.
+	if limit := l.schedule.LimitAt(now); limit != l.limit {
+		l.limit = limit
+		switch {
+		case limit <= 0:
+			l.limiter = nil
+		case l.limiter == nil:
+			l.limiter = rate.NewLimiter(rate.Limit(limit), int(limit))
+		default:
+			l.limiter.SetLimitAt(now, rate.Limit(limit))
+			l.limiter.SetBurstAt(now, int(limit))
+		}
+	}
+	if l.limiter == nil {
+		return 0
+	}
+
+	// Buffers larger than burst are reserved in chunks, and the delay of
+	// the last chunk is the delay of the whole buffer.
+	var delay time.Duration
+	for n > 0 {
+		m := l.limiter.Burst()
+		if m > n {
+			m = n
+		}
+		delay = l.limiter.ReserveN(now, m).DelayFrom(now)
+		n -= m
+	}
+	return delay
+}
+
+// bandwidthScheduler holds limiters of the process indexed by key.
+type bandwidthScheduler struct {
+	mu       sync.Mutex
+	limiters map[string]*bandwidthLimiter
+}
+
+var bandwidth = &bandwidthScheduler{limiters: make(map[string]*bandwidthLimiter)}
+
+func (bs *bandwidthScheduler) get(key string) *bandwidthLimiter {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	l, ok := bs.limiters[key]
+	if !ok {
+		l = &bandwidthLimiter{}
+		bs.limiters[key] = l
+	}
+	return l
+}
+
+// SetBandwidth sets the schedule of the limiter of key, BandwidthTotal for the
+// limit of the process. It could be called at any time, and running transfers
+// will follow the new schedule.
+func SetBandwidth(key string, s BandwidthSchedule) {
+	bandwidth.get(key).setSchedule(s)
+}
+
+// BandwidthPair returns the io callback pair that limits the bandwidth via
+// limiters of keys, and the total limiter if total is true. Limiters are
+// shared by all pairs with the same key, so that the limit covers all
+// workers, parts and both read and write sides using them.
+//
+// Content copied between storagers passes both the read and write sides, so
+// only one side should take the total limiter, otherwise it will be charged
+// twice.
+//
+// false will be returned if all the limiters are unlimited at any time, the
+// pair is not needed then. Schedules set later will not be applied to
+// transfers without the pair.
+func BandwidthPair(total bool, keys ...string) (types.Pair, bool) {
+	limiters := make([]*bandwidthLimiter, 0, len(keys)+1)
+	if total {
+		limiters = append(limiters, bandwidth.get(BandwidthTotal))
+	}
+	for _, key := range keys {
+		limiters = append(limiters, bandwidth.get(key))
+	}
+
+	limited := false
+	for _, l := range limiters {
+		if !l.unlimited() {
+			limited = true
+		}
+	}
+	if !limited {
+		return types.Pair{}, false
+	}
+
+	return pairs.WithIoCallback(func(bs []byte) {
+		now := time.Now()
+
+		// Bytes are taken from all limiters at the same time, and the
+		// slowest one decides the delay.
+		var delay time.Duration
+		for _, l := range limiters {
+			if d := l.reserve(now, len(bs)); d > delay {
+				delay = d
+			}
+		}
+		time.Sleep(delay)
+	}), true
+}
@@ -0,0 +1,121 @@
+package operations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBandwidthSchedule(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		expect BandwidthSchedule
+		hasErr bool
+	}{
+		{"empty", "", BandwidthSchedule{}, false},
+		{"plain", "10MB", BandwidthSchedule{Default: 10 * 1000 * 1000}, false},
+		{"per second", "1kB/s", BandwidthSchedule{Default: 1000}, false},
+		{"unlimited", "off", BandwidthSchedule{}, false},
+		{
+			"schedule", "09:00-18:00=10MB, 22:00-06:00=unlimited, 1MB",
+			BandwidthSchedule{
+				Rules: []BandwidthRule{
+					{Start: 9 * time.Hour, End: 18 * time.Hour, Limit: 10 * 1000 * 1000},
+					{Start: 22 * time.Hour, End: 6 * time.Hour},
+				},
+				Default: 1000 * 1000,
+			},
+			false,
+		},
+		{"end of day", "18:00-24:00=1kB", BandwidthSchedule{
+			Rules: []BandwidthRule{{Start: 18 * time.Hour, End: 24 * time.Hour, Limit: 1000}},
+		}, false},
+		{"two defaults", "1MB,2MB", BandwidthSchedule{}, true},
+		{"invalid limit", "abc", BandwidthSchedule{}, true},
+		{"negative limit", "-1MB", BandwidthSchedule{}, true},
+		{"invalid range", "09:00=1MB", BandwidthSchedule{}, true},
+		{"invalid time", "09:00-25:00=1MB", BandwidthSchedule{}, true},
+		{"empty range", "09:00-09:00=1MB", BandwidthSchedule{}, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseBandwidthSchedule(tt.input)
+			if tt.hasErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, s)
+		})
+	}
+}
+
+func TestBandwidthScheduleLimitAt(t *testing.T) {
+	s, err := ParseBandwidthSchedule("09:00-18:00=10MB,22:00-06:00=1MB,unlimited")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(hour, min int) time.Time {
+		return time.Date(2021, 6, 1, hour, min, 0, 0, time.Local)
+	}
+	assert.Equal(t, int64(10*1000*1000), s.LimitAt(at(9, 0)))
+	assert.Equal(t, int64(10*1000*1000), s.LimitAt(at(17, 59)))
+	assert.Equal(t, int64(0), s.LimitAt(at(18, 0)))
+	assert.Equal(t, int64(1000*1000), s.LimitAt(at(23, 0)))
+	assert.Equal(t, int64(1000*1000), s.LimitAt(at(3, 0)))
+	assert.Equal(t, int64(0), s.LimitAt(at(6, 0)))
+}
+
+func TestBandwidthLimiter(t *testing.T) {
+	now := time.Now()
+	l := &bandwidthLimiter{}
+
+	// Unlimited by default.
+	assert.Equal(t, time.Duration(0), l.reserve(now, 1<<20))
+
+	l.setSchedule(BandwidthSchedule{Default: 100})
+	// The first burst is free, and the rest should wait.
+	assert.Equal(t, time.Duration(0), l.reserve(now, 100))
+	assert.Equal(t, time.Second, l.reserve(now, 100))
+	// Buffers larger than burst should wait for all of them.
+	assert.Equal(t, 4*time.Second, l.reserve(now, 300))
+
+	// Changes of schedule are applied to the next reservation.
+	l.setSchedule(BandwidthSchedule{})
+	assert.Equal(t, time.Duration(0), l.reserve(now, 1<<20))
+}
+
+func TestBandwidthPair(t *testing.T) {
+	defer SetBandwidth(BandwidthTotal, BandwidthSchedule{})
+
+	// The pair is not needed if all limiters are unlimited.
+	_, ok := BandwidthPair(true, "test:unlimited")
+	assert.False(t, ok)
+	SetBandwidth("test:scheduled", BandwidthSchedule{
+		Rules: []BandwidthRule{{Start: 9 * time.Hour, End: 18 * time.Hour, Limit: 100}},
+	})
+	_, ok = BandwidthPair(false, "test:scheduled")
+	assert.True(t, ok)
+
+	// Only the side that takes the total limiter is limited by it.
+	SetBandwidth(BandwidthTotal, BandwidthSchedule{Default: 100})
+	_, ok = BandwidthPair(false, "test:unlimited")
+	assert.False(t, ok)
+	readPair, ok := BandwidthPair(true, "test:unlimited")
+	assert.True(t, ok)
+	SetBandwidth("test:write", BandwidthSchedule{Default: 1000})
+	writePair, ok := BandwidthPair(false, "test:write")
+	assert.True(t, ok)
+
+	// A copy passes both sides, the first burst of the total limiter is
+	// free, and it would wait for a second if it's charged twice.
+	start := time.Now()
+	content := make([]byte, 100)
+	readPair.Value.(func([]byte))(content)
+	writePair.Value.(func([]byte))(content)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+}
@@ -0,0 +1,358 @@
+package operations
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/types"
+)
+
+// Operations measured by Bench.
+const (
+	BenchOpWrite  = "write"
+	BenchOpRead   = "read"
+	BenchOpStat   = "stat"
+	BenchOpList   = "list"
+	BenchOpDelete = "delete"
+)
+
+// ErrBenchStopped is returned by Bench if it's stopped via BenchOptions.Stop.
+var ErrBenchStopped = errors.New("bench stopped")
+
+// BenchOptions are options of Bench.
+type BenchOptions struct {
+	// Sizes are sizes of the synthetic objects, Count objects will be
+	// written for every size.
+	Sizes []int64
+	Count int
+	// MultipartThreshold is the size that objects not smaller than it will
+	// be written via multipart. Zero means multipart will not be used.
+	MultipartThreshold int64
+	// Stop stops the benchmark once it's closed, objects that have been
+	// written will still be deleted.
+	Stop <-chan struct{}
+}
+
+// BenchResult is the result of an operation on objects of Size.
+type BenchResult struct {
+	Op   string
+	Size int64
+	// Count is the number of operations, and Errors is the number of failed
+	// ones.
+	Count  int
+	Errors int
+	// Bytes is the size of content transferred by succeeded operations.
+	Bytes int64
+	// Elapsed is the wall time of all operations running concurrently.
+	Elapsed time.Duration
+	// Latencies are latencies of succeeded operations in ascending order.
+	Latencies []time.Duration
+}
+
+// Percentile returns the latency at percentile p in [0, 100] via the
+// nearest-rank method, zero will be returned if there is no latency.
+func (r *BenchResult) Percentile(p float64) time.Duration {
+	n := len(r.Latencies)
+	if n == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(n)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > n {
+		rank = n
+	}
+	return r.Latencies[rank-1]
+}
+
+// OpsPerSecond returns the number of succeeded operations per second.
+func (r *BenchResult) OpsPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Count-r.Errors) / r.Elapsed.Seconds()
+}
+
+// Throughput returns the transferred bytes per second.
+func (r *BenchResult) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// benchRecorder collects latencies of concurrent operations.
+type benchRecorder struct {
+	mu     sync.Mutex
+	result *BenchResult
+}
+
+func (br *benchRecorder) record(latency time.Duration, n int64, err error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	br.result.Count++
+	if err != nil {
+		br.result.Errors++
+		return
+	}
+	br.result.Bytes += n
+	br.result.Latencies = append(br.result.Latencies, latency)
+}
+
+// Bench writes synthetic objects under dir, measures write, read, stat, list
+// and delete on them via the worker pool, and deletes them at last. dir
+// should not contain other objects, because all objects in it will be
+// listed.
+//
+// Objects will be deleted even if some operations failed or the benchmark is
+// stopped, the returned error is only for errors that stop the benchmark or
+// fail the cleanup. Results of finished operations will be returned along
+// with ErrBenchStopped if it's stopped.
+func (so *SingleOperator) Bench(dir string, opts BenchOptions) (results []*BenchResult, err error) {
+	if opts.Count <= 0 {
+		return nil, fmt.Errorf("bench count must be positive, but got %d", opts.Count)
+	}
+	if dir != "" && !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+
+	var maxSize int64
+	for _, size := range opts.Sizes {
+		if size < 0 {
+			return nil, fmt.Errorf("bench size must not be negative, but got %d", size)
+		}
+		if size > maxSize {
+			maxSize = size
+		}
+	}
+
+	// All objects share the same random content, so that generating content
+	// will not be measured.
+	content := make([]byte, maxSize)
+	_, _ = rand.New(rand.NewSource(time.Now().UnixNano())).Read(content)
+
+	objects := &benchObjects{m: make(map[string]struct{})}
+	var sizeDirs []string
+	defer func() {
+		if cerr := so.benchCleanup(dir, sizeDirs, objects); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for _, size := range opts.Sizes {
+		// Reallocate var here to prevent closure catch.
+		size := size
+		sizeDir := fmt.Sprintf("%s%d/", dir, size)
+		sizeDirs = append(sizeDirs, sizeDir)
+		paths := make([]string, opts.Count)
+		for i := range paths {
+			paths[i] = fmt.Sprintf("%s%d", sizeDir, i)
+		}
+
+		phases := []struct {
+			op    string
+			paths []string
+			fn    func(p string) (int64, error)
+		}{
+			{BenchOpWrite, paths, func(p string) (int64, error) {
+				// Track the path before writing, so that objects partly
+				// written will also be deleted.
+				objects.add(p)
+				return so.benchWrite(p, content[:size], opts.MultipartThreshold)
+			}},
+			{BenchOpRead, paths, func(p string) (int64, error) {
+				return so.store.Read(p, ioutil.Discard)
+			}},
+			{BenchOpStat, paths, func(p string) (int64, error) {
+				_, err := so.store.Stat(p)
+				return 0, err
+			}},
+			{BenchOpList, []string{sizeDir}, func(p string) (int64, error) {
+				return 0, so.benchList(p)
+			}},
+			{BenchOpDelete, paths, func(p string) (int64, error) {
+				err := so.store.Delete(p)
+				if err == nil {
+					objects.remove(p)
+				}
+				return 0, err
+			}},
+		}
+		for _, phase := range phases {
+			if benchStopped(opts.Stop) {
+				return results, ErrBenchStopped
+			}
+			results = append(results, so.benchRun(phase.op, size, phase.paths, opts.Stop, phase.fn))
+		}
+	}
+	if benchStopped(opts.Stop) {
+		return results, ErrBenchStopped
+	}
+	return results, nil
+}
+
+// benchObjects are paths of objects that may exist in storager.
+type benchObjects struct {
+	mu sync.Mutex
+	m  map[string]struct{}
+}
+
+func (bo *benchObjects) add(p string) {
+	bo.mu.Lock()
+	defer bo.mu.Unlock()
+
+	bo.m[p] = struct{}{}
+}
+
+func (bo *benchObjects) remove(p string) {
+	bo.mu.Lock()
+	defer bo.mu.Unlock()
+
+	delete(bo.m, p)
+}
+
+func (bo *benchObjects) paths() []string {
+	bo.mu.Lock()
+	defer bo.mu.Unlock()
+
+	paths := make([]string, 0, len(bo.m))
+	for p := range bo.m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// benchCleanup deletes objects left by Bench, and the dirs left by storagers
+// like fs. It's fine if the dirs don't exist.
+func (so *SingleOperator) benchCleanup(dir string, sizeDirs []string, objects *benchObjects) error {
+	var failed int
+	for _, p := range objects.paths() {
+		if err := so.store.Delete(p); err != nil {
+			so.logger.Error("delete bench object", zap.String("path", p), zap.Error(err))
+			failed++
+		}
+	}
+	for _, sizeDir := range sizeDirs {
+		_ = so.store.Delete(sizeDir)
+	}
+	if dir != "" {
+		_ = so.store.Delete(dir)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d bench objects under %s", failed, dir)
+	}
+	return nil
+}
+
+// benchStopped reports whether stop has been closed.
+func benchStopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// benchRun runs fn on all paths concurrently and records their latencies.
+// Paths will not be submitted any more once stop is closed.
+func (so *SingleOperator) benchRun(op string, size int64, paths []string, stop <-chan struct{}, fn func(p string) (int64, error)) *BenchResult {
+	br := &benchRecorder{result: &BenchResult{Op: op, Size: size}}
+	wg := &sync.WaitGroup{}
+
+	start := time.Now()
+	for _, p := range paths {
+		if benchStopped(stop) {
+			break
+		}
+		// Reallocate var here to prevent closure catch.
+		path := p
+
+		wg.Add(1)
+		err := so.pool.Submit(func() error {
+			defer wg.Done()
+
+			opStart := time.Now()
+			n, err := fn(path)
+			if err != nil {
+				so.logger.Error("bench", zap.String("op", op), zap.String("path", path), zap.Error(err))
+			}
+			br.record(time.Since(opStart), n, err)
+			return err
+		})
+		if err != nil {
+			wg.Done()
+			so.logger.Error("submit task", zap.Error(err))
+			br.record(0, 0, err)
+		}
+	}
+	wg.Wait()
+
+	r := br.result
+	r.Elapsed = time.Since(start)
+	sort.Slice(r.Latencies, func(i, j int) bool {
+		return r.Latencies[i] < r.Latencies[j]
+	})
+	return r
+}
+
+// benchWrite writes content into path, via multipart if the content is not
+// smaller than threshold and the storager supports it. It runs in bench tasks.
+func (so *SingleOperator) benchWrite(path string, content []byte, threshold int64) (int64, error) {
+	size := int64(len(content))
+	if _, ok := so.store.(types.Multiparter); !ok || threshold <= 0 || size < threshold {
+		return so.store.Write(path, bytes.NewReader(content), size)
+	}
+
+	// Parts are written by the part pool, don't occupy the cap of workers
+	// while waiting for them.
+	var err error
+	yieldWorker(func() {
+		var ch chan *EmptyResult
+		ch, err = so.TeeRun(path, size, bytes.NewReader(content))
+		if err != nil {
+			return
+		}
+		for v := range ch {
+			if v.Error != nil {
+				err = v.Error
+			}
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// benchList lists all objects in dir.
+func (so *SingleOperator) benchList(dir string) error {
+	it, err := so.store.List(dir, pairs.WithListMode(types.ListModeDir))
+	if err != nil {
+		return err
+	}
+	for {
+		_, err = it.Next()
+		if err != nil {
+			if errors.Is(err, types.IterateDone) {
+				return nil
+			}
+			return err
+		}
+	}
+}
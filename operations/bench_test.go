@@ -0,0 +1,130 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	_ "go.beyondstorage.io/services/memory"
+	"go.beyondstorage.io/v5/services"
+	"go.beyondstorage.io/v5/types"
+)
+
+func newMemoryStorager(t *testing.T) types.Storager {
+	store, err := services.NewStoragerFromString("memory:///")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// assertBenchCleaned asserts that no object written by Bench is left.
+func assertBenchCleaned(t *testing.T, store types.Storager, dir string, sizes []int64, count int) {
+	for _, size := range sizes {
+		for i := 0; i < count; i++ {
+			p := fmt.Sprintf("%s/%d/%d", dir, size, i)
+			_, err := store.Stat(p)
+			assert.True(t, errors.Is(err, services.ErrObjectNotExist), "%s should be deleted", p)
+		}
+	}
+}
+
+func TestBench(t *testing.T) {
+	store := newMemoryStorager(t)
+	sizes := []int64{0, 1024}
+
+	results, err := NewSingleOperator(store).WithWorkers(4).Bench("bench", BenchOptions{
+		Sizes: sizes,
+		Count: 8,
+	})
+	assert.NoError(t, err)
+
+	ops := []string{BenchOpWrite, BenchOpRead, BenchOpStat, BenchOpList, BenchOpDelete}
+	if assert.Len(t, results, len(sizes)*len(ops)) {
+		for i, r := range results {
+			assert.Equal(t, ops[i%len(ops)], r.Op)
+			assert.Equal(t, sizes[i/len(ops)], r.Size)
+			assert.Equal(t, 0, r.Errors, "%s of size %d", r.Op, r.Size)
+			if r.Op == BenchOpList {
+				assert.Equal(t, 1, r.Count)
+			} else {
+				assert.Equal(t, 8, r.Count)
+			}
+		}
+		// Write and read of 8 objects with 1024 bytes.
+		assert.Equal(t, int64(8*1024), results[len(ops)].Bytes)
+		assert.Equal(t, int64(8*1024), results[len(ops)+1].Bytes)
+	}
+	assertBenchCleaned(t, store, "bench", sizes, 8)
+}
+
+// stopStorager closes stop after the first write.
+type stopStorager struct {
+	types.Storager
+	once sync.Once
+	stop chan struct{}
+}
+
+func (s *stopStorager) Write(path string, r io.Reader, size int64, pairs ...types.Pair) (int64, error) {
+	defer s.once.Do(func() { close(s.stop) })
+	return s.Storager.Write(path, r, size, pairs...)
+}
+
+func TestBenchStopped(t *testing.T) {
+	store := &stopStorager{Storager: newMemoryStorager(t), stop: make(chan struct{})}
+	sizes := []int64{16, 32}
+
+	results, err := NewSingleOperator(store).WithWorkers(1).Bench("bench", BenchOptions{
+		Sizes: sizes,
+		Count: 8,
+		Stop:  store.stop,
+	})
+	assert.ErrorIs(t, err, ErrBenchStopped)
+
+	// Only the write of the first size has been started.
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, BenchOpWrite, results[0].Op)
+		assert.Less(t, results[0].Count, 8)
+	}
+	assertBenchCleaned(t, store, "bench", sizes, 8)
+}
+
+func TestBenchResultPercentile(t *testing.T) {
+	r := &BenchResult{}
+	assert.Equal(t, time.Duration(0), r.Percentile(50))
+
+	for i := 1; i <= 10; i++ {
+		r.Latencies = append(r.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, time.Millisecond, r.Percentile(0))
+	assert.Equal(t, 5*time.Millisecond, r.Percentile(50))
+	assert.Equal(t, 9*time.Millisecond, r.Percentile(90))
+	assert.Equal(t, 10*time.Millisecond, r.Percentile(99))
+	assert.Equal(t, 10*time.Millisecond, r.Percentile(100))
+}
+
+func TestBenchResultRate(t *testing.T) {
+	r := &BenchResult{Count: 10, Errors: 2, Bytes: 8 * 1024, Elapsed: 2 * time.Second}
+	assert.Equal(t, float64(4), r.OpsPerSecond())
+	assert.Equal(t, float64(4*1024), r.Throughput())
+
+	r.Elapsed = 0
+	assert.Equal(t, float64(0), r.OpsPerSecond())
+	assert.Equal(t, float64(0), r.Throughput())
+}
+
+func TestBenchRecorder(t *testing.T) {
+	br := &benchRecorder{result: &BenchResult{}}
+	br.record(time.Second, 10, nil)
+	br.record(time.Second, 10, assert.AnError)
+
+	assert.Equal(t, 2, br.result.Count)
+	assert.Equal(t, 1, br.result.Errors)
+	assert.Equal(t, int64(10), br.result.Bytes)
+	assert.Len(t, br.result.Latencies, 1)
+}
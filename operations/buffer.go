@@ -0,0 +1,122 @@
+package operations
+
+import (
+	"sync"
+)
+
+const (
+	defaultMaxMemory int64 = 1024 * 1024 * 1024 // 1G
+)
+
+// partBuffers holds parts of multipart objects of all operators in the
+// process, so that --max-memory covers all of them.
+var partBuffers = newPartBufferPool(defaultMaxMemory)
+
+// SetMaxMemory sets the memory budget of the buffers that hold parts of
+// multipart objects across all operators in the process. It could be called
+// at any time, and buffers over the new budget will be dropped once they are
+// put back.
+func SetMaxMemory(limit int64) {
+	partBuffers.setLimit(limit)
+}
+
+// partBufferPool manages the buffers used to hold parts of multipart objects.
+//
+// The total size of buffers allocated by this pool will not exceed limit:
+// Get blocks until enough buffers have been put back, which applies
+// backpressure to the reader. A single buffer larger than limit is still
+// allowed while no other buffers are allocated, so that a small limit will
+// not block forever.
+type partBufferPool struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	limit int64
+	// allocated is the total size of all buffers, including the free ones.
+	allocated int64
+	// free holds the buffers that could be reused, indexed by buffer size.
+	free map[int64][][]byte
+}
+
+func newPartBufferPool(limit int64) *partBufferPool {
+	p := &partBufferPool{
+		free: make(map[int64][][]byte),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	p.setLimit(limit)
+	return p
+}
+
+func (p *partBufferPool) setLimit(limit int64) {
+	if limit <= 0 {
+		limit = defaultMaxMemory
+	}
+
+	p.mu.Lock()
+	p.limit = limit
+	p.mu.Unlock()
+
+	p.cond.Broadcast()
+}
+
+// Get returns a buffer whose length is size.
+//
+// The returned buffer must be put back via Put after use.
+func (p *partBufferPool) Get(size int64) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		if bs := p.free[size]; len(bs) > 0 {
+			b := bs[len(bs)-1]
+			bs[len(bs)-1] = nil
+			p.free[size] = bs[:len(bs)-1]
+			return b
+		}
+
+		if p.allocated+size > p.limit {
+			p.evict(size)
+		}
+		if p.allocated+size <= p.limit || p.allocated == 0 {
+			p.allocated += size
+			return make([]byte, size)
+		}
+
+		p.cond.Wait()
+	}
+}
+
+// Put puts the buffer back to the pool so that it could be reused, or drops
+// it if the pool exceeds the limit, which could be lowered while buffers are
+// in use.
+func (p *partBufferPool) Put(b []byte) {
+	p.mu.Lock()
+	size := int64(cap(b))
+	if p.allocated > p.limit {
+		p.allocated -= size
+	} else {
+		p.free[size] = append(p.free[size], b[:size])
+	}
+	p.mu.Unlock()
+
+	p.cond.Broadcast()
+}
+
+// evict drops free buffers until a buffer with size could be allocated.
+//
+// evict must be called with p.mu held.
+func (p *partBufferPool) evict(size int64) {
+	for k, bs := range p.free {
+		for len(bs) > 0 && p.allocated+size > p.limit {
+			// Clear the reference so that the buffer could be collected.
+			bs[len(bs)-1] = nil
+			bs = bs[:len(bs)-1]
+			p.allocated -= k
+		}
+		p.free[k] = bs
+
+		if p.allocated+size <= p.limit {
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package operations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartBufferPool(t *testing.T) {
+	p := newPartBufferPool(8)
+
+	a := p.Get(4)
+	b := p.Get(4)
+	assert.Equal(t, 4, len(a))
+	assert.Equal(t, int64(8), p.allocated)
+
+	got := make(chan []byte)
+	go func() {
+		got <- p.Get(4)
+	}()
+
+	select {
+	case <-got:
+		t.Fatal("get should be blocked while memory budget is used up")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	p.Put(a)
+	c := <-got
+	assert.Equal(t, 4, len(c))
+	// The buffer should be reused instead of allocated.
+	assert.Equal(t, int64(8), p.allocated)
+
+	// Free buffers with other size should be evicted.
+	p.Put(b)
+	p.Put(c)
+	d := p.Get(6)
+	assert.Equal(t, 6, len(d))
+	assert.LessOrEqual(t, p.allocated, int64(8))
+
+	// Buffer larger than limit is allowed while no other buffers allocated.
+	p.Put(d)
+	e := p.Get(16)
+	assert.Equal(t, 16, len(e))
+}
+
+func TestPartBufferPoolSetLimit(t *testing.T) {
+	p := newPartBufferPool(4)
+	a := p.Get(4)
+
+	got := make(chan []byte)
+	go func() {
+		got <- p.Get(4)
+	}()
+
+	// Raising the limit wakes up the blocked Get.
+	p.setLimit(8)
+	b := <-got
+	assert.Equal(t, int64(8), p.allocated)
+
+	// Buffers over the lowered limit are dropped once they are put back.
+	p.setLimit(4)
+	p.Put(a)
+	assert.Equal(t, int64(4), p.allocated)
+	p.Put(b)
+	assert.Equal(t, int64(4), p.allocated)
+	assert.Len(t, p.free[4], 1)
+}
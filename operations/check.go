@@ -0,0 +1,66 @@
+package operations
+
+import (
+	"errors"
+	"time"
+
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/types"
+)
+
+// CheckResult is the result for a single step of storager check.
+// Error will be nil if this step succeeded.
+type CheckResult struct {
+	Operation string
+	Path      string
+	Duration  time.Duration
+	Error     error
+}
+
+// CheckStorager checks connectivity and permissions of the storager via a
+// Stat/List round-trip under path.
+//
+// We will:
+// - List the first object under path.
+// - Stat the listed object, or path itself if nothing listed.
+func (so *SingleOperator) CheckStorager(path string) []*CheckResult {
+	rs := make([]*CheckResult, 0, 2)
+
+	start := time.Now()
+	var listed *types.Object
+	it, err := so.store.List(path, pairs.WithListMode(types.ListModeDir))
+	if err == nil {
+		listed, err = it.Next()
+		if err != nil && errors.Is(err, types.IterateDone) {
+			listed, err = nil, nil
+		}
+	}
+	rs = append(rs, &CheckResult{
+		Operation: "list",
+		Path:      path,
+		Duration:  time.Since(start),
+		Error:     err,
+	})
+	if err != nil {
+		return rs
+	}
+
+	statPath := path
+	if listed != nil {
+		statPath = listed.Path
+	}
+	// Nothing to stat for the root of an empty storage.
+	if statPath == "" {
+		return rs
+	}
+
+	start = time.Now()
+	_, err = so.Stat(statPath)
+	rs = append(rs, &CheckResult{
+		Operation: "stat",
+		Path:      statPath,
+		Duration:  time.Since(start),
+		Error:     err,
+	})
+	return rs
+}
@@ -0,0 +1,367 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/panjf2000/ants/v2"
+
+	"go.beyondstorage.io/v5/services"
+)
+
+// Kinds of worker pools. Listing, files and parts of multipart objects run in
+// separate pools, so that they are tuned separately and will not occupy the
+// workers of each other.
+const (
+	poolKindList = "list"
+	poolKindFile = "file"
+	poolKindPart = "part"
+)
+
+const (
+	defaultWorkers          = 4
+	defaultAdaptiveInterval = time.Second
+)
+
+var (
+	// workerSlots limits the running tasks across all worker pools in the
+	// process.
+	workerSlots = newSlots()
+
+	// adaptiveWorkers are the adaptive options of operators created later.
+	adaptiveWorkers   AdaptiveOptions
+	adaptiveWorkersMu sync.Mutex
+)
+
+// SetMaxWorkers sets the cap of running workers across all operators in the
+// process, zero means unlimited.
+//
+// Every task submitted to worker pools holds a slot of the cap while it's
+// running. Besides, fixed size pools created later will be clamped to the
+// cap, and adaptive pools will only grow while the running workers are below
+// the cap, and shrink while they exceed it.
+func SetMaxWorkers(n int) {
+	workerSlots.setMax(n)
+}
+
+// slots is a semaphore whose size could be changed while it's in use.
+type slots struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	max     int // Zero means unlimited.
+	running int
+}
+
+func newSlots() *slots {
+	s := &slots{}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *slots) setMax(n int) {
+	if n < 0 {
+		n = 0
+	}
+
+	s.mu.Lock()
+	s.max = n
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// acquire blocks until a slot is free.
+func (s *slots) acquire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.max > 0 && s.running >= s.max {
+		s.cond.Wait()
+	}
+	s.running++
+}
+
+func (s *slots) release() {
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+	s.cond.Signal()
+}
+
+// stats returns the cap and the number of acquired slots.
+func (s *slots) stats() (max, running int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.max, s.running
+}
+
+// yieldWorker runs fn with the slot of the current task released, so that
+// tasks blocked by other tasks will not occupy the cap, for example, while
+// they are waiting for the parts of a multipart object. It must only be
+// called by tasks submitted to worker pools.
+func yieldWorker(fn func()) {
+	workerSlots.release()
+	defer workerSlots.acquire()
+
+	fn()
+}
+
+// SetAdaptiveWorkers sets the adaptive options of operators created later,
+// they could be overridden via WithAdaptiveWorkers of every operator.
+func SetAdaptiveWorkers(adaptive AdaptiveOptions) {
+	adaptiveWorkersMu.Lock()
+	defer adaptiveWorkersMu.Unlock()
+
+	adaptiveWorkers = adaptive
+}
+
+func defaultAdaptiveWorkers() AdaptiveOptions {
+	adaptiveWorkersMu.Lock()
+	defer adaptiveWorkersMu.Unlock()
+
+	return adaptiveWorkers
+}
+
+// clampWorkers clamps size into [1, the global cap].
+func clampWorkers(size int) int {
+	if max, _ := workerSlots.stats(); max > 0 && size > max {
+		size = max
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// AdaptiveOptions are options of adaptive worker pools.
+//
+// An adaptive pool starts from the configured size, and is tuned every
+// Interval in [1, Max] via observed throughput, latency and error rate of the
+// finished tasks: it shrinks by half while requests are throttled or failing,
+// grows by one while it's saturated and the throughput is growing, and shrinks
+// by one while the throughput drops or the latency grows without more
+// throughput.
+type AdaptiveOptions struct {
+	// Max is the max size of every pool, zero means the pools are not
+	// adaptive.
+	Max int
+	// Interval is the interval between tunes, zero means one second.
+	Interval time.Duration
+}
+
+func (a AdaptiveOptions) enabled() bool {
+	return a.Max > 0
+}
+
+// workerPool is a worker pool of a kind, its size will be tuned by the
+// controller if it's adaptive.
+type workerPool struct {
+	kind string
+	pool *ants.Pool
+	ctrl *controller
+	// capped means tasks hold slots of the global cap while running. Listing
+	// tasks are short and never wait for others, while the consumers of
+	// their results may be waiting for slots, so they are not capped.
+	capped bool
+}
+
+func newWorkerPool(kind string, size int, adaptive AdaptiveOptions) (*workerPool, error) {
+	if size <= 0 {
+		size = defaultWorkers
+	}
+
+	var ctrl *controller
+	if adaptive.enabled() {
+		ctrl = newController(adaptive)
+		size = ctrl.clamp(size)
+	} else {
+		size = clampWorkers(size)
+	}
+
+	pool, err := ants.NewPool(size)
+	if err != nil {
+		return nil, fmt.Errorf("init %s worker pool: %w", kind, err)
+	}
+	return &workerPool{kind: kind, pool: pool, ctrl: ctrl, capped: kind != poolKindList}, nil
+}
+
+// mustNewWorkerPool is the same as newWorkerPool, but panics on error like
+// the operator constructors.
+func mustNewWorkerPool(kind string, size int, adaptive AdaptiveOptions) *workerPool {
+	p, err := newWorkerPool(kind, size, adaptive)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Submit submits task to the pool, and blocks if the pool is full. The task
+// waits for a slot of the global cap before running if the pool is capped.
+// The error returned by task is observed by the controller of adaptive pools.
+func (p *workerPool) Submit(task func() error) error {
+	if p.ctrl != nil {
+		p.ctrl.start(p)
+	}
+
+	return p.pool.Submit(func() {
+		if p.capped {
+			workerSlots.acquire()
+			defer workerSlots.release()
+		}
+
+		start := time.Now()
+		err := task()
+		if p.ctrl != nil {
+			p.ctrl.observe(time.Since(start), err)
+		}
+	})
+}
+
+// Size returns the current size of the pool.
+func (p *workerPool) Size() int {
+	return p.pool.Cap()
+}
+
+// Release releases the pool, the controller will exit at the next tune.
+func (p *workerPool) Release() {
+	p.pool.Release()
+}
+
+// tuneWindow is the stats of tasks finished between two tunes.
+type tuneWindow struct {
+	elapsed   time.Duration
+	completed int
+	failed    int
+	throttled int
+	latency   time.Duration // The sum of latencies of completed tasks.
+
+	// saturated means all workers are busy or tasks are waiting.
+	saturated bool
+	// atCap and overCap mean the running workers of the process have reached
+	// or exceeded the global cap.
+	atCap   bool
+	overCap bool
+}
+
+// controller tunes the size of an adaptive pool.
+type controller struct {
+	max      int
+	interval time.Duration
+
+	mu      sync.Mutex
+	window  tuneWindow
+	running bool
+
+	// lastThroughput and baseLatency are only accessed by next.
+	lastThroughput float64
+	baseLatency    time.Duration
+}
+
+func newController(adaptive AdaptiveOptions) *controller {
+	interval := adaptive.Interval
+	if interval <= 0 {
+		interval = defaultAdaptiveInterval
+	}
+	return &controller{max: adaptive.Max, interval: interval}
+}
+
+// clamp clamps size into [1, max] and the global cap.
+func (c *controller) clamp(size int) int {
+	if size > c.max {
+		size = c.max
+	}
+	return clampWorkers(size)
+}
+
+// start starts tuning p if it's not tuning.
+func (c *controller) start(p *workerPool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.running {
+		return
+	}
+	c.running = true
+	go c.run(p)
+}
+
+// run tunes p periodically, and exits if p is released or idle, so that idle
+// pools will not hold goroutines.
+func (c *controller) run(p *workerPool) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for now := range ticker.C {
+		c.mu.Lock()
+		w := c.window
+		c.window = tuneWindow{}
+		idle := w.completed == 0 && p.pool.Running() == 0 && p.pool.Waiting() == 0
+		if idle || p.pool.IsClosed() {
+			c.running = false
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		w.elapsed = now.Sub(last)
+		last = now
+		w.saturated = p.pool.Waiting() > 0 || p.pool.Running() >= p.pool.Cap()
+		if max, running := workerSlots.stats(); max > 0 {
+			w.atCap = running >= max
+			w.overCap = running > max
+		}
+
+		p.pool.Tune(c.next(p.pool.Cap(), w))
+	}
+}
+
+// observe records a finished task.
+func (c *controller) observe(latency time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.window.completed++
+	c.window.latency += latency
+	if err == nil {
+		return
+	}
+	c.window.failed++
+	if errors.Is(err, services.ErrRequestThrottled) {
+		c.window.throttled++
+	}
+}
+
+// next returns the next size of a pool of size via the stats of w.
+func (c *controller) next(size int, w tuneWindow) int {
+	switch {
+	case w.throttled > 0 || w.failed*10 > w.completed:
+		// Back off quickly while the storage is overloaded, and start
+		// probing again from the new size.
+		size /= 2
+		c.lastThroughput = 0
+	case w.overCap:
+		size--
+	case w.completed == 0 || w.elapsed <= 0:
+		// Nothing has been finished, keep the size until we know more.
+	default:
+		throughput := float64(w.completed) / w.elapsed.Seconds()
+		latency := w.latency / time.Duration(w.completed)
+		if c.baseLatency == 0 || latency < c.baseLatency {
+			c.baseLatency = latency
+		}
+
+		switch {
+		case c.lastThroughput > 0 && throughput < c.lastThroughput*0.9:
+			size--
+		case latency > 2*c.baseLatency && throughput < c.lastThroughput*1.05:
+			size--
+		case w.saturated && !w.atCap && throughput >= c.lastThroughput:
+			size++
+		}
+		c.lastThroughput = throughput
+	}
+	return c.clamp(size)
+}
@@ -0,0 +1,174 @@
+package operations
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.beyondstorage.io/v5/services"
+)
+
+func TestControllerNext(t *testing.T) {
+	c := newController(AdaptiveOptions{Max: 16})
+
+	// Grow while saturated and the throughput is growing.
+	assert.Equal(t, 5, c.next(4, tuneWindow{
+		elapsed: time.Second, completed: 40, latency: 40 * 100 * time.Millisecond, saturated: true,
+	}))
+	assert.Equal(t, 6, c.next(5, tuneWindow{
+		elapsed: time.Second, completed: 50, latency: 50 * 100 * time.Millisecond, saturated: true,
+	}))
+	// Keep the size if it's not saturated.
+	assert.Equal(t, 6, c.next(6, tuneWindow{
+		elapsed: time.Second, completed: 50, latency: 50 * 100 * time.Millisecond,
+	}))
+	// Shrink while the throughput drops.
+	assert.Equal(t, 5, c.next(6, tuneWindow{
+		elapsed: time.Second, completed: 40, latency: 40 * 100 * time.Millisecond, saturated: true,
+	}))
+	// Shrink while the latency grows without more throughput.
+	assert.Equal(t, 4, c.next(5, tuneWindow{
+		elapsed: time.Second, completed: 40, latency: 40 * 300 * time.Millisecond, saturated: true,
+	}))
+	// Don't grow at the global cap, and shrink over it.
+	assert.Equal(t, 4, c.next(4, tuneWindow{
+		elapsed: time.Second, completed: 80, latency: 80 * 100 * time.Millisecond, saturated: true, atCap: true,
+	}))
+	assert.Equal(t, 3, c.next(4, tuneWindow{
+		elapsed: time.Second, completed: 80, latency: 80 * 100 * time.Millisecond, saturated: true, atCap: true, overCap: true,
+	}))
+	// Keep the size if nothing has been finished.
+	assert.Equal(t, 3, c.next(3, tuneWindow{elapsed: time.Second, saturated: true}))
+	// Never exceed max or drop below one.
+	assert.Equal(t, 16, c.next(32, tuneWindow{elapsed: time.Second, saturated: true}))
+	assert.Equal(t, 1, c.next(1, tuneWindow{elapsed: time.Second, completed: 1, failed: 1}))
+}
+
+func TestControllerBackOff(t *testing.T) {
+	c := newController(AdaptiveOptions{Max: 16})
+
+	// Halve on throttling.
+	c.observe(time.Millisecond, fmt.Errorf("write: %w", services.ErrRequestThrottled))
+	for i := 0; i < 99; i++ {
+		c.observe(time.Millisecond, nil)
+	}
+	w := c.window
+	assert.Equal(t, 100, w.completed)
+	assert.Equal(t, 1, w.failed)
+	assert.Equal(t, 1, w.throttled)
+	assert.Equal(t, 8, c.next(16, w))
+
+	// Halve if more than 10% tasks failed.
+	assert.Equal(t, 4, c.next(8, tuneWindow{elapsed: time.Second, completed: 10, failed: 2}))
+	assert.Equal(t, 4, c.next(4, tuneWindow{elapsed: time.Second, completed: 10, failed: 1}))
+}
+
+func TestClampWorkers(t *testing.T) {
+	defer SetMaxWorkers(0)
+
+	assert.Equal(t, 1, clampWorkers(0))
+	assert.Equal(t, 64, clampWorkers(64))
+
+	SetMaxWorkers(8)
+	assert.Equal(t, 8, clampWorkers(64))
+	assert.Equal(t, 4, clampWorkers(4))
+
+	p, err := newWorkerPool(poolKindFile, 16, AdaptiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+	assert.Equal(t, 8, p.Size())
+
+	ap, err := newWorkerPool(poolKindPart, 16, AdaptiveOptions{Max: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ap.Release()
+	assert.Equal(t, 4, ap.Size())
+}
+
+func TestWorkerSlots(t *testing.T) {
+	SetMaxWorkers(2)
+	defer SetMaxWorkers(0)
+
+	// Tasks of all pools share the cap.
+	var running, peak int32
+	wg := &sync.WaitGroup{}
+	for _, kind := range []string{poolKindFile, poolKindPart} {
+		p, err := newWorkerPool(kind, 2, AdaptiveOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Release()
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			err = p.Submit(func() error {
+				defer wg.Done()
+
+				n := atomic.AddInt32(&running, 1)
+				for {
+					old := atomic.LoadInt32(&peak)
+					if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), peak)
+}
+
+func TestYieldWorker(t *testing.T) {
+	SetMaxWorkers(1)
+	defer SetMaxWorkers(0)
+
+	file, err := newWorkerPool(poolKindFile, 1, AdaptiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Release()
+	part, err := newWorkerPool(poolKindPart, 1, AdaptiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer part.Release()
+
+	// The part task could run while the file task is waiting for it.
+	done := make(chan struct{})
+	err = file.Submit(func() error {
+		defer close(done)
+
+		partDone := make(chan struct{})
+		err := part.Submit(func() error {
+			close(partDone)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		yieldWorker(func() { <-partDone })
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("file task should not be blocked by its part")
+	}
+}
@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"sort"
@@ -15,6 +16,12 @@ import (
 
 // CopyFileViaWrite will copy a file via Write operation.
 func (do *DualOperator) CopyFileViaWrite(src, dst string, size int64) (ch chan *EmptyResult, err error) {
+	srcObj, err := do.statPreserved(src)
+	if err != nil {
+		return nil, err
+	}
+	meta := do.writeMetadata(srcObj)
+
 	ch = make(chan *EmptyResult, 4)
 
 	r, w := io.Pipe()
@@ -37,11 +44,36 @@ func (do *DualOperator) CopyFileViaWrite(src, dst string, size int64) (ch chan *
 
 	go func() {
 		defer close(ch)
+		// Close the reader to unblock the read side if write failed.
+		defer r.Close()
+
+		var body io.Reader = r
+		var head []byte
+		if meta.needContent(dst) {
+			var err error
+			head, body, err = peekContent(r)
+			if err != nil {
+				do.logger.Error("pipe read", zap.String("path", src), zap.Error(err))
+				ch <- &EmptyResult{Error: err}
+				return
+			}
+		}
 
-		_, err := do.dst.Write(dst, r, size, do.writePairs...)
+		ps := make([]types.Pair, 0, len(do.writePairs)+len(meta.pairs)+1)
+		ps = append(ps, do.writePairs...)
+		ps = append(ps, meta.pairsFor(dst, head)...)
+
+		_, err := do.dst.Write(dst, body, size, ps...)
 		if err != nil {
 			do.logger.Error("pipe write", zap.String("path", dst), zap.Error(err))
 			ch <- &EmptyResult{Error: err}
+			return
+		}
+
+		err = do.applyPreserved(srcObj, src, dst)
+		if err != nil {
+			do.logger.Error("preserve", zap.String("path", dst), zap.Error(err))
+			ch <- &EmptyResult{Error: err}
 		}
 	}()
 
@@ -55,6 +87,10 @@ func (do *DualOperator) CopyFileViaWrite(src, dst string, size int64) (ch chan *
 // - Write into this multipart object via split source file into parts (read by offset)
 // - Complete the multipart object.
 //
+// Every part will be read into a buffer fetched from the part buffer pool, and
+// the buffer will be passed to WriteMultipart directly. We will wait for a free
+// buffer before reading the next part, so the memory usage is bounded.
+//
 // We have two channels have:
 // - errch is returned to cmd and used as an error channel.
 // - partch is used internally to control the part copy multipart logic.
@@ -67,38 +103,60 @@ func (do *DualOperator) CopyFileViaMultipart(src, dst string, totalSize int64) (
 		return nil, fmt.Errorf("dst is not a dstMultiparter")
 	}
 
-	dstObj, err := dstMultiparter.CreateMultipart(dst)
+	partSize, err := getPartSize(do.dst, totalSize, do.partSize)
 	if err != nil {
-		return nil, fmt.Errorf("create multipart: %w", err)
+		return nil, fmt.Errorf("get part size: %w", err)
+	}
+
+	srcObj, err := do.statPreserved(src)
+	if err != nil {
+		return nil, err
 	}
+	meta := do.writeMetadata(srcObj)
 
-	partSize, err := calculatePartSize(do.dst, totalSize)
+	partPool, err := newPartPool(do.partConcurrency, do.adaptive)
 	if err != nil {
-		return nil, fmt.Errorf("calculate part size: %w", err)
+		return nil, fmt.Errorf("init part pool: %w", err)
+	}
+
+	head := do.readHead(meta, src, dst, totalSize)
+	dstObj, err := dstMultiparter.CreateMultipart(dst, meta.pairsFor(dst, head)...)
+	if err != nil {
+		partPool.Release()
+		return nil, fmt.Errorf("create multipart: %w", err)
 	}
 
 	go func() {
 		// Close partch to inform that all parts have been done.
 		defer close(partch)
+		defer partPool.Release()
 
 		wg := &sync.WaitGroup{}
 		var offset int64
 		var index int
 
-		for {
-			wg.Add(1)
+		// All buffers share the same size so that they could be reused
+		// for the last part.
+		bufSize := partSize
 
+		for {
 			// Reallocate var here to prevent closure catch.
 			taskSize := partSize
 			taskIndex := index
 			taskOffset := offset
 
-			err = do.pool.Submit(func() {
-				do.copyMultipart(partch, wg, src, dstObj, taskSize, taskOffset, taskIndex)
+			// Wait for a free buffer before submitting the part.
+			b := do.buffers.Get(bufSize)
+
+			wg.Add(1)
+			err := partPool.Submit(func() error {
+				return do.copyMultipart(partch, wg, src, dstObj, b[:taskSize], taskOffset, taskIndex)
 			})
 			if err != nil {
+				do.buffers.Put(b)
+				wg.Done()
 				do.logger.Error("submit task", zap.Error(err))
-				errch <- &EmptyResult{Error: err}
+				partch <- &PartResult{Error: err}
 				break
 			}
 
@@ -122,85 +180,91 @@ func (do *DualOperator) CopyFileViaMultipart(src, dst string, totalSize int64) (
 		defer close(errch)
 
 		parts := make([]*types.Part, 0)
+		failed := false
 		for v := range partch {
 			if v.Error != nil {
+				failed = true
 				errch <- &EmptyResult{Error: v.Error}
 				continue
 			}
 			parts = append(parts, v.Part)
 		}
 
+		// Don't complete the multipart object with missing parts.
+		if failed {
+			return
+		}
+
 		sort.SliceStable(parts, func(i, j int) bool {
 			return parts[i].Index < parts[j].Index
 		})
 
-		err = dstMultiparter.CompleteMultipart(dstObj, parts)
+		err := dstMultiparter.CompleteMultipart(dstObj, parts)
 		if err != nil {
 			errch <- &EmptyResult{Error: err}
 			return
 		}
+
+		err = do.applyPreserved(srcObj, src, dst)
+		if err != nil {
+			do.logger.Error("preserve", zap.String("path", dst), zap.Error(err))
+			errch <- &EmptyResult{Error: err}
+		}
 	}()
 
 	return errch, nil
 }
 
+// copyMultipart reads a part from src into b and writes it into dstObj.
+//
+// b will be put back to the part buffer pool after the part has been written.
+// The error is sent into ch, and also returned so that the part pool could
+// observe it.
 func (do *DualOperator) copyMultipart(
 	ch chan *PartResult, wg *sync.WaitGroup,
 	src string, dstObj *types.Object,
-	size, offset int64, index int,
-) {
+	b []byte, offset int64, index int,
+) error {
 	defer wg.Done()
+	defer do.buffers.Put(b)
 
-	r, w := io.Pipe()
-
-	go func() {
-		defer func() {
-			err := w.Close()
-			if err != nil {
-				do.logger.Error("close pipe writer", zap.Error(err))
-				ch <- &PartResult{Error: err}
-			}
-		}()
+	size := int64(len(b))
 
-		ps := make([]types.Pair, 0, len(do.readPairs)+2)
-		ps = append(ps, pairs.WithSize(size), pairs.WithOffset(offset))
-		ps = append(ps, do.readPairs...)
+	ps := make([]types.Pair, 0, len(do.readPairs)+2)
+	ps = append(ps, pairs.WithSize(size), pairs.WithOffset(offset))
+	ps = append(ps, do.readPairs...)
 
-		_, err := do.src.Read(src, w, ps...)
-		if err != nil {
-			do.logger.Error("pipe read", zap.String("path", src), zap.Error(err))
-			ch <- &PartResult{Error: err}
-		}
-	}()
-
-	defer func() {
-		err := r.Close()
-		if err != nil {
-			do.logger.Error("close pipe reader", zap.Error(err))
-			ch <- &PartResult{Error: err}
-		}
-	}()
+	buf := bytes.NewBuffer(b[:0])
+	_, err := do.src.Read(src, buf, ps...)
+	if err != nil {
+		do.logger.Error("read part", zap.String("path", src), zap.Error(err))
+		ch <- &PartResult{Error: err}
+		return err
+	}
+	if int64(buf.Len()) != size {
+		err = fmt.Errorf("read part at offset %d: expected %d bytes, but got %d", offset, size, buf.Len())
+		do.logger.Error("read part", zap.String("path", src), zap.Error(err))
+		ch <- &PartResult{Error: err}
+		return err
+	}
 
 	multiparter := do.dst.(types.Multiparter)
 
-	_, p, err := multiparter.WriteMultipart(dstObj, r, size, index, do.writePairs...)
+	_, p, err := multiparter.WriteMultipart(dstObj, bytes.NewReader(buf.Bytes()), size, index, do.writePairs...)
 	if err != nil {
-		do.logger.Error("pipe write", zap.String("path", dstObj.Path), zap.Error(err))
+		do.logger.Error("write part", zap.String("path", dstObj.Path), zap.Error(err))
 		ch <- &PartResult{Error: err}
-		return
+		return err
 	}
 	ch <- &PartResult{Part: p}
+	return nil
 }
 
 // CopyRecursively will copy directories recursively.
 func (do *DualOperator) CopyRecursively(src, dst string, multipartThreshold int64) (errch chan *EmptyResult, err error) {
 	errch = make(chan *EmptyResult, 4)
 
-	so := NewSingleOperator(do.src)
-	och, err := so.ListRecursively(src)
-	if err != nil {
-		errch <- &EmptyResult{Error: err}
-	}
+	och := listRecursively(do.src, do.listPool, src)
 
 	if !strings.HasSuffix(dst, "/") {
 		dst += "/"
@@ -213,7 +277,7 @@ func (do *DualOperator) CopyRecursively(src, dst string, multipartThreshold int6
 
 		for or := range och {
 			if or.Error != nil {
-				errch <- &EmptyResult{Error: err}
+				errch <- &EmptyResult{Error: or.Error}
 				break
 			}
 			object := or.Object
@@ -226,37 +290,48 @@ func (do *DualOperator) CopyRecursively(src, dst string, multipartThreshold int6
 			size := object.MustGetContentLength()
 
 			wg.Add(1)
-			err = so.pool.Submit(func() {
+			err := do.pool.Submit(func() error {
 				defer wg.Done()
 
-				if size < multipartThreshold {
-					ch, err := do.CopyFileViaWrite(object.Path, path, size)
-					if err != nil {
-						errch <- &EmptyResult{Error: err}
-					}
-					for er := range ch {
-						if er.Error != nil {
-							errch <- &EmptyResult{Error: err}
-						}
-					}
+				var ch chan *EmptyResult
+				var err error
+				multipart := size >= multipartThreshold
+				if multipart {
+					ch, err = do.CopyFileViaMultipart(object.Path, path, size)
 				} else {
-					ch, err := do.CopyFileViaMultipart(object.Path, path, size)
-					if err != nil {
-						errch <- &EmptyResult{Error: err}
-					}
+					ch, err = do.CopyFileViaWrite(object.Path, path, size)
+				}
+				if err != nil {
+					errch <- &EmptyResult{Error: err}
+					return err
+				}
+				wait := func() {
 					for er := range ch {
 						if er.Error != nil {
-							errch <- &EmptyResult{Error: err}
+							err = er.Error
+							errch <- &EmptyResult{Error: er.Error}
 						}
 					}
 				}
+				if multipart {
+					// Parts are copied by the part pool, don't occupy the
+					// cap of workers while waiting for them.
+					yieldWorker(wait)
+				} else {
+					wait()
+				}
+				return err
 			})
 			if err != nil {
+				wg.Done()
 				errch <- &EmptyResult{Error: err}
 				break
 			}
 		}
 
+		// Keep draining och, so that the list goroutines could exit.
+		for range och {
+		}
 		wg.Wait()
 	}()
 
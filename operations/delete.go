@@ -42,16 +42,17 @@ func (so *SingleOperator) DeleteMultipart(path string) (ch chan *EmptyResult, er
 
 			wg.Add(1)
 
-			err = so.pool.Submit(func() {
+			err = so.pool.Submit(func() error {
 				defer wg.Done()
 
 				if o.Path == path {
-					err = so.Delete(path, pairs.WithMultipartID(o.MustGetMultipartID()))
+					err := so.Delete(path, pairs.WithMultipartID(o.MustGetMultipartID()))
 					if err != nil {
 						ch <- &EmptyResult{Error: err}
-						return
+						return err
 					}
 				}
+				return nil
 			})
 			if err != nil {
 				ch <- &EmptyResult{Error: err}
@@ -90,16 +91,15 @@ func (so *SingleOperator) DeleteMultipartViaRecursively(path string) (ch chan *E
 
 			wg.Add(1)
 
-			err = so.pool.Submit(func() {
+			err = so.pool.Submit(func() error {
 				defer wg.Done()
 
-				err = so.Delete(o.Path, pairs.WithMultipartID(o.MustGetMultipartID()))
+				err := so.Delete(o.Path, pairs.WithMultipartID(o.MustGetMultipartID()))
 				if err != nil {
-					if err != nil {
-						ch <- &EmptyResult{Error: err}
-						return
-					}
+					ch <- &EmptyResult{Error: err}
+					return err
 				}
+				return nil
 			})
 			if err != nil {
 				ch <- &EmptyResult{Error: err}
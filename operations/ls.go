@@ -2,6 +2,7 @@ package operations
 
 import (
 	"errors"
+	"sync"
 
 	"go.beyondstorage.io/v5/pairs"
 	"go.beyondstorage.io/v5/types"
@@ -33,41 +34,221 @@ func (so *SingleOperator) List(path string) (ch chan *ObjectResult, err error) {
 
 	return ch, nil
 }
+
+// ListRecursively lists all objects under path. Objects in a dir are sent
+// before the dir itself, and in the same order as listing dirs one by one.
+// Sub dirs of the dirs being sent are listed ahead via the list pool.
 func (so *SingleOperator) ListRecursively(path string) (ch chan *ObjectResult, err error) {
+	return listRecursively(so.store, so.listPool, path), nil
+}
+
+func listRecursively(store types.Storager, pool *workerPool, path string) (ch chan *ObjectResult) {
 	ch = make(chan *ObjectResult, 16)
 
 	go func() {
 		defer close(ch)
 
-		so.listRecursively(ch, path)
+		q := newDirQueue()
+		defer q.close()
+		go func() {
+			for {
+				d, ok := q.pop()
+				if !ok {
+					return
+				}
+
+				err := pool.Submit(func() error {
+					return d.list(store, q)
+				})
+				if err != nil {
+					d.finish(err)
+				}
+			}
+		}()
+
+		root := newDirListing(path)
+		q.push(root)
+		sendDir(ch, q, root)
 	}()
 
-	return ch, nil
+	return ch
 }
 
-func (so *SingleOperator) listRecursively(
-	ch chan *ObjectResult,
-	path string,
-) {
-	it, err := so.store.List(path, pairs.WithListMode(types.ListModeDir))
+// sendDir sends objects in d into ch, objects in sub dirs will be sent before
+// the sub dirs.
+func sendDir(ch chan *ObjectResult, q *dirQueue, d *dirListing) {
+	d.activate(q)
+
+	for i := 0; ; i++ {
+		e, ok := d.entry(i)
+		if !ok {
+			break
+		}
+		if e.dir != nil {
+			sendDir(ch, q, e.dir)
+		}
+		ch <- &ObjectResult{Object: e.object}
+	}
+	if d.err != nil {
+		ch <- &ObjectResult{Error: d.err}
+	}
+}
+
+// dirEntry is an object in a dir, dir is the listing of it if it's a dir.
+type dirEntry struct {
+	object *types.Object
+	dir    *dirListing
+}
+
+// dirListing is the result of listing a dir, which will be read while it's
+// being listed.
+type dirListing struct {
+	path string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	entries []dirEntry
+	done    bool
+	err     error // Only read after done.
+	// Sub dirs will only be listed after the dir is activated, so that at
+	// most the sub dirs of the dirs being sent are listed ahead.
+	active  bool
+	pending []*dirListing
+}
+
+func newDirListing(path string) *dirListing {
+	d := &dirListing{path: path}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+// list lists d via store, and pushes sub dirs into q once d is activated.
+func (d *dirListing) list(store types.Storager, q *dirQueue) error {
+	it, err := store.List(d.path, pairs.WithListMode(types.ListModeDir))
 	if err != nil {
-		ch <- &ObjectResult{Error: err}
-		return
+		d.finish(err)
+		return err
 	}
 
 	for {
 		o, err := it.Next()
 		if err != nil && errors.Is(err, types.IterateDone) {
-			break
+			d.finish(nil)
+			return nil
 		}
 		if err != nil {
-			ch <- &ObjectResult{Error: err}
-			break
+			d.finish(err)
+			return err
 		}
 
+		e := dirEntry{object: o}
 		if o.Mode.IsDir() {
-			so.listRecursively(ch, o.Path)
+			e.dir = newDirListing(o.Path)
 		}
-		ch <- &ObjectResult{Object: o}
+		d.add(e, q)
 	}
 }
+
+func (d *dirListing) add(e dirEntry, q *dirQueue) {
+	d.mu.Lock()
+	d.entries = append(d.entries, e)
+	if e.dir != nil {
+		if d.active {
+			q.push(e.dir)
+		} else {
+			d.pending = append(d.pending, e.dir)
+		}
+	}
+	d.mu.Unlock()
+	d.cond.Broadcast()
+}
+
+func (d *dirListing) finish(err error) {
+	d.mu.Lock()
+	d.done = true
+	d.err = err
+	d.mu.Unlock()
+	d.cond.Broadcast()
+}
+
+// activate pushes sub dirs found before into q, and later ones will be
+// pushed once they are found.
+func (d *dirListing) activate(q *dirQueue) {
+	d.mu.Lock()
+	d.active = true
+	pending := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+
+	for _, v := range pending {
+		q.push(v)
+	}
+}
+
+// entry returns the i-th entry of d, and blocks until it's listed. false will
+// be returned if d has been listed without it.
+func (d *dirListing) entry(i int) (dirEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for len(d.entries) <= i && !d.done {
+		d.cond.Wait()
+	}
+	if i >= len(d.entries) {
+		return dirEntry{}, false
+	}
+
+	e := d.entries[i]
+	// Entries are read only once, release them as soon as possible.
+	d.entries[i] = dirEntry{}
+	return e, true
+}
+
+// dirQueue is the queue of dirs to be listed. Dirs are pushed without
+// blocking, so that listing tasks will never wait for the submitter which
+// could be waiting for a free worker.
+type dirQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	dirs   []*dirListing
+	closed bool
+}
+
+func newDirQueue() *dirQueue {
+	q := &dirQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// pop returns the next dir to be listed, false will be returned if q has
+// been closed.
+func (q *dirQueue) pop() (*dirListing, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.dirs) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return nil, false
+	}
+
+	d := q.dirs[0]
+	q.dirs = q.dirs[1:]
+	return d, true
+}
+
+func (q *dirQueue) push(d *dirListing) {
+	q.mu.Lock()
+	q.dirs = append(q.dirs, d)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// close stops q, dirs not popped will not be listed.
+func (q *dirQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Signal()
+}
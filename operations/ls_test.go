@@ -0,0 +1,132 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.beyondstorage.io/v5/types"
+)
+
+// treeStorager lists objects from dirs indexed by path.
+type treeStorager struct {
+	types.Storager
+	dirs map[string][]*types.Object
+}
+
+func newTreeStorager(paths ...string) *treeStorager {
+	s := &treeStorager{dirs: make(map[string][]*types.Object)}
+	for _, p := range paths {
+		dir := path.Dir(strings.TrimSuffix(p, "/")) + "/"
+
+		o := &types.Object{Path: p, Mode: types.ModeRead}
+		if strings.HasSuffix(p, "/") {
+			o.Mode = types.ModeDir
+			if _, ok := s.dirs[p]; !ok {
+				s.dirs[p] = nil
+			}
+		}
+		s.dirs[dir] = append(s.dirs[dir], o)
+	}
+	return s
+}
+
+func (s *treeStorager) List(dir string, pairs ...types.Pair) (*types.ObjectIterator, error) {
+	objs, ok := s.dirs[dir]
+	if !ok {
+		return nil, fmt.Errorf("dir %s not exist", dir)
+	}
+
+	done := false
+	return types.NewObjectIterator(context.Background(), func(ctx context.Context, page *types.ObjectPage) error {
+		if done {
+			return types.IterateDone
+		}
+		done = true
+		page.Data = append(page.Data, objs...)
+		return nil
+	}, nil), nil
+}
+
+func TestListRecursively(t *testing.T) {
+	store := newTreeStorager(
+		"a/1", "a/b/", "a/b/1", "a/2", "a/c/", "a/c/d/", "a/c/d/1", "a/c/1", "a/e/",
+	)
+	// Objects in dirs should be sent before dirs, in the order of listing.
+	expected := []string{
+		"a/1", "a/b/1", "a/b/", "a/2", "a/c/d/1", "a/c/d/", "a/c/1", "a/c/", "a/e/",
+	}
+
+	for i := 0; i < 10; i++ {
+		pool, err := newWorkerPool(poolKindList, 4, AdaptiveOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		for v := range listRecursively(store, pool, "a/") {
+			if v.Error != nil {
+				t.Fatal(v.Error)
+			}
+			paths = append(paths, v.Object.Path)
+		}
+		pool.Release()
+		assert.Equal(t, expected, paths)
+	}
+}
+
+func TestListRecursivelyError(t *testing.T) {
+	store := newTreeStorager("a/1", "a/b/", "a/2")
+	delete(store.dirs, "a/b/")
+
+	pool, err := newWorkerPool(poolKindList, 1, AdaptiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Release()
+
+	var results []string
+	for v := range listRecursively(store, pool, "a/") {
+		if v.Error != nil {
+			results = append(results, "error")
+			continue
+		}
+		results = append(results, v.Object.Path)
+	}
+	assert.Equal(t, []string{"a/1", "error", "a/b/", "a/2"}, results)
+}
+
+func TestListRecursivelyNotCapped(t *testing.T) {
+	SetMaxWorkers(1)
+	defer SetMaxWorkers(0)
+
+	// Listing should not wait for slots of the global cap, which could be
+	// held by the consumer of the results.
+	workerSlots.acquire()
+	defer workerSlots.release()
+
+	pool, err := newWorkerPool(poolKindList, 1, AdaptiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Release()
+
+	store := newTreeStorager("a/1", "a/b/", "a/b/1")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range listRecursively(store, pool, "a/") {
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listing should not be blocked by the global cap")
+	}
+}
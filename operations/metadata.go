@@ -0,0 +1,147 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/types"
+)
+
+// sniffLen is the max number of bytes used to detect content type, which is
+// the same as http.DetectContentType.
+const sniffLen = 512
+
+// writeMetadata is the metadata set to objects while writing.
+type writeMetadata struct {
+	pairs []types.Pair
+	// detect is whether the content type should be detected if it's not set
+	// in pairs.
+	detect bool
+}
+
+// needContent reports whether the content of p is required to detect its
+// content type.
+func (m writeMetadata) needContent(p string) bool {
+	return m.detect && !hasPair(m.pairs, "content_type") && mime.TypeByExtension(path.Ext(p)) == ""
+}
+
+// pairsFor returns the metadata pairs of the object at p. head is the first
+// bytes of its content, which will be used to detect the content type if p
+// doesn't have a known extension.
+func (m writeMetadata) pairsFor(p string, head []byte) []types.Pair {
+	if !m.detect || hasPair(m.pairs, "content_type") {
+		return m.pairs
+	}
+
+	contentType := DetectContentType(p, head)
+	if contentType == "" {
+		return m.pairs
+	}
+
+	ps := make([]types.Pair, 0, len(m.pairs)+1)
+	ps = append(ps, m.pairs...)
+	return append(ps, pairs.WithContentType(contentType))
+}
+
+// DetectContentType returns the MIME type of the file at p by its extension.
+// If the extension is unknown, the type will be detected from head, the first
+// bytes of the content. Empty string will be returned if head is nil.
+func DetectContentType(p string, head []byte) string {
+	if contentType := mime.TypeByExtension(path.Ext(p)); contentType != "" {
+		return contentType
+	}
+	if head == nil {
+		return ""
+	}
+	return http.DetectContentType(head)
+}
+
+// peekContent reads the first bytes of r, and returns them with a reader that
+// will read the whole content again.
+func peekContent(r io.Reader) ([]byte, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+	head = head[:n]
+	return head, io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+func hasPair(ps []types.Pair, key string) bool {
+	for _, p := range ps {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// WithMetadataPairs sets the pairs of metadata like content type and storage
+// class, which will be used while writing or creating multipart objects.
+func (so *SingleOperator) WithMetadataPairs(ps ...types.Pair) *SingleOperator {
+	so.metadata.pairs = ps
+	return so
+}
+
+// WithContentTypeDetection sets whether the content type should be detected
+// from the path or content if it's not set via WithMetadataPairs.
+func (so *SingleOperator) WithContentTypeDetection(detect bool) *SingleOperator {
+	so.metadata.detect = detect
+	return so
+}
+
+// WithMetadataPairs sets the pairs of metadata like content type and storage
+// class, which will be used while writing or creating multipart objects.
+func (do *DualOperator) WithMetadataPairs(ps ...types.Pair) *DualOperator {
+	do.metadata.pairs = ps
+	return do
+}
+
+// WithContentTypeDetection sets whether the content type should be detected
+// from the path or content if it's not set via WithMetadataPairs.
+func (do *DualOperator) WithContentTypeDetection(detect bool) *DualOperator {
+	do.metadata.detect = detect
+	return do
+}
+
+// SetMetadata rewrites the metadata of path in place by copying it to itself
+// with ps.
+func (so *SingleOperator) SetMetadata(path string, ps ...types.Pair) error {
+	copier, ok := so.store.(types.Copier)
+	if !ok {
+		return fmt.Errorf("copier unimplement")
+	}
+	return copier.Copy(path, path, ps...)
+}
+
+// readHead reads the first bytes of src which is used to detect the content
+// type of dst with metadata m.
+func (do *DualOperator) readHead(m writeMetadata, src, dst string, size int64) []byte {
+	if !m.needContent(dst) {
+		return nil
+	}
+	if size > sniffLen {
+		size = sniffLen
+	}
+
+	ps := make([]types.Pair, 0, len(do.readPairs)+1)
+	ps = append(ps, pairs.WithSize(size))
+	ps = append(ps, do.readPairs...)
+
+	buf := new(bytes.Buffer)
+	_, err := do.src.Read(src, buf, ps...)
+	if err != nil {
+		// The content type will be detected from extension only.
+		do.logger.Error("read head", zap.String("path", src), zap.Error(err))
+		return nil
+	}
+	return buf.Bytes()
+}
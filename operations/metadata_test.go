@@ -0,0 +1,71 @@
+package operations
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/types"
+)
+
+func TestDetectContentType(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		head     []byte
+		expected string
+	}{
+		{"extension", "dir/index.html", nil, "text/html; charset=utf-8"},
+		{"extension over content", "a.json", []byte("<html>"), "application/json"},
+		{"content", "dir/file", []byte("<html><body>"), "text/html; charset=utf-8"},
+		{"binary content", "file", []byte{0x00, 0x01, 0x02}, "application/octet-stream"},
+		{"unknown", "file", nil, ""},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DetectContentType(tt.path, tt.head))
+		})
+	}
+}
+
+func TestWriteMetadataPairsFor(t *testing.T) {
+	class := types.Pair{Key: "s3_storage_class", Value: "STANDARD_IA"}
+
+	m := writeMetadata{pairs: []types.Pair{class}}
+	assert.Equal(t, []types.Pair{class}, m.pairsFor("a.html", nil))
+
+	m.detect = true
+	assert.False(t, m.needContent("a.html"))
+	assert.True(t, m.needContent("a"))
+	assert.Equal(t, []types.Pair{class, pairs.WithContentType("text/html; charset=utf-8")},
+		m.pairsFor("a.html", nil))
+	assert.Equal(t, []types.Pair{class}, m.pairsFor("a", nil))
+	// Pairs set by user should not be modified.
+	assert.Len(t, m.pairs, 1)
+
+	m.pairs = []types.Pair{pairs.WithContentType("image/png")}
+	assert.False(t, m.needContent("a"))
+	assert.Equal(t, m.pairs, m.pairsFor("a.html", nil))
+}
+
+func TestPeekContent(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), sniffLen+10)
+
+	head, r, err := peekContent(bytes.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, content[:sniffLen], head)
+	all, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, content, all)
+
+	head, r, err = peekContent(bytes.NewReader([]byte("short")))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("short"), head)
+	all, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("short"), all)
+}
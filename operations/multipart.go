@@ -1,15 +1,55 @@
 package operations
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
 
 	"go.beyondstorage.io/v5/types"
 )
 
 const (
 	defaultMultipartPartSize int64 = 128 * 1024 * 1024 // 128M
+	defaultPartConcurrency         = 4
 )
 
+// getPartSize returns the part size used to upload an object of totalSize.
+//
+// If partSize is zero, the part size will be calculated from totalSize and the
+// multipart restrictions of store. Otherwise, the user-input part size will be
+// validated against these restrictions.
+func getPartSize(store types.Storager, totalSize, partSize int64) (int64, error) {
+	if partSize == 0 {
+		return calculatePartSize(store, totalSize)
+	}
+
+	err := validatePartSize(store, totalSize, partSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// The whole object will be treated as the only part.
+	if totalSize > 0 && partSize > totalSize {
+		partSize = totalSize
+	}
+	return partSize, nil
+}
+
+// newPartPool creates a worker pool for the parts of a single multipart object.
+//
+// Every multipart object has its own part pool, so that the parts of a huge
+// object will not occupy the workers used to copy other files.
+func newPartPool(concurrency int, adaptive AdaptiveOptions) (*workerPool, error) {
+	if concurrency <= 0 {
+		concurrency = defaultPartConcurrency
+	}
+	return newWorkerPool(poolKindPart, concurrency, adaptive)
+}
+
 func calculatePartSize(store types.Storager, totalSize int64) (int64, error) {
 	maxNum, numOK := store.Metadata().GetMultipartNumberMaximum()
 	maxSize, maxOK := store.Metadata().GetMultipartSizeMaximum()
@@ -101,3 +141,103 @@ func validatePartSize(stor types.Storager, totalSize, partSize int64) error {
 	}
 	return nil
 }
+
+// writeParts splits r into parts and writes them into the multipart object mo.
+//
+// Every part will be read into a buffer fetched from buffers, and the buffer
+// will be passed to WriteMultipart directly. We will wait for a free buffer
+// before reading the next part, so that the reader will not run ahead of the
+// writers.
+//
+// The returned channel will be closed after all parts have been done.
+func writeParts(
+	multiparter types.Multiparter, mo *types.Object, r io.Reader,
+	partSize int64, partPool *workerPool, buffers *partBufferPool,
+	logger *zap.Logger, ps ...types.Pair,
+) (partch chan *PartResult) {
+	partch = make(chan *PartResult, 4)
+
+	go func() {
+		// Close partch to inform that all parts have been done.
+		defer close(partch)
+
+		wg := &sync.WaitGroup{}
+		var index int
+
+		for {
+			taskIndex := index
+
+			b := buffers.Get(partSize)
+
+			n, err := io.ReadFull(r, b)
+			if err == io.EOF {
+				buffers.Put(b)
+				break
+			}
+			// io.ErrUnexpectedEOF means this is the last part.
+			last := err == io.ErrUnexpectedEOF
+			if err != nil && !last {
+				buffers.Put(b)
+				partch <- &PartResult{Error: err}
+				break
+			}
+
+			wg.Add(1)
+			err = partPool.Submit(func() error {
+				defer wg.Done()
+				defer buffers.Put(b)
+
+				_, part, err := multiparter.WriteMultipart(mo, bytes.NewReader(b[:n]), int64(n), taskIndex, ps...)
+				if err != nil {
+					partch <- &PartResult{Error: err}
+					return err
+				}
+				partch <- &PartResult{Part: part}
+				return nil
+			})
+			if err != nil {
+				buffers.Put(b)
+				wg.Done()
+				logger.Error("submit task", zap.Error(err))
+				partch <- &PartResult{Error: err}
+				break
+			}
+
+			if last {
+				break
+			}
+			index++
+		}
+
+		wg.Wait()
+	}()
+
+	return partch
+}
+
+// completeParts collects all parts from partch and completes the multipart
+// object mo.
+//
+// The multipart object will not be completed if any part failed, and the first
+// error will be returned.
+func completeParts(multiparter types.Multiparter, mo *types.Object, partch chan *PartResult) (err error) {
+	parts := make([]*types.Part, 0)
+	for v := range partch {
+		if v.Error != nil {
+			if err == nil {
+				err = v.Error
+			}
+			continue
+		}
+		parts = append(parts, v.Part)
+	}
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(parts, func(i, j int) bool {
+		return parts[i].Index < parts[j].Index
+	})
+
+	return multiparter.CompleteMultipart(mo, parts)
+}
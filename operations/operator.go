@@ -3,44 +3,77 @@ package operations
 import (
 	"fmt"
 
-	"github.com/panjf2000/ants/v2"
 	"go.uber.org/zap"
 
 	"go.beyondstorage.io/v5/types"
 )
 
 type SingleOperator struct {
-	store  types.Storager
-	pool   *ants.Pool
-	logger *zap.Logger
+	store    types.Storager
+	pool     *workerPool
+	listPool *workerPool
+	logger   *zap.Logger
+
+	workers  int
+	adaptive AdaptiveOptions
+
+	partSize        int64
+	partConcurrency int
+	buffers         *partBufferPool
+	metadata        writeMetadata
 }
 
 func NewSingleOperator(store types.Storager) (oo *SingleOperator) {
-	pool, err := ants.NewPool(4)
-	if err != nil {
-		panic(fmt.Errorf("inti worker pool: %w", err))
-	}
-
 	// TODO: we will allow user config log level.
 	logger, err := zap.NewDevelopment()
 	if err != nil {
 		panic(fmt.Errorf("init logger: %w", err))
 	}
 
+	adaptive := defaultAdaptiveWorkers()
 	return &SingleOperator{
-		store:  store,
-		pool:   pool,
-		logger: logger,
+		store:           limitStorager(store),
+		pool:            mustNewWorkerPool(poolKindFile, defaultWorkers, adaptive),
+		listPool:        mustNewWorkerPool(poolKindList, defaultWorkers, adaptive),
+		logger:          logger,
+		workers:         defaultWorkers,
+		adaptive:        adaptive,
+		partConcurrency: defaultPartConcurrency,
+		buffers:         partBuffers,
 	}
 }
 
+// WithWorkers sets the number of files that will be handled concurrently,
+// which is the initial size if the pools are adaptive.
 func (so *SingleOperator) WithWorkers(workers int) *SingleOperator {
-	pool, err := ants.NewPool(workers)
-	if err != nil {
-		panic(fmt.Errorf("inti worker pool: %w", err))
-	}
+	so.workers = workers
+	so.pool.Release()
+	so.pool = mustNewWorkerPool(poolKindFile, workers, so.adaptive)
+	return so
+}
 
-	so.pool = pool
+// WithAdaptiveWorkers makes the pools of listing, files and parts adaptive,
+// their sizes will be tuned via observed throughput, latency and errors.
+func (so *SingleOperator) WithAdaptiveWorkers(adaptive AdaptiveOptions) *SingleOperator {
+	so.adaptive = adaptive
+	so.pool.Release()
+	so.pool = mustNewWorkerPool(poolKindFile, so.workers, adaptive)
+	so.listPool.Release()
+	so.listPool = mustNewWorkerPool(poolKindList, defaultWorkers, adaptive)
+	return so
+}
+
+// WithPartSize sets the part size used by multipart related operations.
+// Zero means the part size will be calculated automatically.
+func (so *SingleOperator) WithPartSize(size int64) *SingleOperator {
+	so.partSize = size
+	return so
+}
+
+// WithPartConcurrency sets the number of parts that will be uploaded
+// concurrently for every multipart object.
+func (so *SingleOperator) WithPartConcurrency(concurrency int) *SingleOperator {
+	so.partConcurrency = concurrency
 	return so
 }
 
@@ -49,37 +82,58 @@ type DualOperator struct {
 	dst        types.Storager
 	readPairs  []types.Pair
 	writePairs []types.Pair
-	pool       *ants.Pool
+	pool       *workerPool
+	listPool   *workerPool
 	logger     *zap.Logger
+
+	workers  int
+	adaptive AdaptiveOptions
+
+	partSize        int64
+	partConcurrency int
+	buffers         *partBufferPool
+	metadata        writeMetadata
+	preserve        PreserveOptions
 }
 
 func NewDualOperator(src, dst types.Storager) (do *DualOperator) {
-	pool, err := ants.NewPool(4)
-	if err != nil {
-		panic(fmt.Errorf("inti worker pool: %w", err))
-	}
-
 	// TODO: we will allow user config log level.
 	logger, err := zap.NewDevelopment()
 	if err != nil {
 		panic(fmt.Errorf("init logger: %w", err))
 	}
 
+	adaptive := defaultAdaptiveWorkers()
 	return &DualOperator{
-		src:    src,
-		dst:    dst,
-		pool:   pool,
-		logger: logger,
+		src:             limitStorager(src),
+		dst:             limitStorager(dst),
+		pool:            mustNewWorkerPool(poolKindFile, defaultWorkers, adaptive),
+		listPool:        mustNewWorkerPool(poolKindList, defaultWorkers, adaptive),
+		logger:          logger,
+		workers:         defaultWorkers,
+		adaptive:        adaptive,
+		partConcurrency: defaultPartConcurrency,
+		buffers:         partBuffers,
 	}
 }
 
+// WithWorkers sets the number of files that will be handled concurrently,
+// which is the initial size if the pools are adaptive.
 func (do *DualOperator) WithWorkers(workers int) *DualOperator {
-	pool, err := ants.NewPool(workers)
-	if err != nil {
-		panic(fmt.Errorf("inti worker pool: %w", err))
-	}
+	do.workers = workers
+	do.pool.Release()
+	do.pool = mustNewWorkerPool(poolKindFile, workers, do.adaptive)
+	return do
+}
 
-	do.pool = pool
+// WithAdaptiveWorkers makes the pools of listing, files and parts adaptive,
+// their sizes will be tuned via observed throughput, latency and errors.
+func (do *DualOperator) WithAdaptiveWorkers(adaptive AdaptiveOptions) *DualOperator {
+	do.adaptive = adaptive
+	do.pool.Release()
+	do.pool = mustNewWorkerPool(poolKindFile, do.workers, adaptive)
+	do.listPool.Release()
+	do.listPool = mustNewWorkerPool(poolKindList, defaultWorkers, adaptive)
 	return do
 }
 
@@ -92,3 +146,17 @@ func (do *DualOperator) WithWritePairs(ps ...types.Pair) *DualOperator {
 	do.writePairs = ps
 	return do
 }
+
+// WithPartSize sets the part size used by multipart related operations.
+// Zero means the part size will be calculated automatically.
+func (do *DualOperator) WithPartSize(size int64) *DualOperator {
+	do.partSize = size
+	return do
+}
+
+// WithPartConcurrency sets the number of parts that will be uploaded
+// concurrently for every multipart object.
+func (do *DualOperator) WithPartConcurrency(concurrency int) *DualOperator {
+	do.partConcurrency = concurrency
+	return do
+}
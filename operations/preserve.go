@@ -0,0 +1,130 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/types"
+)
+
+// PreserveOptions are attributes of source files that will be preserved while
+// copying.
+type PreserveOptions struct {
+	ContentType bool
+	// UserMetadataPair is the key of the service specific pair to write user
+	// metadata into dst, like `s3_user_metadata`. Empty means user metadata
+	// will not be preserved.
+	UserMetadataPair string
+	// Timestamps and Mode are applied to local files after writing, so they
+	// require DstDir. Mode also requires SrcDir to read the permission bits.
+	Timestamps bool
+	Mode       bool
+	// SrcDir and DstDir are the local work dirs of src and dst, which are
+	// empty if they are not local fs.
+	SrcDir string
+	DstDir string
+}
+
+// enabled reports whether any attribute should be preserved.
+func (p PreserveOptions) enabled() bool {
+	return p.ContentType || p.UserMetadataPair != "" || p.Timestamps || p.Mode
+}
+
+// pairs returns pairs that carry attributes of o into dst, pairs already in
+// ps will not be overridden.
+func (p PreserveOptions) pairs(o *types.Object, ps []types.Pair) []types.Pair {
+	if p.ContentType && !hasPair(ps, "content_type") {
+		if v, ok := o.GetContentType(); ok && v != "" {
+			ps = append(ps, pairs.WithContentType(v))
+		}
+	}
+	if p.UserMetadataPair != "" && !hasPair(ps, p.UserMetadataPair) {
+		if v, ok := o.GetUserMetadata(); ok && len(v) > 0 {
+			ps = append(ps, types.Pair{Key: p.UserMetadataPair, Value: v})
+		}
+	}
+	return ps
+}
+
+// apply applies timestamps and mode of src to the local file dst after it
+// has been written.
+func (p PreserveOptions) apply(o *types.Object, src, dst string) error {
+	if p.DstDir == "" {
+		return nil
+	}
+	dstPath := localPath(p.DstDir, dst)
+
+	if p.Mode && p.SrcDir != "" {
+		fi, err := os.Stat(localPath(p.SrcDir, src))
+		if err != nil {
+			return fmt.Errorf("preserve mode: %w", err)
+		}
+		err = os.Chmod(dstPath, fi.Mode().Perm())
+		if err != nil {
+			return fmt.Errorf("preserve mode: %w", err)
+		}
+	}
+
+	if p.Timestamps {
+		if t, ok := o.GetLastModified(); ok {
+			err := os.Chtimes(dstPath, t, t)
+			if err != nil {
+				return fmt.Errorf("preserve timestamps: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// localPath returns the local path of key in fs storager with work dir.
+func localPath(dir, key string) string {
+	if filepath.IsAbs(key) {
+		return key
+	}
+	return filepath.Join(dir, key)
+}
+
+// WithPreserve sets the attributes of source files that will be preserved
+// while copying.
+func (do *DualOperator) WithPreserve(p PreserveOptions) *DualOperator {
+	do.preserve = p
+	return do
+}
+
+// statPreserved stats src if any attribute should be preserved, nil will be
+// returned otherwise.
+func (do *DualOperator) statPreserved(src string) (*types.Object, error) {
+	if !do.preserve.enabled() {
+		return nil, nil
+	}
+	o, err := do.src.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s for preserving: %w", src, err)
+	}
+	return o, nil
+}
+
+// writeMetadata returns the metadata to write dst, attributes of o will be
+// preserved if it's not nil.
+func (do *DualOperator) writeMetadata(o *types.Object) writeMetadata {
+	m := do.metadata
+	if o == nil {
+		return m
+	}
+
+	ps := make([]types.Pair, 0, len(m.pairs)+2)
+	ps = append(ps, m.pairs...)
+	m.pairs = do.preserve.pairs(o, ps)
+	return m
+}
+
+// applyPreserved applies attributes of o which could only be set after dst
+// has been written.
+func (do *DualOperator) applyPreserved(o *types.Object, src, dst string) error {
+	if o == nil {
+		return nil
+	}
+	return do.preserve.apply(o, src, dst)
+}
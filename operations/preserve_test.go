@@ -0,0 +1,52 @@
+package operations
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreserveMode(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "byctl-preserve-src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(srcDir)
+	dstDir, err := ioutil.TempDir("", "byctl-preserve-dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dstDir)
+
+	err = ioutil.WriteFile(filepath.Join(srcDir, "a"), []byte("a"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dstDir, "b"), []byte("a"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := PreserveOptions{Mode: true, SrcDir: srcDir, DstDir: dstDir}
+	assert.True(t, p.enabled())
+	assert.NoError(t, p.apply(nil, "a", "b"))
+
+	fi, err := os.Stat(filepath.Join(dstDir, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+
+	// Nothing should be applied if dst is not local.
+	p.DstDir = ""
+	assert.NoError(t, p.apply(nil, "missing", "missing"))
+	assert.False(t, PreserveOptions{SrcDir: srcDir, DstDir: dstDir}.enabled())
+}
+
+func TestLocalPath(t *testing.T) {
+	assert.Equal(t, filepath.Join("/tmp/work", "dir/a"), localPath("/tmp/work/", "dir/a"))
+	assert.Equal(t, "/abs/a", localPath("/tmp/work/", "/abs/a"))
+}
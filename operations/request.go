@@ -0,0 +1,428 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"go.beyondstorage.io/v5/services"
+	"go.beyondstorage.io/v5/types"
+)
+
+const (
+	// maxThrottleRetries is the max retries of idempotent requests which
+	// are throttled by the storage.
+	maxThrottleRetries = 3
+	// throttleInterval is the min interval between two slowdowns, so that a
+	// burst of throttled requests running concurrently only slows down once.
+	throttleInterval = time.Second
+	// throttleRecoverInterval is the interval to speed up again after
+	// slowdown.
+	throttleRecoverInterval = 5 * time.Second
+	// minRequestRate is the min rate of slowdown in requests per second.
+	minRequestRate = 0.1
+)
+
+// requestLimiter limits requests to a storager via a token bucket. The rate
+// will be halved while the storage throttles requests, and recovers
+// gradually to the configured limit after that.
+type requestLimiter struct {
+	mu sync.Mutex
+	// max is the configured rate, zero means unlimited.
+	max float64
+	// current is the effective rate, zero means unlimited.
+	current float64
+	limiter *rate.Limiter
+
+	// ceiling is the rate while the storage starts throttling, the rate of
+	// unlimited storager will be unlimited again after recovering to it.
+	ceiling     float64
+	changedAt   time.Time
+	throttledAt time.Time
+
+	// Requests sent in the current window, which are used to estimate the
+	// rate of unlimited storager.
+	windowStart time.Time
+	windowCount int
+	lastRate    float64
+}
+
+// setMax sets the configured rate. Setting the same rate again is a no-op, so
+// that the slowdown caused by throttling will not be reset.
+func (l *requestLimiter) setMax(max float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if max == l.max {
+		return
+	}
+	l.max = max
+	l.ceiling = 0
+	l.setRate(time.Now(), max)
+}
+
+// setRate sets the effective rate, it should be called with lock held.
+func (l *requestLimiter) setRate(now time.Time, r float64) {
+	l.current = r
+	l.changedAt = now
+	if r <= 0 {
+		l.limiter = nil
+		return
+	}
+
+	burst := int(math.Ceil(r))
+	if l.limiter == nil {
+		l.limiter = rate.NewLimiter(rate.Limit(r), burst)
+		return
+	}
+	l.limiter.SetLimitAt(now, rate.Limit(r))
+	l.limiter.SetBurstAt(now, burst)
+}
+
+// reserve takes a request from the bucket at now, and returns the delay before
+// it could be sent.
+func (l *requestLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elapsed := now.Sub(l.windowStart); elapsed >= time.Second {
+		l.lastRate = float64(l.windowCount) / elapsed.Seconds()
+		l.windowStart = now
+		l.windowCount = 0
+	}
+	l.windowCount++
+
+	if l.limiter == nil {
+		return 0
+	}
+	return l.limiter.ReserveN(now, 1).DelayFrom(now)
+}
+
+// wait blocks until a request could be sent.
+func (l *requestLimiter) wait() {
+	time.Sleep(l.reserve(time.Now()))
+}
+
+// observe adjusts the rate via the result of a request finished at now.
+func (l *requestLimiter) observe(now time.Time, err error) {
+	throttled := errors.Is(err, services.ErrRequestThrottled)
+	if err != nil && !throttled {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if throttled {
+		if now.Sub(l.throttledAt) < throttleInterval {
+			return
+		}
+		l.throttledAt = now
+
+		base := l.current
+		if base <= 0 {
+			base = l.lastRate
+			if elapsed := now.Sub(l.windowStart); elapsed > 0 {
+				base = math.Max(base, float64(l.windowCount)/elapsed.Seconds())
+			}
+		}
+		if base > l.ceiling {
+			l.ceiling = base
+		}
+		l.setRate(now, math.Max(base/2, minRequestRate))
+		return
+	}
+
+	// Speed up by a quarter every interval without throttling.
+	if l.current <= 0 || l.current == l.max || now.Sub(l.changedAt) < throttleRecoverInterval {
+		return
+	}
+	next := l.current * 1.25
+	switch {
+	case l.max > 0 && next >= l.max:
+		next = l.max
+	case l.max <= 0 && next >= l.ceiling:
+		next = 0
+	}
+	l.setRate(now, next)
+}
+
+// requestLimiters are limiters of storagers in the process, so that all
+// operators of the same storager share the same limit.
+var requestLimiters = struct {
+	sync.Mutex
+	m map[types.Storager]*requestLimiter
+}{
+	m: make(map[types.Storager]*requestLimiter),
+}
+
+func requestLimiterOf(store types.Storager) *requestLimiter {
+	requestLimiters.Lock()
+	defer requestLimiters.Unlock()
+
+	l, ok := requestLimiters.m[store]
+	if !ok {
+		l = &requestLimiter{}
+		requestLimiters.m[store] = l
+	}
+	return l
+}
+
+// SetRequestLimit limits requests sent to store via all operators to rps
+// requests per second, zero means unlimited. It should be called before the
+// operators of store are created.
+//
+// Requests will be slowed down automatically while the storage throttles
+// them.
+func SetRequestLimit(store types.Storager, rps float64) {
+	requestLimiterOf(store).setMax(rps)
+}
+
+// limited returns whether a limit is configured.
+func (l *requestLimiter) limited() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.max > 0
+}
+
+// limitedStorager sends requests of the storager via the request limiter.
+// Listing pages fetched by iterators are not limited, because they are
+// fetched inside the storager.
+type limitedStorager struct {
+	types.Storager
+	limiter *requestLimiter
+}
+
+// limitStorager wraps store with its request limiter if a limit is configured
+// via SetRequestLimit. Only the optional interfaces used by operations,
+// Multiparter and Copier, are kept, others could be found via
+// unwrapStorager.
+func limitStorager(store types.Storager) types.Storager {
+	if store == nil || unwrapStorager(store) != store {
+		return store
+	}
+	limiter := requestLimiterOf(store)
+	if !limiter.limited() {
+		return store
+	}
+
+	ls := &limitedStorager{Storager: store, limiter: limiter}
+	m, isMultiparter := store.(types.Multiparter)
+	c, isCopier := store.(types.Copier)
+	switch {
+	case isMultiparter && isCopier:
+		return struct {
+			*limitedStorager
+			limitedMultiparter
+			limitedCopier
+		}{ls, limitedMultiparter{m, limiter}, limitedCopier{c, limiter}}
+	case isMultiparter:
+		return struct {
+			*limitedStorager
+			limitedMultiparter
+		}{ls, limitedMultiparter{m, limiter}}
+	case isCopier:
+		return struct {
+			*limitedStorager
+			limitedCopier
+		}{ls, limitedCopier{c, limiter}}
+	default:
+		return ls
+	}
+}
+
+// unwrapStorager returns the storager wrapped by limitStorager.
+func unwrapStorager(store types.Storager) types.Storager {
+	if u, ok := store.(interface{ unwrap() types.Storager }); ok {
+		return u.unwrap()
+	}
+	return store
+}
+
+func (s *limitedStorager) unwrap() types.Storager {
+	return s.Storager
+}
+
+// send sends a request via the limiter.
+func (l *requestLimiter) send(fn func() error) error {
+	l.wait()
+	err := fn()
+	l.observe(time.Now(), err)
+	return err
+}
+
+// sendIdempotent is the same as send, but retries throttled requests.
+func (l *requestLimiter) sendIdempotent(fn func() error) (err error) {
+	for i := 0; ; i++ {
+		err = l.send(fn)
+		if i >= maxThrottleRetries || !errors.Is(err, services.ErrRequestThrottled) {
+			return err
+		}
+	}
+}
+
+func (s *limitedStorager) Delete(path string, pairs ...types.Pair) error {
+	return s.limiter.sendIdempotent(func() error {
+		return s.Storager.Delete(path, pairs...)
+	})
+}
+
+func (s *limitedStorager) DeleteWithContext(ctx context.Context, path string, pairs ...types.Pair) error {
+	return s.limiter.sendIdempotent(func() error {
+		return s.Storager.DeleteWithContext(ctx, path, pairs...)
+	})
+}
+
+func (s *limitedStorager) List(path string, pairs ...types.Pair) (oi *types.ObjectIterator, err error) {
+	err = s.limiter.sendIdempotent(func() error {
+		oi, err = s.Storager.List(path, pairs...)
+		return err
+	})
+	return
+}
+
+func (s *limitedStorager) ListWithContext(ctx context.Context, path string, pairs ...types.Pair) (oi *types.ObjectIterator, err error) {
+	err = s.limiter.sendIdempotent(func() error {
+		oi, err = s.Storager.ListWithContext(ctx, path, pairs...)
+		return err
+	})
+	return
+}
+
+func (s *limitedStorager) Read(path string, w io.Writer, pairs ...types.Pair) (n int64, err error) {
+	err = s.limiter.send(func() error {
+		n, err = s.Storager.Read(path, w, pairs...)
+		return err
+	})
+	return
+}
+
+func (s *limitedStorager) ReadWithContext(ctx context.Context, path string, w io.Writer, pairs ...types.Pair) (n int64, err error) {
+	err = s.limiter.send(func() error {
+		n, err = s.Storager.ReadWithContext(ctx, path, w, pairs...)
+		return err
+	})
+	return
+}
+
+func (s *limitedStorager) Stat(path string, pairs ...types.Pair) (o *types.Object, err error) {
+	err = s.limiter.sendIdempotent(func() error {
+		o, err = s.Storager.Stat(path, pairs...)
+		return err
+	})
+	return
+}
+
+func (s *limitedStorager) StatWithContext(ctx context.Context, path string, pairs ...types.Pair) (o *types.Object, err error) {
+	err = s.limiter.sendIdempotent(func() error {
+		o, err = s.Storager.StatWithContext(ctx, path, pairs...)
+		return err
+	})
+	return
+}
+
+func (s *limitedStorager) Write(path string, r io.Reader, size int64, pairs ...types.Pair) (n int64, err error) {
+	err = s.limiter.send(func() error {
+		n, err = s.Storager.Write(path, r, size, pairs...)
+		return err
+	})
+	return
+}
+
+func (s *limitedStorager) WriteWithContext(ctx context.Context, path string, r io.Reader, size int64, pairs ...types.Pair) (n int64, err error) {
+	err = s.limiter.send(func() error {
+		n, err = s.Storager.WriteWithContext(ctx, path, r, size, pairs...)
+		return err
+	})
+	return
+}
+
+// limitedMultiparter sends multipart requests via the limiter.
+type limitedMultiparter struct {
+	types.Multiparter
+	limiter *requestLimiter
+}
+
+func (lm limitedMultiparter) CreateMultipart(path string, pairs ...types.Pair) (o *types.Object, err error) {
+	err = lm.limiter.send(func() error {
+		o, err = lm.Multiparter.CreateMultipart(path, pairs...)
+		return err
+	})
+	return
+}
+
+func (lm limitedMultiparter) CreateMultipartWithContext(ctx context.Context, path string, pairs ...types.Pair) (o *types.Object, err error) {
+	err = lm.limiter.send(func() error {
+		o, err = lm.Multiparter.CreateMultipartWithContext(ctx, path, pairs...)
+		return err
+	})
+	return
+}
+
+func (lm limitedMultiparter) WriteMultipart(o *types.Object, r io.Reader, size int64, index int, pairs ...types.Pair) (n int64, part *types.Part, err error) {
+	err = lm.limiter.send(func() error {
+		n, part, err = lm.Multiparter.WriteMultipart(o, r, size, index, pairs...)
+		return err
+	})
+	return
+}
+
+func (lm limitedMultiparter) WriteMultipartWithContext(ctx context.Context, o *types.Object, r io.Reader, size int64, index int, pairs ...types.Pair) (n int64, part *types.Part, err error) {
+	err = lm.limiter.send(func() error {
+		n, part, err = lm.Multiparter.WriteMultipartWithContext(ctx, o, r, size, index, pairs...)
+		return err
+	})
+	return
+}
+
+func (lm limitedMultiparter) ListMultipart(o *types.Object, pairs ...types.Pair) (pi *types.PartIterator, err error) {
+	err = lm.limiter.sendIdempotent(func() error {
+		pi, err = lm.Multiparter.ListMultipart(o, pairs...)
+		return err
+	})
+	return
+}
+
+func (lm limitedMultiparter) ListMultipartWithContext(ctx context.Context, o *types.Object, pairs ...types.Pair) (pi *types.PartIterator, err error) {
+	err = lm.limiter.sendIdempotent(func() error {
+		pi, err = lm.Multiparter.ListMultipartWithContext(ctx, o, pairs...)
+		return err
+	})
+	return
+}
+
+func (lm limitedMultiparter) CompleteMultipart(o *types.Object, parts []*types.Part, pairs ...types.Pair) error {
+	return lm.limiter.send(func() error {
+		return lm.Multiparter.CompleteMultipart(o, parts, pairs...)
+	})
+}
+
+func (lm limitedMultiparter) CompleteMultipartWithContext(ctx context.Context, o *types.Object, parts []*types.Part, pairs ...types.Pair) error {
+	return lm.limiter.send(func() error {
+		return lm.Multiparter.CompleteMultipartWithContext(ctx, o, parts, pairs...)
+	})
+}
+
+// limitedCopier sends copy requests via the limiter.
+type limitedCopier struct {
+	types.Copier
+	limiter *requestLimiter
+}
+
+func (lc limitedCopier) Copy(src string, dst string, pairs ...types.Pair) error {
+	return lc.limiter.sendIdempotent(func() error {
+		return lc.Copier.Copy(src, dst, pairs...)
+	})
+}
+
+func (lc limitedCopier) CopyWithContext(ctx context.Context, src string, dst string, pairs ...types.Pair) error {
+	return lc.limiter.sendIdempotent(func() error {
+		return lc.Copier.CopyWithContext(ctx, src, dst, pairs...)
+	})
+}
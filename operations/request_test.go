@@ -0,0 +1,176 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.beyondstorage.io/v5/services"
+	"go.beyondstorage.io/v5/types"
+)
+
+var errThrottled = fmt.Errorf("stat: %w", services.ErrRequestThrottled)
+
+func TestRequestLimiterSlowdown(t *testing.T) {
+	l := &requestLimiter{}
+	l.setMax(10)
+
+	now := time.Now().Add(throttleInterval)
+	l.observe(now, errThrottled)
+	assert.Equal(t, float64(5), l.current)
+
+	// Throttled requests running concurrently only slow down once.
+	l.observe(now, errThrottled)
+	assert.Equal(t, float64(5), l.current)
+
+	now = now.Add(throttleInterval)
+	l.observe(now, errThrottled)
+	assert.Equal(t, 2.5, l.current)
+
+	// Other errors are ignored, and it doesn't recover too fast.
+	l.observe(now.Add(time.Second), assert.AnError)
+	l.observe(now.Add(time.Second), nil)
+	assert.Equal(t, 2.5, l.current)
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(throttleRecoverInterval)
+		l.observe(now, nil)
+	}
+	assert.Equal(t, float64(10), l.current)
+	assert.Equal(t, float64(10), float64(l.limiter.Limit()))
+}
+
+func TestRequestLimiterUnlimited(t *testing.T) {
+	l := &requestLimiter{}
+
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, time.Duration(0), l.reserve(now))
+	}
+
+	// The rate is estimated from requests sent, and halved.
+	now = now.Add(500 * time.Millisecond)
+	l.observe(now, errThrottled)
+	assert.Equal(t, float64(20), l.current)
+
+	// Unlimited again after recovering to the rate before throttling.
+	for i := 0; i < 10; i++ {
+		now = now.Add(throttleRecoverInterval)
+		l.observe(now, nil)
+	}
+	assert.Equal(t, float64(0), l.current)
+	assert.Nil(t, l.limiter)
+}
+
+type throttledStorager struct {
+	types.Storager
+	throttles int
+	stats     int
+}
+
+func (s *throttledStorager) Stat(path string, pairs ...types.Pair) (*types.Object, error) {
+	s.stats++
+	if s.stats <= s.throttles {
+		return nil, errThrottled
+	}
+	return &types.Object{Path: path}, nil
+}
+
+type throttledMultiparter struct {
+	*throttledStorager
+	types.Multiparter
+	lists int
+}
+
+func (s *throttledMultiparter) ListMultipartWithContext(ctx context.Context, o *types.Object, pairs ...types.Pair) (*types.PartIterator, error) {
+	s.lists++
+	if s.lists <= s.throttles {
+		return nil, errThrottled
+	}
+	return &types.PartIterator{}, nil
+}
+
+// fullStorager implements all optional interfaces.
+type fullStorager struct {
+	*throttledStorager
+	types.Multiparter
+	types.Copier
+	types.Mover
+	types.Appender
+	types.Direr
+	types.Linker
+	types.Fetcher
+	types.StorageHTTPSigner
+	types.MultipartHTTPSigner
+}
+
+func TestLimitStorager(t *testing.T) {
+	// Storager without limit should not be wrapped.
+	raw := &throttledStorager{throttles: 2}
+	assert.Equal(t, types.Storager(raw), limitStorager(raw))
+
+	SetRequestLimit(raw, 1000)
+	store := limitStorager(raw)
+
+	// Throttled idempotent requests will be retried.
+	o, err := store.Stat("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", o.Path)
+	assert.Equal(t, 3, raw.stats)
+
+	raw.throttles, raw.stats = maxThrottleRetries+1, 0
+	_, err = store.Stat("a")
+	assert.ErrorIs(t, err, services.ErrRequestThrottled)
+	assert.Equal(t, maxThrottleRetries+1, raw.stats)
+
+	assert.Equal(t, types.Storager(raw), unwrapStorager(store))
+	assert.Equal(t, store, limitStorager(store))
+	_, ok := store.(types.Multiparter)
+	assert.False(t, ok)
+
+	// Multiparter should be kept, and requests with context are limited too.
+	rawMultiparter := &throttledMultiparter{throttledStorager: &throttledStorager{throttles: 2}}
+	SetRequestLimit(rawMultiparter, 1000)
+	m, ok := limitStorager(rawMultiparter).(types.Multiparter)
+	if assert.True(t, ok) {
+		_, err = m.ListMultipartWithContext(context.Background(), &types.Object{})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, rawMultiparter.lists)
+	}
+}
+
+func TestLimitStoragerOptional(t *testing.T) {
+	raw := &fullStorager{throttledStorager: &throttledStorager{}}
+	SetRequestLimit(raw, 1000)
+	store := limitStorager(raw)
+
+	_, ok := store.(types.Multiparter)
+	assert.True(t, ok)
+	_, ok = store.(types.Copier)
+	assert.True(t, ok)
+	_, ok = store.(types.Mover)
+	assert.False(t, ok)
+
+	// Other interfaces could be found via the unwrapped storager.
+	so := NewSingleOperator(raw)
+	assert.Equal(t, types.Storager(raw), unwrapStorager(so.store))
+	for _, c := range so.Capabilities() {
+		assert.True(t, c.Supported, c.Name)
+	}
+}
+
+func TestRequestLimiterSetSameMax(t *testing.T) {
+	l := &requestLimiter{}
+	l.setMax(10)
+	l.observe(time.Now().Add(throttleInterval), errThrottled)
+	assert.Equal(t, float64(5), l.current)
+
+	// Applying the same limit again should not reset the slowdown.
+	l.setMax(10)
+	assert.Equal(t, float64(5), l.current)
+	l.setMax(20)
+	assert.Equal(t, float64(20), l.current)
+}
@@ -2,18 +2,24 @@ package operations
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/v5/pairs"
 	"go.beyondstorage.io/v5/types"
 )
 
-func (so *SingleOperator) Sign(path string, expire time.Duration) (url string, err error) {
-	signer, ok := so.store.(types.StorageHTTPSigner)
-	if !ok {
-		return "", fmt.Errorf("storage http signer unimplement")
-	}
+// Methods of signed requests.
+const (
+	SignMethodRead   = "read"
+	SignMethodWrite  = "write"
+	SignMethodDelete = "delete"
+)
 
-	req, err := signer.QuerySignHTTPRead(path, expire)
+func (so *SingleOperator) Sign(path string, expire time.Duration) (url string, err error) {
+	req, err := so.SignHTTP(SignMethodRead, path, 0, expire)
 	if err != nil {
 		return "", err
 	}
@@ -22,3 +28,99 @@ func (so *SingleOperator) Sign(path string, expire time.Duration) (url string, e
 
 	return
 }
+
+// SignHTTP returns the signed request of path for method, size is only used
+// by write.
+func (so *SingleOperator) SignHTTP(method, path string, size int64, expire time.Duration) (req *http.Request, err error) {
+	signer, ok := unwrapStorager(so.store).(types.StorageHTTPSigner)
+	if !ok {
+		return nil, fmt.Errorf("storage http signer unimplement")
+	}
+
+	switch method {
+	case SignMethodRead:
+		return signer.QuerySignHTTPRead(path, expire)
+	case SignMethodWrite:
+		return signer.QuerySignHTTPWrite(path, size, expire)
+	case SignMethodDelete:
+		return signer.QuerySignHTTPDelete(path, expire)
+	}
+	return nil, fmt.Errorf("sign method %s is not supported", method)
+}
+
+// SignedMultipart is the signed requests to upload a multipart object.
+type SignedMultipart struct {
+	MultipartID string
+	Parts       []*SignedPart
+	// Complete is the request to complete the multipart object, the parts in
+	// its body should be replaced by the real uploaded parts for services
+	// that need etags of parts.
+	Complete *http.Request
+}
+
+// SignedPart is the signed request to write a part.
+type SignedPart struct {
+	Index int
+	// Offset is the offset of the part in the whole object.
+	Offset  int64
+	Size    int64
+	Request *http.Request
+}
+
+// SignMultipart creates a multipart object and signs the requests to write
+// every part of it. The part size will be calculated if it's not set via
+// WithPartSize.
+func (so *SingleOperator) SignMultipart(path string, size int64, expire time.Duration) (sm *SignedMultipart, err error) {
+	multiparter, ok := so.store.(types.Multiparter)
+	if !ok {
+		return nil, fmt.Errorf("multiparter unimplement")
+	}
+	signer, ok := unwrapStorager(so.store).(types.MultipartHTTPSigner)
+	if !ok {
+		return nil, fmt.Errorf("multipart http signer unimplement")
+	}
+
+	partSize, err := getPartSize(so.store, size, so.partSize)
+	if err != nil {
+		return nil, err
+	}
+
+	mo, err := multiparter.CreateMultipart(path)
+	if err != nil {
+		return nil, err
+	}
+	sm = &SignedMultipart{MultipartID: mo.MustGetMultipartID()}
+
+	parts := make([]*types.Part, 0)
+	for index, offset := 0, int64(0); offset < size || index == 0; index++ {
+		n := partSize
+		if size-offset < n {
+			n = size - offset
+		}
+
+		req, err := signer.QuerySignHTTPWriteMultipart(mo, n, index, expire)
+		if err != nil {
+			so.abortMultipart(path, sm.MultipartID)
+			return nil, err
+		}
+		sm.Parts = append(sm.Parts, &SignedPart{Index: index, Offset: offset, Size: n, Request: req})
+		parts = append(parts, &types.Part{Index: index, Size: n})
+
+		offset += n
+	}
+
+	sm.Complete, err = signer.QuerySignHTTPCompleteMultipart(mo, parts, expire)
+	if err != nil {
+		so.abortMultipart(path, sm.MultipartID)
+		return nil, err
+	}
+	return sm, nil
+}
+
+// abortMultipart deletes the multipart object created while signing failed.
+func (so *SingleOperator) abortMultipart(path, multipartID string) {
+	err := so.Delete(path, pairs.WithMultipartID(multipartID))
+	if err != nil {
+		so.logger.Error("abort multipart", zap.String("path", path), zap.Error(err))
+	}
+}
@@ -2,6 +2,7 @@ package operations
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"go.beyondstorage.io/v5/pairs"
@@ -43,7 +44,87 @@ func (so *SingleOperator) Stat(path string) (o *types.Object, err error) {
 	return o, nil
 }
 
+// StatMany stats paths concurrently via the worker pool. Results will be sent
+// in the same order as paths, and errors will contain the path.
+func (so *SingleOperator) StatMany(paths []string) (ch chan *ObjectResult) {
+	ch = make(chan *ObjectResult, 16)
+
+	// Every path has its own result channel, so that results could be sent
+	// in order while stats are running concurrently.
+	results := make([]chan *ObjectResult, len(paths))
+	for i := range results {
+		results[i] = make(chan *ObjectResult, 1)
+	}
+
+	go func() {
+		for i, p := range paths {
+			// Reallocate var here to prevent closure catch.
+			path, result := p, results[i]
+
+			err := so.pool.Submit(func() error {
+				o, err := so.Stat(path)
+				if err != nil {
+					result <- &ObjectResult{Error: fmt.Errorf("stat %s: %w", path, err)}
+					return err
+				}
+				result <- &ObjectResult{Object: o}
+				return nil
+			})
+			if err != nil {
+				result <- &ObjectResult{Error: fmt.Errorf("submit stat %s: %w", path, err)}
+			}
+		}
+	}()
+
+	go func() {
+		defer close(ch)
+
+		for _, result := range results {
+			ch <- <-result
+		}
+	}()
+
+	return ch
+}
+
 func (so SingleOperator) StatStorager() (meta *types.StorageMeta) {
 	meta = so.store.Metadata()
 	return meta
 }
+
+// Capability is an optional interface of go-storage and whether the storager
+// implements it.
+type Capability struct {
+	Name      string
+	Supported bool
+}
+
+// Capabilities returns the optional interfaces that storager could implement.
+func (so SingleOperator) Capabilities() []Capability {
+	s := unwrapStorager(so.store)
+	check := func(name string, ok bool) Capability {
+		return Capability{Name: name, Supported: ok}
+	}
+
+	_, multiparter := s.(types.Multiparter)
+	_, appender := s.(types.Appender)
+	_, copier := s.(types.Copier)
+	_, mover := s.(types.Mover)
+	_, direr := s.(types.Direr)
+	_, linker := s.(types.Linker)
+	_, fetcher := s.(types.Fetcher)
+	_, storageSigner := s.(types.StorageHTTPSigner)
+	_, multipartSigner := s.(types.MultipartHTTPSigner)
+
+	return []Capability{
+		check("Multiparter", multiparter),
+		check("Appender", appender),
+		check("Copier", copier),
+		check("Mover", mover),
+		check("Direr", direr),
+		check("Linker", linker),
+		check("Fetcher", fetcher),
+		check("StorageHTTPSigner", storageSigner),
+		check("MultipartHTTPSigner", multipartSigner),
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"regexp"
-	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,13 +17,11 @@ import (
 func (do *DualOperator) SyncDir(src, dst string, opts SyncOptions) (errch chan *EmptyResult, err error) {
 	errch = make(chan *EmptyResult, 4)
 
-	so := NewSingleOperator(do.src)
-
 	var ch chan *ObjectResult
 	if opts.Recursive {
-		ch, err = so.ListRecursively(src)
+		ch = listRecursively(do.src, do.listPool, src)
 	} else {
-		ch, err = so.List(src)
+		ch, err = NewSingleOperator(do.src).List(src)
 	}
 	if err != nil {
 		return nil, err
@@ -87,43 +84,84 @@ func (do *DualOperator) SyncDir(src, dst string, opts SyncOptions) (errch chan *
 
 			wg.Add(1)
 
-			err = do.pool.Submit(func() {
+			err = do.pool.Submit(func() error {
 				defer wg.Done()
 
-				var buf bytes.Buffer
-				_, err = do.src.Read(o.Path, &buf)
-				if err != nil {
-					errch <- &EmptyResult{Error: err}
-					return
-				}
-
 				path := dst + objRelPath
 				if opts.IsArgs {
 					path = dst + o.Path
 				}
 
-				size := int64(buf.Len())
-				if size > opts.MultipartThreshold {
-					mch, err := do.writeFileViaMultipart(&buf, path, size)
+				var srcObj *types.Object
+				if !o.Mode.IsDir() {
+					var err error
+					srcObj, err = do.statPreserved(o.Path)
+					if err != nil {
+						errch <- &EmptyResult{Error: err}
+						return err
+					}
+				}
+				meta := do.writeMetadata(srcObj)
+
+				// Large files will be streamed into multipart object, so that
+				// we don't need to hold the whole file in memory.
+				if size, ok := o.GetContentLength(); ok && size > opts.MultipartThreshold {
+					r, w := io.Pipe()
+					// Close the reader to unblock the read side if write failed.
+					defer r.Close()
+
+					go func() {
+						_, err := do.src.Read(o.Path, w, do.readPairs...)
+						w.CloseWithError(err)
+					}()
+
+					// Parts are written by the part pool, don't occupy the
+					// cap of workers while waiting for them.
+					var mch chan *EmptyResult
+					var err error
+					yieldWorker(func() {
+						mch, err = do.writeFileViaMultipart(r, path, size, meta)
+					})
 					if err != nil {
 						errch <- &EmptyResult{Error: err}
-						return
+						return err
 					}
 
 					for value := range mch {
 						if value.Error != nil {
 							errch <- &EmptyResult{Error: value.Error}
-							return
+							return value.Error
 						}
 					}
 				} else {
-					_, err = do.dst.Write(path, &buf, size)
+					var buf bytes.Buffer
+					_, err := do.src.Read(o.Path, &buf, do.readPairs...)
+					if err != nil {
+						errch <- &EmptyResult{Error: err}
+						return err
+					}
+
+					head := buf.Bytes()
+					if len(head) > sniffLen {
+						head = head[:sniffLen]
+					}
+					ps := make([]types.Pair, 0, len(do.writePairs)+len(meta.pairs)+1)
+					ps = append(ps, do.writePairs...)
+					ps = append(ps, meta.pairsFor(path, head)...)
+
+					_, err = do.dst.Write(path, &buf, int64(buf.Len()), ps...)
 					if err != nil {
 						errch <- &EmptyResult{Error: err}
-						return
+						return err
 					}
 				}
 
+				err := do.applyPreserved(srcObj, o.Path, path)
+				if err != nil {
+					errch <- &EmptyResult{Error: err}
+					return err
+				}
+
 				if !o.Mode.IsDir() {
 					if opts.IsArgs {
 						fmt.Printf("<%s> synced.\n", o.Path)
@@ -131,8 +169,10 @@ func (do *DualOperator) SyncDir(src, dst string, opts SyncOptions) (errch chan *
 						fmt.Printf("<%s> synced.\n", objRelPath)
 					}
 				}
+				return nil
 			})
 			if err != nil {
+				wg.Done()
 				do.logger.Error("submit task", zap.Error(err))
 				break
 			}
@@ -156,96 +196,52 @@ func (do *DualOperator) SyncDir(src, dst string, opts SyncOptions) (errch chan *
 	return
 }
 
-func (do *DualOperator) writeFileViaMultipart(r io.Reader, path string, size int64) (errch chan *EmptyResult, err error) {
+func (do *DualOperator) writeFileViaMultipart(
+	r io.Reader, path string, size int64, meta writeMetadata,
+) (errch chan *EmptyResult, err error) {
 	errch = make(chan *EmptyResult, 4)
-	partch := make(chan *PartResult, 4)
 
 	multiparter, ok := do.dst.(types.Multiparter)
 	if !ok {
 		return nil, fmt.Errorf("multiparter")
 	}
 
-	mo, err := multiparter.CreateMultipart(path)
+	partSize, err := getPartSize(do.dst, size, do.partSize)
 	if err != nil {
 		return nil, err
 	}
 
-	partSize, err := calculatePartSize(do.dst, size)
+	partPool, err := newPartPool(do.partConcurrency, do.adaptive)
 	if err != nil {
 		return nil, err
 	}
 
-	go func() {
-		// Close partch to inform that all parts have been done.
-		defer close(partch)
-
-		wg := &sync.WaitGroup{}
-		var index int
-
-		b := make([]byte, partSize)
-
-		for {
-			wg.Add(1)
-
-			taskIndex := index
-
-			n, err := io.ReadFull(r, b)
-			if err == io.EOF {
-				wg.Done()
-				break
-			}
-			if err != nil && n != 0 {
-				err = nil
-			}
-			if err != nil {
-				partch <- &PartResult{Error: err}
-				return
-			}
-
-			rd := bytes.NewReader(b[:n])
-
-			err = do.pool.Submit(func() {
-				defer wg.Done()
-
-				_, part, err := multiparter.WriteMultipart(mo, rd, rd.Size(), taskIndex)
-				if err != nil {
-					partch <- &PartResult{Error: err}
-					return
-				}
-				partch <- &PartResult{Part: part}
-			})
-			if err != nil {
-				do.logger.Error("submit task", zap.Error(err))
-				return
-			}
-
-			index++
+	var head []byte
+	if meta.needContent(path) {
+		head, r, err = peekContent(r)
+		if err != nil {
+			partPool.Release()
+			return nil, err
 		}
+	}
 
-		wg.Wait()
-	}()
+	mo, err := multiparter.CreateMultipart(path, meta.pairsFor(path, head)...)
+	if err != nil {
+		partPool.Release()
+		return nil, err
+	}
 
+	defer partPool.Release()
 	defer close(errch)
 
-	parts := make([]*types.Part, 0)
-	for v := range partch {
-		if v.Error != nil {
-			errch <- &EmptyResult{Error: v.Error}
-			continue
-		}
-		parts = append(parts, v.Part)
-	}
+	partch := writeParts(multiparter, mo, r, partSize, partPool, do.buffers, do.logger, do.writePairs...)
 
-	sort.SliceStable(parts, func(i, j int) bool {
-		return parts[i].Index < parts[j].Index
-	})
-
-	err = multiparter.CompleteMultipart(mo, parts)
+	err = completeParts(multiparter, mo, partch)
 	if err != nil {
-		return nil, err
+		errch <- &EmptyResult{Error: err}
 	}
 
-	return
+	return errch, nil
 }
 
 func getFilesName(path string, store types.Storager, recursive bool) (files map[string]time.Time, err error) {
@@ -0,0 +1,90 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/types"
+)
+
+// objectReader is an io.ReadSeeker of object, so that it could be served via
+// http.ServeContent.
+//
+// Content will be streamed from the current offset by a ranged Read which
+// will be started lazily, so seeking doesn't cost any request.
+type objectReader struct {
+	ctx    context.Context
+	store  types.Storager
+	path   string
+	size   int64
+	offset int64
+
+	r *io.PipeReader
+}
+
+func newObjectReader(ctx context.Context, store types.Storager, path string, size int64) *objectReader {
+	return &objectReader{
+		ctx:   ctx,
+		store: store,
+		path:  path,
+		size:  size,
+	}
+}
+
+// Read implements io.Reader.
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.r == nil {
+		pr, pw := io.Pipe()
+		offset, size := r.offset, r.size-r.offset
+		go func() {
+			_, err := r.store.ReadWithContext(r.ctx, r.path, pw,
+				pairs.WithOffset(offset), pairs.WithSize(size))
+			pw.CloseWithError(err)
+		}()
+		r.r = pr
+	}
+
+	n, err := r.r.Read(p)
+	r.offset += int64(n)
+	if errors.Is(err, io.EOF) && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("seek: negative position")
+	}
+
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// Close aborts the Read in progress.
+func (r *objectReader) Close() error {
+	if r.r != nil {
+		_ = r.r.Close()
+		r.r = nil
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package serve
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/v5/services"
+	"go.beyondstorage.io/v5/types"
+)
+
+const s3XMLNS = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// S3Options are options of the S3 handler.
+type S3Options struct {
+	// Bucket is the name of the only bucket served.
+	Bucket string
+	// AccessKey and SecretKey are the static credential that requests must be
+	// signed with.
+	AccessKey string
+	SecretKey string
+	// Region is returned by GetBucketLocation.
+	Region string
+	// MultipartThreshold is the size that objects larger than it will be
+	// written via multipart in PutObject.
+	MultipartThreshold int64
+}
+
+// S3Handler serves objects under root in storager via S3 compatible API.
+//
+// Only path-style requests like `/bucket/key` are supported, and the storager
+// is exposed as the only bucket.
+type S3Handler struct {
+	store types.Storager
+	so    *operations.SingleOperator
+	// root is the key prefix of the served dir, which is empty or ends with
+	// `/`.
+	root   string
+	opts   S3Options
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// NewS3 creates a S3 handler, so should operate on store.
+func NewS3(store types.Storager, so *operations.SingleOperator, root string, opts S3Options) *S3Handler {
+	if root != "" && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+
+	// TODO: we will allow user config log level.
+	logger, _ := zap.NewDevelopment()
+
+	return &S3Handler{
+		store:  store,
+		so:     so,
+		root:   root,
+		opts:   opts,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// s3Error is an error response of S3 API.
+type s3Error struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+func (e *s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var (
+	errAccessDenied = &s3Error{
+		"AccessDenied", "Access Denied.", http.StatusForbidden}
+	errAuthorizationHeaderMalformed = &s3Error{
+		"AuthorizationHeaderMalformed", "The authorization header is malformed.", http.StatusBadRequest}
+	errAuthorizationQueryParametersError = &s3Error{
+		"AuthorizationQueryParametersError", "The query parameters for authorization are malformed.", http.StatusBadRequest}
+	errSignatureVersionNotSupported = &s3Error{
+		"InvalidRequest", "Only AWS Signature Version 4 is supported.", http.StatusBadRequest}
+	errInvalidAccessKeyID = &s3Error{
+		"InvalidAccessKeyId", "The access key ID you provided does not exist in our records.", http.StatusForbidden}
+	errSignatureDoesNotMatch = &s3Error{
+		"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+	errRequestTimeTooSkewed = &s3Error{
+		"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	errRequestNotReadyYet = &s3Error{
+		"AccessDenied", "Request is not valid yet.", http.StatusForbidden}
+	errExpiredToken = &s3Error{
+		"AccessDenied", "Request has expired.", http.StatusForbidden}
+	errXAmzContentSHA256Mismatch = &s3Error{
+		"XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 header does not match what was computed.", http.StatusBadRequest}
+	errNoSuchBucket = &s3Error{
+		"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	errNoSuchKey = &s3Error{
+		"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	errNoSuchUpload = &s3Error{
+		"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+	errInvalidPart = &s3Error{
+		"InvalidPart", "One or more of the specified parts could not be found.", http.StatusBadRequest}
+	errInvalidArgument = &s3Error{
+		"InvalidArgument", "Invalid argument.", http.StatusBadRequest}
+	errMalformedXML = &s3Error{
+		"MalformedXML", "The XML you provided was not well-formed.", http.StatusBadRequest}
+	errMissingContentLength = &s3Error{
+		"MissingContentLength", "You must provide the Content-Length HTTP header.", http.StatusLengthRequired}
+	errNotImplemented = &s3Error{
+		"NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	errMethodNotAllowed = &s3Error{
+		"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	errInternalError = &s3Error{
+		"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+)
+
+type s3ErrorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string
+	Message  string
+	Resource string
+}
+
+// ServeHTTP implements http.Handler.
+func (h *S3Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sig, serr := h.authenticate(r)
+	if serr != nil {
+		h.writeError(w, r, serr)
+		return
+	}
+
+	bucket, key := splitBucketKey(r.URL.Path)
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			h.writeError(w, r, errMethodNotAllowed)
+			return
+		}
+		h.listBuckets(w, r)
+		return
+	}
+	if bucket != h.opts.Bucket {
+		h.writeError(w, r, errNoSuchBucket)
+		return
+	}
+
+	if !validKey(key) {
+		h.writeError(w, r, errInvalidArgument)
+		return
+	}
+	if key == "" {
+		h.serveBucket(w, r, sig)
+		return
+	}
+	h.serveObject(w, r, sig, h.root+key)
+}
+
+// splitBucketKey splits path like `/bucket/key` into bucket and key.
+func splitBucketKey(p string) (bucket, key string) {
+	p = strings.TrimPrefix(p, "/")
+	idx := strings.Index(p, "/")
+	if idx == -1 {
+		return p, ""
+	}
+	return p[:idx], p[idx+1:]
+}
+
+// validKey reports whether key could be mapped into the served dir. Keys with
+// `..` segments are rejected, so that they could not escape from the dir in
+// storagers like fs.
+func validKey(key string) bool {
+	for _, seg := range strings.Split(key, "/") {
+		if seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *S3Handler) serveBucket(w http.ResponseWriter, r *http.Request, sig *sigV4) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		switch {
+		case hasQuery(query, "location"):
+			h.writeXML(w, r, http.StatusOK, struct {
+				XMLName xml.Name `xml:"LocationConstraint"`
+				XMLNS   string   `xml:"xmlns,attr"`
+				Region  string   `xml:",chardata"`
+			}{XMLNS: s3XMLNS, Region: h.opts.Region})
+		case query.Get("list-type") == "2":
+			h.listObjectsV2(w, r)
+		default:
+			h.writeError(w, r, errNotImplemented)
+		}
+	case http.MethodPost:
+		if hasQuery(query, "delete") {
+			h.deleteObjects(w, r, sig)
+			return
+		}
+		h.writeError(w, r, errNotImplemented)
+	default:
+		h.writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+func (h *S3Handler) serveObject(w http.ResponseWriter, r *http.Request, sig *sigV4, key string) {
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if uploadID != "" {
+			h.writeError(w, r, errNotImplemented)
+			return
+		}
+		h.getObject(w, r, key)
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			h.writeError(w, r, errNotImplemented)
+			return
+		}
+		if uploadID != "" {
+			h.uploadPart(w, r, sig, key, uploadID)
+			return
+		}
+		h.putObject(w, r, sig, key)
+	case http.MethodPost:
+		switch {
+		case hasQuery(query, "uploads"):
+			h.createMultipartUpload(w, r, key)
+		case uploadID != "":
+			h.completeMultipartUpload(w, r, sig, key, uploadID)
+		default:
+			h.writeError(w, r, errNotImplemented)
+		}
+	case http.MethodDelete:
+		if uploadID != "" {
+			h.abortMultipartUpload(w, r, key, uploadID)
+			return
+		}
+		h.deleteObject(w, r, key)
+	default:
+		h.writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+type s3Bucket struct {
+	Name         string
+	CreationDate string
+}
+
+func (h *S3Handler) listBuckets(w http.ResponseWriter, r *http.Request) {
+	h.writeXML(w, r, http.StatusOK, struct {
+		XMLName xml.Name   `xml:"ListAllMyBucketsResult"`
+		XMLNS   string     `xml:"xmlns,attr"`
+		Owner   s3Owner    `xml:"Owner"`
+		Buckets []s3Bucket `xml:"Buckets>Bucket"`
+	}{
+		XMLNS: s3XMLNS,
+		Owner: s3Owner{ID: h.opts.AccessKey, DisplayName: h.opts.AccessKey},
+		Buckets: []s3Bucket{{
+			Name:         h.opts.Bucket,
+			CreationDate: formatS3Time(time.Unix(0, 0)),
+		}},
+	})
+}
+
+type s3Owner struct {
+	ID          string
+	DisplayName string
+}
+
+// writeError writes the error response of err, errors from storager will be
+// converted into S3 errors.
+func (h *S3Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var serr *s3Error
+	switch {
+	case errors.As(err, &serr):
+	case errors.Is(err, services.ErrObjectNotExist):
+		serr = errNoSuchKey
+	case errors.Is(err, services.ErrPermissionDenied):
+		serr = errAccessDenied
+	default:
+		h.logger.Error("serve s3", zap.String("method", r.Method),
+			zap.String("path", r.URL.Path), zap.Error(err))
+		serr = errInternalError
+	}
+
+	// Response of HEAD doesn't have body.
+	if r.Method == http.MethodHead {
+		w.WriteHeader(serr.StatusCode)
+		return
+	}
+	h.writeXML(w, r, serr.StatusCode, s3ErrorResponse{
+		Code:     serr.Code,
+		Message:  serr.Message,
+		Resource: r.URL.Path,
+	})
+}
+
+func (h *S3Handler) writeXML(w http.ResponseWriter, r *http.Request, code int, v interface{}) {
+	content, err := xml.Marshal(v)
+	if err != nil {
+		h.logger.Error("marshal xml", zap.String("path", r.URL.Path), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(content)
+}
+
+func hasQuery(query map[string][]string, name string) bool {
+	_, ok := query[name]
+	return ok
+}
+
+func formatS3Time(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
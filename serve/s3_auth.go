@@ -0,0 +1,379 @@
+package serve
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	sigV4Time      = "20060102T150405Z"
+
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+	// maxClockSkew is the max difference between the request time and the
+	// server time.
+	maxClockSkew = 15 * time.Minute
+	// maxPresignExpire is the max expire of presigned requests.
+	maxPresignExpire = 7 * 24 * time.Hour
+)
+
+// emptySHA256 is the hex encoded sha256 of empty content.
+var emptySHA256 = hex.EncodeToString(sha256.New().Sum(nil))
+
+// sigV4 is a verified AWS Signature Version 4, which is used to verify
+// signatures of chunks in streaming uploads.
+type sigV4 struct {
+	key       []byte
+	time      string
+	scope     string
+	signature string
+	// payload is the value of x-amz-content-sha256.
+	payload string
+}
+
+// authenticate verifies the signature of r which could be either in the
+// Authorization header or in the query.
+func (h *S3Handler) authenticate(r *http.Request) (*sigV4, *s3Error) {
+	if r.URL.Query().Get("X-Amz-Algorithm") != "" {
+		return h.authenticateQuery(r)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return nil, errAccessDenied
+	}
+	if !strings.HasPrefix(auth, sigV4Algorithm+" ") {
+		return nil, errSignatureVersionNotSupported
+	}
+
+	fields := make(map[string]string)
+	for _, field := range strings.Split(strings.TrimPrefix(auth, sigV4Algorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			return nil, errAuthorizationHeaderMalformed
+		}
+		fields[kv[0]] = kv[1]
+	}
+	credential, signedHeaders, signature := fields["Credential"], fields["SignedHeaders"], fields["Signature"]
+	if credential == "" || signedHeaders == "" || signature == "" {
+		return nil, errAuthorizationHeaderMalformed
+	}
+
+	amzTime := r.Header.Get("X-Amz-Date")
+	if amzTime == "" {
+		amzTime = r.Header.Get("Date")
+	}
+	t, err := time.Parse(sigV4Time, amzTime)
+	if err != nil {
+		return nil, errAccessDenied
+	}
+	if d := h.now().Sub(t); d > maxClockSkew || d < -maxClockSkew {
+		return nil, errRequestTimeTooSkewed
+	}
+
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+	if payload == "" {
+		payload = emptySHA256
+	}
+
+	return h.verify(r, amzTime, credential, signedHeaders, signature, payload, r.URL.Query())
+}
+
+// authenticateQuery verifies presigned requests.
+func (h *S3Handler) authenticateQuery(r *http.Request) (*sigV4, *s3Error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != sigV4Algorithm {
+		return nil, errSignatureVersionNotSupported
+	}
+
+	amzTime := query.Get("X-Amz-Date")
+	t, err := time.Parse(sigV4Time, amzTime)
+	if err != nil {
+		return nil, errAuthorizationQueryParametersError
+	}
+	expire, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expire < 0 || time.Duration(expire)*time.Second > maxPresignExpire {
+		return nil, errAuthorizationQueryParametersError
+	}
+	now := h.now()
+	if now.Before(t.Add(-maxClockSkew)) {
+		return nil, errRequestNotReadyYet
+	}
+	if now.After(t.Add(time.Duration(expire) * time.Second)) {
+		return nil, errExpiredToken
+	}
+
+	signature := query.Get("X-Amz-Signature")
+	query.Del("X-Amz-Signature")
+
+	return h.verify(r, amzTime, query.Get("X-Amz-Credential"), query.Get("X-Amz-SignedHeaders"),
+		signature, unsignedPayload, query)
+}
+
+func (h *S3Handler) verify(
+	r *http.Request, amzTime, credential, signedHeaders, signature, payload string, query url.Values,
+) (*sigV4, *s3Error) {
+	// Credential is in format `<access-key>/<date>/<region>/<service>/aws4_request`.
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" || !strings.HasPrefix(amzTime, parts[1]) {
+		return nil, errAuthorizationHeaderMalformed
+	}
+	if subtle.ConstantTimeCompare([]byte(parts[0]), []byte(h.opts.AccessKey)) != 1 {
+		return nil, errInvalidAccessKeyID
+	}
+	scope := strings.Join(parts[1:], "/")
+
+	canonical, err := canonicalRequest(r, query, strings.Split(signedHeaders, ";"), payload)
+	if err != nil {
+		return nil, errAuthorizationHeaderMalformed
+	}
+
+	key := signingKey(h.opts.SecretKey, parts[1], parts[2], parts[3])
+	expected := hmacHex(key, stringToSign(amzTime, scope, canonical))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return nil, errSignatureDoesNotMatch
+	}
+
+	return &sigV4{
+		key:       key,
+		time:      amzTime,
+		scope:     scope,
+		signature: signature,
+		payload:   payload,
+	}, nil
+}
+
+// canonicalRequest builds the canonical request described in
+// https://docs.aws.amazon.com/general/latest/gr/sigv4-create-canonical-request.html
+func canonicalRequest(r *http.Request, query url.Values, signedHeaders []string, payload string) (string, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteString(r.Method)
+	buf.WriteByte('\n')
+	buf.WriteString(uriEncode(r.URL.Path, false))
+	buf.WriteByte('\n')
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	buf.WriteString(strings.Join(params, "&"))
+	buf.WriteByte('\n')
+
+	for _, name := range signedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		default:
+			values, ok := r.Header[http.CanonicalHeaderKey(name)]
+			if !ok {
+				return "", fmt.Errorf("signed header %s is missing", name)
+			}
+			trimmed := make([]string, 0, len(values))
+			for _, v := range values {
+				trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+			}
+			value = strings.Join(trimmed, ",")
+		}
+		buf.WriteString(name + ":" + value + "\n")
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(strings.Join(signedHeaders, ";"))
+	buf.WriteByte('\n')
+	buf.WriteString(payload)
+	return buf.String(), nil
+}
+
+func stringToSign(amzTime, scope, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return sigV4Algorithm + "\n" + amzTime + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+}
+
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSum([]byte("AWS4"+secret), date)
+	key = hmacSum(key, region)
+	key = hmacSum(key, service)
+	return hmacSum(key, "aws4_request")
+}
+
+func hmacSum(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func hmacHex(key []byte, data string) string {
+	return hex.EncodeToString(hmacSum(key, data))
+}
+
+// uriEncode encodes s as required by AWS, which only keeps unreserved
+// characters. `/` will be kept unless encodeSlash is true.
+func uriEncode(s string, encodeSlash bool) string {
+	const hexChars = "0123456789ABCDEF"
+
+	buf := new(strings.Builder)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			buf.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte('%')
+			buf.WriteByte(hexChars[c>>4])
+			buf.WriteByte(hexChars[c&15])
+		}
+	}
+	return buf.String()
+}
+
+// payloadReader verifies the body against the sha256 in x-amz-content-sha256.
+type payloadReader struct {
+	r        io.Reader
+	h        hash.Hash
+	expected string
+}
+
+func newPayloadReader(r io.Reader, expected string) *payloadReader {
+	return &payloadReader{r: r, h: sha256.New(), expected: expected}
+}
+
+func (r *payloadReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(r.h.Sum(nil)) != r.expected {
+		return n, errXAmzContentSHA256Mismatch
+	}
+	return n, err
+}
+
+// chunkedReader decodes body in aws-chunked encoding and verifies signature
+// of every chunk, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+type chunkedReader struct {
+	r   *bufio.Reader
+	sig *sigV4
+	// prev is the signature of the previous chunk.
+	prev string
+
+	h         hash.Hash
+	signature string
+	// left is the size of the current chunk not read yet.
+	left int64
+	done bool
+}
+
+func newChunkedReader(r io.Reader, sig *sigV4) *chunkedReader {
+	return &chunkedReader{
+		r:    bufio.NewReader(r),
+		sig:  sig,
+		prev: sig.signature,
+		h:    sha256.New(),
+	}
+}
+
+var errMalformedChunk = errors.New("malformed chunk")
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	for r.left == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	r.left -= int64(n)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return n, err
+	}
+	if r.left == 0 {
+		err = r.finishChunk()
+	}
+	return n, err
+}
+
+// next reads the header of the next chunk like
+// `<hex-size>;chunk-signature=<signature>\r\n`.
+func (r *chunkedReader) next() error {
+	line, err := r.r.ReadString('\n')
+	if err != nil {
+		return errMalformedChunk
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	idx := strings.Index(line, ";chunk-signature=")
+	if idx == -1 {
+		return errMalformedChunk
+	}
+	size, err := strconv.ParseInt(line[:idx], 16, 64)
+	if err != nil || size < 0 {
+		return errMalformedChunk
+	}
+
+	r.signature = line[idx+len(";chunk-signature="):]
+	r.left = size
+	r.h.Reset()
+	if size == 0 {
+		// The last chunk is empty.
+		r.done = true
+		return r.finishChunk()
+	}
+	return nil
+}
+
+// finishChunk verifies the signature of the current chunk and consumes the
+// trailing `\r\n`.
+func (r *chunkedReader) finishChunk() error {
+	toSign := "AWS4-HMAC-SHA256-PAYLOAD\n" + r.sig.time + "\n" + r.sig.scope + "\n" +
+		r.prev + "\n" + emptySHA256 + "\n" + hex.EncodeToString(r.h.Sum(nil))
+	expected := hmacHex(r.sig.key, toSign)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(r.signature)) != 1 {
+		return errSignatureDoesNotMatch
+	}
+	r.prev = r.signature
+
+	crlf := make([]byte, 2)
+	if _, err := io.ReadFull(r.r, crlf); err != nil || string(crlf) != "\r\n" {
+		return errMalformedChunk
+	}
+	return nil
+}
@@ -0,0 +1,585 @@
+package serve
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/services"
+	"go.beyondstorage.io/v5/types"
+)
+
+const (
+	// maxS3Keys is the max number of keys returned by ListObjectsV2.
+	maxS3Keys = 1000
+	// maxS3Parts is the max part number of multipart uploads.
+	maxS3Parts = 10000
+	// maxS3XMLSize is the max size of XML request bodies.
+	maxS3XMLSize = 2 << 20
+)
+
+// body returns the decoded body of r and its size.
+func (h *S3Handler) body(r *http.Request, sig *sigV4) (io.Reader, int64, error) {
+	switch {
+	case sig.payload == streamingPayload:
+		size, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil || size < 0 {
+			return nil, 0, errMissingContentLength
+		}
+		return newChunkedReader(r.Body, sig), size, nil
+	case r.ContentLength < 0:
+		return nil, 0, errMissingContentLength
+	case sig.payload == unsignedPayload:
+		return r.Body, r.ContentLength, nil
+	case len(sig.payload) == sha256HexLen:
+		return newPayloadReader(r.Body, sig.payload), r.ContentLength, nil
+	}
+	return nil, 0, errNotImplemented
+}
+
+// sha256HexLen is the length of hex encoded sha256.
+const sha256HexLen = 64
+
+func (h *S3Handler) getObject(w http.ResponseWriter, r *http.Request, key string) {
+	o, err := h.so.Stat(key)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if o.Mode.IsDir() {
+		h.writeError(w, r, errNoSuchKey)
+		return
+	}
+
+	size, _ := o.GetContentLength()
+	modTime, _ := o.GetLastModified()
+	w.Header().Set("Content-Type", contentType(o))
+	if etag, ok := o.GetEtag(); ok {
+		w.Header().Set("ETag", quoteETag(etag))
+	}
+	if meta, ok := o.GetUserMetadata(); ok {
+		for k, v := range meta {
+			w.Header().Set("X-Amz-Meta-"+k, v)
+		}
+	}
+
+	or := newObjectReader(r.Context(), h.store, key, size)
+	defer or.Close()
+	http.ServeContent(w, r, path.Base(key), modTime, or)
+}
+
+// putObject writes the object, objects larger than MultipartThreshold will be
+// written via multipart if supported.
+func (h *S3Handler) putObject(w http.ResponseWriter, r *http.Request, sig *sigV4, key string) {
+	body, size, err := h.body(r, sig)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	md5sum := md5.New()
+	body = io.TeeReader(body, md5sum)
+
+	var ps []types.Pair
+	if v := r.Header.Get("Content-Type"); v != "" {
+		ps = append(ps, pairs.WithContentType(v))
+	}
+
+	_, isMultiparter := h.store.(types.Multiparter)
+	if isMultiparter && size >= h.opts.MultipartThreshold {
+		var ch chan *operations.EmptyResult
+		ch, err = h.so.TeeRun(key, size, body)
+		if err == nil {
+			for v := range ch {
+				if v.Error != nil {
+					err = v.Error
+				}
+			}
+		}
+	} else {
+		_, err = h.store.WriteWithContext(r.Context(), key, body, size, ps...)
+	}
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", hashETag(md5sum))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *S3Handler) deleteObject(w http.ResponseWriter, r *http.Request, key string) {
+	// Deleting objects not exist is not an error in S3.
+	err := h.so.Delete(key)
+	if err != nil && !errors.Is(err, services.ErrObjectNotExist) {
+		h.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type s3DeleteRequest struct {
+	Quiet   bool
+	Objects []struct {
+		Key string
+	} `xml:"Object"`
+}
+
+type s3Deleted struct {
+	Key string
+}
+
+type s3DeleteError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+func (h *S3Handler) deleteObjects(w http.ResponseWriter, r *http.Request, sig *sigV4) {
+	var req s3DeleteRequest
+	if err := h.readXML(r, sig, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	result := struct {
+		XMLName xml.Name        `xml:"DeleteResult"`
+		XMLNS   string          `xml:"xmlns,attr"`
+		Deleted []s3Deleted     `xml:"Deleted"`
+		Errors  []s3DeleteError `xml:"Error"`
+	}{XMLNS: s3XMLNS}
+
+	for _, v := range req.Objects {
+		if !validKey(v.Key) {
+			result.Errors = append(result.Errors, s3DeleteError{
+				Key:     v.Key,
+				Code:    errInvalidArgument.Code,
+				Message: errInvalidArgument.Message,
+			})
+			continue
+		}
+		err := h.so.Delete(h.root + v.Key)
+		if err != nil && !errors.Is(err, services.ErrObjectNotExist) {
+			result.Errors = append(result.Errors, s3DeleteError{
+				Key:     v.Key,
+				Code:    errInternalError.Code,
+				Message: err.Error(),
+			})
+			continue
+		}
+		if !req.Quiet {
+			result.Deleted = append(result.Deleted, s3Deleted{Key: v.Key})
+		}
+	}
+	h.writeXML(w, r, http.StatusOK, result)
+}
+
+// readXML decodes the signed XML body of r into v.
+func (h *S3Handler) readXML(r *http.Request, sig *sigV4, v interface{}) error {
+	body, _, err := h.body(r, sig)
+	if err != nil {
+		return err
+	}
+	err = xml.NewDecoder(io.LimitReader(body, maxS3XMLSize)).Decode(v)
+	var serr *s3Error
+	if errors.As(err, &serr) {
+		return serr
+	}
+	if err != nil {
+		return errMalformedXML
+	}
+	// Read to the end, so that the payload could be verified.
+	if _, err = io.Copy(ioutil.Discard, body); err != nil {
+		return errMalformedXML
+	}
+	return nil
+}
+
+type s3Content struct {
+	Key          string
+	LastModified string `xml:",omitempty"`
+	ETag         string `xml:",omitempty"`
+	Size         int64
+	StorageClass string
+}
+
+type s3CommonPrefix struct {
+	Prefix string
+}
+
+// s3Entry is an object or a common prefix in bucket.
+type s3Entry struct {
+	key      string
+	object   *types.Object
+	isPrefix bool
+}
+
+// listObjectsV2 lists objects in bucket.
+//
+// Storagers don't support listing from a given key, so all matched objects
+// will be listed and sorted for every page.
+func (h *S3Handler) listObjectsV2(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	if delimiter != "" && delimiter != "/" {
+		h.writeError(w, r, errNotImplemented)
+		return
+	}
+	if !validKey(prefix) {
+		h.writeError(w, r, errInvalidArgument)
+		return
+	}
+
+	maxKeys := maxS3Keys
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			h.writeError(w, r, errInvalidArgument)
+			return
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	after := query.Get("start-after")
+	if token := query.Get("continuation-token"); token != "" {
+		v, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			h.writeError(w, r, errInvalidArgument)
+			return
+		}
+		after = string(v)
+	}
+
+	entries, err := h.listEntries(r.Context(), prefix, delimiter)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	idx := sort.Search(len(entries), func(i int) bool {
+		return entries[i].key > after
+	})
+	entries = entries[idx:]
+
+	encode := func(s string) string { return s }
+	if query.Get("encoding-type") == "url" {
+		encode = url.QueryEscape
+	}
+
+	result := struct {
+		XMLName               xml.Name         `xml:"ListBucketResult"`
+		XMLNS                 string           `xml:"xmlns,attr"`
+		Name                  string           `xml:"Name"`
+		Prefix                string           `xml:"Prefix"`
+		Delimiter             string           `xml:"Delimiter,omitempty"`
+		StartAfter            string           `xml:"StartAfter,omitempty"`
+		ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+		EncodingType          string           `xml:"EncodingType,omitempty"`
+		MaxKeys               int              `xml:"MaxKeys"`
+		KeyCount              int              `xml:"KeyCount"`
+		IsTruncated           bool             `xml:"IsTruncated"`
+		Contents              []s3Content      `xml:"Contents"`
+		CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+	}{
+		XMLNS:             s3XMLNS,
+		Name:              h.opts.Bucket,
+		Prefix:            encode(prefix),
+		Delimiter:         encode(delimiter),
+		StartAfter:        encode(query.Get("start-after")),
+		ContinuationToken: query.Get("continuation-token"),
+		EncodingType:      query.Get("encoding-type"),
+		MaxKeys:           maxKeys,
+	}
+
+	if len(entries) > maxKeys {
+		entries = entries[:maxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(entries[len(entries)-1].key))
+	}
+	result.KeyCount = len(entries)
+
+	for _, e := range entries {
+		if e.isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: encode(e.key)})
+			continue
+		}
+
+		c := s3Content{Key: encode(e.key), StorageClass: "STANDARD"}
+		c.Size, _ = e.object.GetContentLength()
+		if t, ok := e.object.GetLastModified(); ok {
+			c.LastModified = formatS3Time(t)
+		}
+		if etag, ok := e.object.GetEtag(); ok {
+			c.ETag = quoteETag(etag)
+		}
+		result.Contents = append(result.Contents, c)
+	}
+	h.writeXML(w, r, http.StatusOK, result)
+}
+
+// listEntries lists all objects and common prefixes matching prefix sorted by
+// key. Delimiter could be either empty or `/`, dirs will be walked recursively
+// if it's empty.
+func (h *S3Handler) listEntries(ctx context.Context, prefix, delimiter string) ([]s3Entry, error) {
+	var entries []s3Entry
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		it, err := h.store.ListWithContext(ctx, h.root+dir, pairs.WithListMode(types.ListModeDir))
+		if err != nil {
+			return err
+		}
+
+		for {
+			o, err := it.Next()
+			if err != nil && errors.Is(err, types.IterateDone) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			name := childName(h.root+dir, o)
+			// Skip the dir itself which may be returned by some services.
+			if name == "" {
+				continue
+			}
+			key := dir + name
+
+			if !o.Mode.IsDir() {
+				if strings.HasPrefix(key, prefix) {
+					entries = append(entries, s3Entry{key: key, object: o})
+				}
+				continue
+			}
+
+			key += "/"
+			if delimiter == "/" {
+				if strings.HasPrefix(key, prefix) {
+					entries = append(entries, s3Entry{key: key, isPrefix: true})
+				}
+				continue
+			}
+			if strings.HasPrefix(key, prefix) || strings.HasPrefix(prefix, key) {
+				if err = walk(key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(prefix[:strings.LastIndex(prefix, "/")+1]); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	return entries, nil
+}
+
+func (h *S3Handler) multiparter() (types.Multiparter, error) {
+	m, ok := h.store.(types.Multiparter)
+	if !ok {
+		return nil, errNotImplemented
+	}
+	return m, nil
+}
+
+func (h *S3Handler) createMultipartUpload(w http.ResponseWriter, r *http.Request, key string) {
+	m, err := h.multiparter()
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	var ps []types.Pair
+	if v := r.Header.Get("Content-Type"); v != "" {
+		ps = append(ps, pairs.WithContentType(v))
+	}
+	o, err := m.CreateMultipart(key, ps...)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeXML(w, r, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		XMLNS    string   `xml:"xmlns,attr"`
+		Bucket   string
+		Key      string
+		UploadID string `xml:"UploadId"`
+	}{
+		XMLNS:    s3XMLNS,
+		Bucket:   h.opts.Bucket,
+		Key:      strings.TrimPrefix(key, h.root),
+		UploadID: o.MustGetMultipartID(),
+	})
+}
+
+// uploadPart writes the part, part number in S3 starts from 1 while index in
+// storager starts from 0.
+func (h *S3Handler) uploadPart(w http.ResponseWriter, r *http.Request, sig *sigV4, key, uploadID string) {
+	m, err := h.multiparter()
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || number < 1 || number > maxS3Parts {
+		h.writeError(w, r, errInvalidArgument)
+		return
+	}
+
+	body, size, err := h.body(r, sig)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	md5sum := md5.New()
+	body = io.TeeReader(body, md5sum)
+
+	o := h.store.Create(key, pairs.WithMultipartID(uploadID))
+	_, part, err := m.WriteMultipart(o, body, size, number-1)
+	if err != nil {
+		h.writeMultipartError(w, r, err)
+		return
+	}
+
+	etag := hashETag(md5sum)
+	if part != nil && part.ETag != "" {
+		etag = quoteETag(part.ETag)
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+type s3CompleteRequest struct {
+	Parts []struct {
+		PartNumber int
+		ETag       string
+	} `xml:"Part"`
+}
+
+func (h *S3Handler) completeMultipartUpload(w http.ResponseWriter, r *http.Request, sig *sigV4, key, uploadID string) {
+	m, err := h.multiparter()
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	var req s3CompleteRequest
+	if err = h.readXML(r, sig, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	o := h.store.Create(key, pairs.WithMultipartID(uploadID))
+	uploaded, err := listParts(m, o)
+	if err != nil {
+		h.writeMultipartError(w, r, err)
+		return
+	}
+
+	parts := make([]*types.Part, 0, len(req.Parts))
+	for _, v := range req.Parts {
+		p, ok := uploaded[v.PartNumber-1]
+		if !ok || (p.ETag != "" && strings.Trim(p.ETag, `"`) != strings.Trim(v.ETag, `"`)) {
+			h.writeError(w, r, errInvalidPart)
+			return
+		}
+		parts = append(parts, p)
+	}
+
+	err = m.CompleteMultipart(o, parts)
+	if err != nil {
+		h.writeMultipartError(w, r, err)
+		return
+	}
+
+	var etag string
+	if obj, err := h.store.StatWithContext(r.Context(), key); err == nil {
+		if v, ok := obj.GetEtag(); ok {
+			etag = quoteETag(v)
+		}
+	}
+	h.writeXML(w, r, http.StatusOK, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		XMLNS   string   `xml:"xmlns,attr"`
+		Bucket  string
+		Key     string
+		ETag    string `xml:",omitempty"`
+	}{
+		XMLNS:  s3XMLNS,
+		Bucket: h.opts.Bucket,
+		Key:    strings.TrimPrefix(key, h.root),
+		ETag:   etag,
+	})
+}
+
+// listParts returns uploaded parts of multipart object o indexed by index.
+func listParts(m types.Multiparter, o *types.Object) (map[int]*types.Part, error) {
+	it, err := m.ListMultipart(o)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make(map[int]*types.Part)
+	for {
+		p, err := it.Next()
+		if err != nil && errors.Is(err, types.IterateDone) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parts[p.Index] = p
+	}
+	return parts, nil
+}
+
+func (h *S3Handler) abortMultipartUpload(w http.ResponseWriter, r *http.Request, key, uploadID string) {
+	if _, err := h.multiparter(); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	err := h.so.Delete(key, pairs.WithMultipartID(uploadID))
+	if err != nil {
+		h.writeMultipartError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeMultipartError writes err of multipart operations, objects not exist
+// means the upload not exist.
+func (h *S3Handler) writeMultipartError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, services.ErrObjectNotExist) {
+		err = errNoSuchUpload
+	}
+	h.writeError(w, r, err)
+}
+
+func hashETag(h hash.Hash) string {
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
@@ -0,0 +1,328 @@
+// Package serve exposes a storager over HTTP and WebDAV.
+package serve
+
+import (
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/v5/pairs"
+	"go.beyondstorage.io/v5/services"
+	"go.beyondstorage.io/v5/types"
+)
+
+// Options are options of the handler.
+type Options struct {
+	// WebDAV enables WebDAV methods, only GET and HEAD will be served
+	// otherwise.
+	WebDAV bool
+	// ReadOnly rejects all WebDAV methods that modify objects.
+	ReadOnly bool
+	// Username and Password are the basic auth credentials required by
+	// WebDAV methods that modify objects. These methods will be rejected if
+	// they are empty.
+	Username string
+	Password string
+	// MultipartThreshold is the size that objects larger than it will be
+	// written or moved via multipart.
+	MultipartThreshold int64
+}
+
+// Handler serves objects under root in storager.
+type Handler struct {
+	store types.Storager
+	so    *operations.SingleOperator
+	do    *operations.DualOperator
+	// root is the key prefix of the served dir, which is empty or ends with
+	// `/`.
+	root   string
+	opts   Options
+	logger *zap.Logger
+}
+
+// New creates a handler, so and do should operate on store, and do is used to
+// move objects in WebDAV mode.
+func New(store types.Storager, so *operations.SingleOperator, do *operations.DualOperator, root string, opts Options) *Handler {
+	if root != "" && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+
+	// TODO: we will allow user config log level.
+	logger, _ := zap.NewDevelopment()
+
+	return &Handler{
+		store:  store,
+		so:     so,
+		do:     do,
+		root:   root,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.serveGet(w, r)
+		return
+	}
+
+	if !h.opts.WebDAV {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		h.serveOptions(w, r)
+		return
+	case "PROPFIND":
+		h.servePropfind(w, r)
+		return
+	}
+
+	if h.opts.ReadOnly {
+		w.Header().Set("Allow", h.allowedMethods())
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="byctl"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		h.servePut(w, r)
+	case http.MethodDelete:
+		h.serveDelete(w, r)
+	case "MKCOL":
+		h.serveMkcol(w, r)
+	case "MOVE":
+		h.serveMove(w, r)
+	default:
+		w.Header().Set("Allow", h.allowedMethods())
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized checks whether r is signed with the basic auth credentials in
+// options. Credentials are compared in constant time to avoid leaking them via
+// timing.
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.opts.Username == "" || h.opts.Password == "" {
+		return false
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	userMatch := subtle.ConstantTimeCompare([]byte(username), []byte(h.opts.Username)) == 1
+	passMatch := subtle.ConstantTimeCompare([]byte(password), []byte(h.opts.Password)) == 1
+	return userMatch && passMatch
+}
+
+// key returns the key of object and whether it's requested as a dir.
+//
+// Keys of dirs end with `/`, except the root which could be empty.
+func (h *Handler) key(urlPath string) (key string, isDir bool) {
+	rel := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if rel == "" {
+		return h.root, true
+	}
+	if strings.HasSuffix(urlPath, "/") {
+		return h.root + rel + "/", true
+	}
+	return h.root + rel, false
+}
+
+func (h *Handler) serveGet(w http.ResponseWriter, r *http.Request) {
+	key, isDir := h.key(r.URL.Path)
+	if isDir {
+		h.serveDir(w, r, key)
+		return
+	}
+
+	o, err := h.so.Stat(key)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	if o.Mode.IsDir() {
+		u := *r.URL
+		u.Path += "/"
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+		return
+	}
+
+	size, _ := o.GetContentLength()
+	modTime, _ := o.GetLastModified()
+	w.Header().Set("Content-Type", contentType(o))
+	if etag, ok := o.GetEtag(); ok {
+		w.Header().Set("ETag", quoteETag(etag))
+	}
+
+	or := newObjectReader(r.Context(), h.store, key, size)
+	defer or.Close()
+	http.ServeContent(w, r, path.Base(key), modTime, or)
+}
+
+type indexEntry struct {
+	Name    string
+	Href    string
+	Size    int64
+	ModTime string
+	IsDir   bool
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Index of {{.Path}}</title></head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Last Modified</th></tr>
+{{- if ne .Path "/"}}
+<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="{{.Href}}">{{.Name}}</a></td><td>{{if not .IsDir}}{{.Size}}{{end}}</td><td>{{.ModTime}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+func (h *Handler) serveDir(w http.ResponseWriter, r *http.Request, key string) {
+	objects, found, err := h.list(r, key)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	if !found && key != h.root {
+		http.NotFound(w, r)
+		return
+	}
+
+	entries := make([]indexEntry, 0, len(objects))
+	for _, o := range objects {
+		name := childName(key, o)
+		e := indexEntry{
+			Name: name,
+			// Names like `a:b` should not be parsed as schemes.
+			Href:  "./" + (&url.URL{Path: name}).EscapedPath(),
+			IsDir: o.Mode.IsDir(),
+		}
+		if e.IsDir {
+			e.Name += "/"
+			e.Href += "/"
+		}
+		e.Size, _ = o.GetContentLength()
+		if t, ok := o.GetLastModified(); ok {
+			e.ModTime = t.UTC().Format(time.RFC3339)
+		}
+		entries = append(entries, e)
+	}
+	// Dirs go first.
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	displayPath := path.Clean("/" + r.URL.Path)
+	if displayPath != "/" {
+		displayPath += "/"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = indexTemplate.Execute(w, struct {
+		Path    string
+		Entries []indexEntry
+	}{
+		Path:    displayPath,
+		Entries: entries,
+	})
+	if err != nil {
+		h.logger.Error("render index", zap.String("path", key), zap.Error(err))
+	}
+}
+
+// list lists children of dir key. found reports whether dir exists, which is
+// true if any object including the dir itself is listed.
+func (h *Handler) list(r *http.Request, key string) (objects []*types.Object, found bool, err error) {
+	it, err := h.store.ListWithContext(r.Context(), key, pairs.WithListMode(types.ListModeDir))
+	if err != nil {
+		return nil, false, err
+	}
+
+	for {
+		o, err := it.Next()
+		if err != nil && errors.Is(err, types.IterateDone) {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		found = true
+		// Skip the dir itself which may be returned by some services.
+		if childName(key, o) == "" {
+			continue
+		}
+		objects = append(objects, o)
+	}
+	return objects, found, nil
+}
+
+// error writes the status code according to err.
+func (h *Handler) error(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrObjectNotExist):
+		http.NotFound(w, r)
+	case errors.Is(err, services.ErrPermissionDenied):
+		http.Error(w, "permission denied", http.StatusForbidden)
+	default:
+		h.logger.Error("serve", zap.String("method", r.Method),
+			zap.String("path", r.URL.Path), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func childName(dir string, o *types.Object) string {
+	return strings.TrimSuffix(strings.TrimPrefix(o.Path, dir), "/")
+}
+
+// contentType returns the content type of object, which will be detected via
+// the extension if object doesn't have one.
+func contentType(o *types.Object) string {
+	if v, ok := o.GetContentType(); ok && v != "" {
+		return v
+	}
+	if v := mime.TypeByExtension(path.Ext(o.Path)); v != "" {
+		return v
+	}
+	return "application/octet-stream"
+}
+
+// quoteETag makes sure etag is quoted as required by RFC 7232.
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
@@ -0,0 +1,101 @@
+package serve
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_Key(t *testing.T) {
+	cases := []struct {
+		name  string
+		root  string
+		path  string
+		key   string
+		isDir bool
+	}{
+		{"root", "", "/", "", true},
+		{"empty path", "data", "", "data/", true},
+		{"file", "data/", "/a/b.txt", "data/a/b.txt", false},
+		{"dir", "data", "/a/", "data/a/", true},
+		{"dot dot", "data", "/../../etc/passwd", "data/etc/passwd", false},
+		{"clean", "", "//a/./b/../c", "a/c", false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(nil, nil, nil, tt.root, Options{})
+			key, isDir := h.key(tt.path)
+			assert.Equal(t, tt.key, key)
+			assert.Equal(t, tt.isDir, isDir)
+		})
+	}
+}
+
+func TestQuoteETag(t *testing.T) {
+	assert.Equal(t, `"abc"`, quoteETag("abc"))
+	assert.Equal(t, `"abc"`, quoteETag(`"abc"`))
+	assert.Equal(t, `W/"abc"`, quoteETag(`W/"abc"`))
+}
+
+func TestObjectReader_Seek(t *testing.T) {
+	r := newObjectReader(context.Background(), nil, "a", 10)
+
+	n, err := r.Seek(4, io.SeekStart)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+
+	n, err = r.Seek(2, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+
+	n, err = r.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), n)
+
+	// Read at the end should not hit the storager.
+	_, err = r.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+
+	_, err = r.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+}
+
+func TestHandler_WriteAuth(t *testing.T) {
+	cases := []struct {
+		name   string
+		opts   Options
+		user   string
+		pass   string
+		status int
+	}{
+		{"read only", Options{WebDAV: true, ReadOnly: true, Username: "u", Password: "p"}, "u", "p", http.StatusMethodNotAllowed},
+		{"no credentials configured", Options{WebDAV: true}, "", "", http.StatusUnauthorized},
+		{"no auth", Options{WebDAV: true, Username: "u", Password: "p"}, "", "", http.StatusUnauthorized},
+		{"wrong password", Options{WebDAV: true, Username: "u", Password: "p"}, "u", "x", http.StatusUnauthorized},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(nil, nil, nil, "", tt.opts)
+			for _, method := range []string{http.MethodPut, http.MethodDelete, "MKCOL", "MOVE"} {
+				r := httptest.NewRequest(method, "/a", nil)
+				if tt.user != "" {
+					r.SetBasicAuth(tt.user, tt.pass)
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				assert.Equal(t, tt.status, w.Code, method)
+			}
+		})
+	}
+
+	h := New(nil, nil, nil, "", Options{WebDAV: true, Username: "u", Password: "p"})
+	r := httptest.NewRequest(http.MethodPut, "/a", nil)
+	r.SetBasicAuth("u", "p")
+	assert.True(t, h.authorized(r))
+}
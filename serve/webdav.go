@@ -0,0 +1,354 @@
+package serve
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"go.beyondstorage.io/beyond-ctl/operations"
+	"go.beyondstorage.io/v5/services"
+	"go.beyondstorage.io/v5/types"
+)
+
+// defaultExpectedSize is the expected size of objects uploaded without
+// Content-Length, which is used to calculate the part size.
+const defaultExpectedSize = 128 * 1024 * 1024
+
+func (h *Handler) allowedMethods() string {
+	if h.opts.ReadOnly {
+		return "OPTIONS, GET, HEAD, PROPFIND"
+	}
+	return "OPTIONS, GET, HEAD, PROPFIND, PUT, DELETE, MKCOL, MOVE"
+}
+
+func (h *Handler) serveOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", h.allowedMethods())
+	// Only class 1 is supported, as locks are not implemented.
+	w.Header().Set("DAV", "1")
+	w.WriteHeader(http.StatusOK)
+}
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"D:multistatus"`
+	XMLNS     string        `xml:"xmlns:D,attr"`
+	Responses []davResponse `xml:"D:response"`
+}
+
+type davResponse struct {
+	Href     string      `xml:"D:href"`
+	Propstat davPropstat `xml:"D:propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"D:prop"`
+	Status string  `xml:"D:status"`
+}
+
+type davProp struct {
+	DisplayName   string           `xml:"D:displayname,omitempty"`
+	ResourceType  *davResourceType `xml:"D:resourcetype"`
+	ContentLength *int64           `xml:"D:getcontentlength,omitempty"`
+	ContentType   string           `xml:"D:getcontenttype,omitempty"`
+	LastModified  string           `xml:"D:getlastmodified,omitempty"`
+	ETag          string           `xml:"D:getetag,omitempty"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"D:collection"`
+}
+
+// servePropfind returns all properties of the resource, and its children if
+// depth is not 0.
+//
+// Depth infinity is treated as 1, as listing all objects recursively may be
+// too expensive.
+func (h *Handler) servePropfind(w http.ResponseWriter, r *http.Request) {
+	key, isDir := h.key(r.URL.Path)
+	href := path.Clean("/" + r.URL.Path)
+
+	var o *types.Object
+	if key == h.root {
+		o = h.store.Create(key)
+		o.Mode = types.ModeDir
+	} else {
+		var err error
+		o, err = h.so.Stat(strings.TrimSuffix(key, "/"))
+		if err != nil {
+			h.error(w, r, err)
+			return
+		}
+		if isDir && !o.Mode.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	ms := davMultistatus{XMLNS: "DAV:"}
+	ms.Responses = append(ms.Responses, davResponseOf(href, o))
+
+	if o.Mode.IsDir() && r.Header.Get("Depth") != "0" {
+		dir := strings.TrimSuffix(key, "/")
+		if dir != "" {
+			dir += "/"
+		}
+		objects, _, err := h.list(r, dir)
+		if err != nil {
+			h.error(w, r, err)
+			return
+		}
+		for _, child := range objects {
+			ms.Responses = append(ms.Responses,
+				davResponseOf(path.Join(href, childName(dir, child)), child))
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(buf).Encode(ms); err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// davResponseOf returns the response of object o whose url path is p.
+func davResponseOf(p string, o *types.Object) davResponse {
+	prop := davProp{
+		DisplayName:  path.Base(p),
+		ResourceType: &davResourceType{},
+	}
+	if o.Mode.IsDir() {
+		prop.ResourceType.Collection = &struct{}{}
+		if p != "/" {
+			p += "/"
+		}
+	} else {
+		size, _ := o.GetContentLength()
+		prop.ContentLength = &size
+		prop.ContentType = contentType(o)
+		if etag, ok := o.GetEtag(); ok {
+			prop.ETag = quoteETag(etag)
+		}
+	}
+	if t, ok := o.GetLastModified(); ok {
+		prop.LastModified = t.UTC().Format(http.TimeFormat)
+	}
+
+	return davResponse{
+		Href: (&url.URL{Path: p}).EscapedPath(),
+		Propstat: davPropstat{
+			Prop:   prop,
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
+
+func (h *Handler) servePut(w http.ResponseWriter, r *http.Request) {
+	key, isDir := h.key(r.URL.Path)
+	if isDir {
+		http.Error(w, "cannot put a collection", http.StatusMethodNotAllowed)
+		return
+	}
+
+	size := r.ContentLength
+	_, isMultiparter := h.store.(types.Multiparter)
+
+	var err error
+	switch {
+	case isMultiparter && (size < 0 || size >= h.opts.MultipartThreshold):
+		if size < 0 {
+			size = defaultExpectedSize
+		}
+		var ch chan *operations.EmptyResult
+		ch, err = h.so.TeeRun(key, size, r.Body)
+		if err == nil {
+			for v := range ch {
+				if v.Error != nil {
+					err = v.Error
+				}
+			}
+		}
+	case size >= 0:
+		_, err = h.store.WriteWithContext(r.Context(), key, r.Body, size)
+	default:
+		http.Error(w, "Content-Length is required", http.StatusLengthRequired)
+		return
+	}
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) serveDelete(w http.ResponseWriter, r *http.Request) {
+	key, _ := h.key(r.URL.Path)
+	if key == h.root {
+		http.Error(w, "cannot delete the root", http.StatusForbidden)
+		return
+	}
+	key = strings.TrimSuffix(key, "/")
+
+	o, err := h.so.Stat(key)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	if o.Mode.IsDir() {
+		err = h.deleteDir(key + "/")
+	} else {
+		err = h.so.Delete(key)
+	}
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteDir deletes all objects in dir and the dir itself.
+func (h *Handler) deleteDir(dir string) error {
+	ch, err := h.so.DeleteRecursively(dir)
+	if err != nil {
+		return err
+	}
+	for v := range ch {
+		if v.Error != nil {
+			err = v.Error
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Dirs in object storage may not exist as objects.
+	err = h.so.Delete(dir)
+	if err != nil && !errors.Is(err, services.ErrObjectNotExist) {
+		return err
+	}
+	return nil
+}
+
+// serveMkcol creates a dir via Direr if supported. Otherwise, an empty object
+// whose key ends with `/` will be written as the dir.
+func (h *Handler) serveMkcol(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > 0 {
+		http.Error(w, "request body is not supported", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	key, _ := h.key(r.URL.Path)
+	if key == h.root {
+		http.Error(w, "collection already exists", http.StatusMethodNotAllowed)
+		return
+	}
+	key = strings.TrimSuffix(key, "/")
+
+	_, err := h.so.Stat(key)
+	if err == nil {
+		http.Error(w, "collection already exists", http.StatusMethodNotAllowed)
+		return
+	}
+	if !errors.Is(err, services.ErrObjectNotExist) {
+		h.error(w, r, err)
+		return
+	}
+
+	if direr, ok := h.store.(types.Direr); ok {
+		_, err = direr.CreateDir(key)
+	} else {
+		_, err = h.store.WriteWithContext(r.Context(), key+"/", bytes.NewReader(nil), 0)
+	}
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) serveMove(w http.ResponseWriter, r *http.Request) {
+	src, _ := h.key(r.URL.Path)
+	if src == h.root {
+		http.Error(w, "cannot move the root", http.StatusForbidden)
+		return
+	}
+	src = strings.TrimSuffix(src, "/")
+
+	dstURL, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || dstURL.Path == "" {
+		http.Error(w, "invalid destination", http.StatusBadRequest)
+		return
+	}
+	if dstURL.Host != "" && dstURL.Host != r.Host {
+		http.Error(w, "destination is on another server", http.StatusBadGateway)
+		return
+	}
+	dst, _ := h.key(dstURL.Path)
+	if dst == h.root {
+		http.Error(w, "cannot overwrite the root", http.StatusForbidden)
+		return
+	}
+	dst = strings.TrimSuffix(dst, "/")
+	if dst == src || strings.HasPrefix(dst, src+"/") {
+		http.Error(w, "destination is the source or inside it", http.StatusForbidden)
+		return
+	}
+
+	o, err := h.so.Stat(src)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	dstObject, err := h.so.Stat(dst)
+	if err != nil && !errors.Is(err, services.ErrObjectNotExist) {
+		h.error(w, r, err)
+		return
+	}
+	exist := err == nil
+	if exist {
+		if r.Header.Get("Overwrite") == "F" {
+			http.Error(w, "destination already exists", http.StatusPreconditionFailed)
+			return
+		}
+		if dstObject.Mode.IsDir() {
+			err = h.deleteDir(dst + "/")
+		} else {
+			err = h.so.Delete(dst)
+		}
+		if err != nil {
+			h.error(w, r, err)
+			return
+		}
+	}
+
+	if o.Mode.IsDir() {
+		err = h.do.MoveRecursively(src, dst, h.opts.MultipartThreshold)
+	} else {
+		size, _ := o.GetContentLength()
+		if size < h.opts.MultipartThreshold {
+			err = h.do.MoveFileViaWrite(src, dst, size)
+		} else {
+			err = h.do.MoveFileViaMultipart(src, dst, size)
+		}
+	}
+	if err != nil {
+		h.error(w, r, fmt.Errorf("move %s to %s: %w", src, dst, err))
+		return
+	}
+
+	if exist {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}